@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// A lease in the aggregated view together with the server it is from
+type serverLease struct {
+	server *serverView
+	Lease4
+}
+
+// Fetches the leases of every server in parallel. Servers that fail
+// are left out and reported in the returned error.
+func AllLeases(ctx context.Context, servers []*serverView) ([]serverLease, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var all []serverLease
+	var errs []string
+	for _, s := range servers {
+		if s.err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(s *serverView) {
+			defer wg.Done()
+			leases, err := s.client.Leases(ctx).All()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, s.name+": "+err.Error())
+				return
+			}
+			for _, l := range leases {
+				all = append(all, serverLease{s, l})
+			}
+		}(s)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return all, errors.New(strings.Join(errs, "; "))
+	}
+	return all, nil
+}
+
+// Shows the leases of all servers, as AllLeases fetches them, in one
+// table with the server in the first column. Column 0 sorts by server, the others like the
+// lease table. Rows whose keys are in selected are highlighted.
+func AllLeasesTable(leases []serverLease, table *tview.Table, sortorder *[]SortData, selected map[string]bool) {
+	table.Clear()
+	table.SetCell(0, 0, tview.NewTableCell("Server").
+		SetTextColor(tcell.ColorYellow).
+		SetClickedFunc(func() bool {
+			(*sortorder)[0].Column = 0
+			(*sortorder)[0].Asc = !(*sortorder)[0].Asc
+			AllLeasesTable(leases, table, sortorder, selected)
+			return false
+		}))
+	for i, field := range shownLeaseFields() {
+		field := field
+		table.SetCell(0, i+1, tview.NewTableCell(leaseHeader[field]).
+			SetTextColor(tcell.ColorYellow).
+			SetClickedFunc(func() bool {
+				(*sortorder)[0].Column = field + 1
+				(*sortorder)[0].Asc = !(*sortorder)[0].Asc
+				AllLeasesTable(leases, table, sortorder, selected)
+				return false
+			}))
+	}
+	column := (*sortorder)[0].Column
+	sort.SliceStable(leases, func(i, j int) bool {
+		c := 0
+		if column == 0 {
+			c = cmp(leases[i].server.name, leases[j].server.name)
+		} else {
+			c = leases[i].Compare(&leases[j].Lease4, column-1)
+		}
+		if (*sortorder)[0].Asc {
+			return c < 0
+		}
+		return c > 0
+	})
+	for i, l := range leases {
+		reserved := false
+		for _, subnet := range l.server.subnets {
+			for _, r := range subnet.Reservations {
+				reserved = reserved || r.IpAddress == l.IpAddress
+			}
+		}
+		table.SetCell(i+1, 0, tview.NewTableCell(l.server.name).SetReference(l))
+		SetLeaseCells(table, i+1, 1, &leases[i].Lease4, reserved)
+	}
+	markSelected(table, selected, nil)
+	table.ScrollToBeginning()
+}
+
+// Shows the leases of all servers fetched by AllLeases, and the
+// servers that failed in the status line
+func (u *ui) showAllLeases(leases []serverLease, err error) {
+	u.table.SetTitle("All leases")
+	AllLeasesTable(leases, u.table, &u.allsort, u.allselected)
+	if err != nil {
+		u.statusline.SetText(err.Error())
+	}
+}
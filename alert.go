@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Background of the banner alerting of subnets running out of
+// addresses
+const alertColor = tcell.ColorDarkRed
+
+// Address counts of a subnet from the statistics of stat_cmds
+type SubnetStats struct {
+	Total    int64
+	Assigned int64
+	Declined int64
+}
+
+// Returns the number of addresses in the pools of the subnet that are
+// not leased
+func (s SubnetStats) Free() int64 {
+	return s.Total - s.Assigned
+}
+
+// Names of the subnet statistics SubnetStats is read from
+var subnetStatName = regexp.MustCompile(`^subnet\[(\d+)\]\.(total|assigned|declined)-addresses$`)
+
+// Returns the address counts of every subnet, by subnet ID
+func (c *Client) SubnetStats(ctx context.Context) (map[int]SubnetStats, error) {
+	resp, err := c.dhcp4(ctx, StatisticGetAllRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if err = resp.Err(); err != nil {
+		return nil, err
+	}
+	var all map[string][][]json.RawMessage
+	if err = resp.Decode(&all, false); err != nil {
+		return nil, err
+	}
+	stats := map[int]SubnetStats{}
+	for name, samples := range all {
+		m := subnetStatName.FindStringSubmatch(name)
+		if m == nil || len(samples) == 0 || len(samples[0]) == 0 {
+			continue
+		}
+		id, _ := strconv.Atoi(m[1])
+		// The latest sample comes first, as value and timestamp
+		value, err := strconv.ParseInt(string(samples[0][0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		s := stats[id]
+		switch m[2] {
+		case "total":
+			s.Total = value
+		case "assigned":
+			s.Assigned = value
+		case "declined":
+			s.Declined = value
+		}
+		stats[id] = s
+	}
+	return stats, nil
+}
+
+// PoolAlert is when subnets count as nearly exhausted: when they have
+// fewer free addresses than Free, or less than FreePercent percent of
+// their addresses free. Zero values do not alert.
+type PoolAlert struct {
+	Free        int64   `json:"free,omitempty"`
+	FreePercent float64 `json:"free-percent,omitempty"`
+}
+
+func (a PoolAlert) Enabled() bool {
+	return a.Free > 0 || a.FreePercent > 0
+}
+
+// Reports whether a subnet is nearly exhausted. Subnets without pools
+// never are.
+func (a PoolAlert) Exhausted(s SubnetStats) bool {
+	if s.Total <= 0 {
+		return false
+	}
+	return s.Free() < a.Free || float64(s.Free())*100 < a.FreePercent*float64(s.Total)
+}
+
+// DeclineAlert is when the declined addresses of a subnet count as
+// rising too fast: when they grew by more than PerMinute a minute
+// over the last Minutes minutes, 5 if not set. Addresses are declined
+// when a client finds them in use, which a rogue device answering ARP
+// for them causes.
+type DeclineAlert struct {
+	PerMinute float64 `json:"per-minute,omitempty"`
+	Minutes   int     `json:"minutes,omitempty"`
+}
+
+func (a DeclineAlert) Enabled() bool {
+	return a.PerMinute > 0
+}
+
+// Returns the time over which the rise of declines is measured
+func (a DeclineAlert) window() time.Duration {
+	return alertWindow(a.Minutes)
+}
+
+// D2Alert is when the DNS updates of kea-dhcp-ddns count as failing:
+// when more than Failures of them timed out or failed over the last
+// Minutes minutes, 5 if not set
+type D2Alert struct {
+	Failures int64 `json:"failures,omitempty"`
+	Minutes  int   `json:"minutes,omitempty"`
+}
+
+func (a D2Alert) Enabled() bool {
+	return a.Failures > 0
+}
+
+func (a D2Alert) window() time.Duration {
+	return alertWindow(a.Minutes)
+}
+
+// Returns a window of minutes over which a rise is measured, 5
+// minutes if not set
+func alertWindow(minutes int) time.Duration {
+	if minutes <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// A counter, like the declined addresses of a subnet, at a poll
+type countSample struct {
+	at    time.Time
+	count int64
+}
+
+// Adds a sample to those of a counter, dropping those before the
+// window that starts at start. The oldest sample kept is the last
+// one before the window.
+func addSample(samples []countSample, sample countSample, start time.Time) []countSample {
+	samples = append(samples, sample)
+	for len(samples) > 2 && !samples[1].at.After(start) {
+		samples = samples[1:]
+	}
+	return samples
+}
+
+// Reports whether the statistics are polled, which they are for the
+// alerts only
+func (c *Config) pollStats() bool {
+	return c.PoolAlert.Enabled() || c.DeclineAlert.Enabled()
+}
+
+// Takes the statistics of a poll of a server, keeping the declined
+// addresses of each subnet over the window of the decline alert, and
+// updates the alerts
+func (u *ui) applyStats(s *serverView, stats map[int]SubnetStats, at time.Time) {
+	s.stats = stats
+	if s.declines == nil {
+		s.declines = map[int][]countSample{}
+	}
+	start := at.Add(-u.config.DeclineAlert.window())
+	for id, st := range stats {
+		s.declines[id] = addSample(s.declines[id], countSample{at, st.Declined}, start)
+	}
+	u.updateAlert()
+}
+
+// Takes the failed DNS updates of kea-dhcp-ddns at a poll of a server
+// and updates the alerts. Polls that could not read them are skipped.
+func (u *ui) applyD2Stats(s *serverView, stats map[string]int64, at time.Time) {
+	if stats != nil {
+		start := at.Add(-u.config.D2Alert.window())
+		s.d2Failures = addSample(s.d2Failures, countSample{at, d2Failures(stats)}, start)
+	}
+	u.updateAlert()
+}
+
+// Returns the rise of the declined addresses of a subnet per minute
+// over the window of the decline alert, 0 before there are two polls
+func (s *serverView) declineRate(id int) float64 {
+	samples := s.declines[id]
+	if len(samples) < 2 {
+		return 0
+	}
+	first, last := samples[0], samples[len(samples)-1]
+	minutes := last.at.Sub(first.at).Minutes()
+	if minutes <= 0 {
+		return 0
+	}
+	return float64(last.count-first.count) / minutes
+}
+
+// A condition the banner alerts of, with the key it is dismissed by
+// and the event it fires when it comes up
+type alert struct {
+	key   string
+	text  string
+	event Event
+}
+
+// Returns the conditions of all servers to alert of
+func (u *ui) alerts() []alert {
+	var alerts []alert
+	for _, s := range u.servers {
+		for _, subnet := range s.subnets {
+			name := subnet.Subnet
+			if len(u.servers) > 1 {
+				name = s.name + " " + name
+			}
+			stats, ok := s.stats[subnet.Id]
+			if ok && u.config.PoolAlert.Enabled() && u.config.PoolAlert.Exhausted(stats) {
+				text := fmt.Sprintf("%s running out (%d of %d free)", name, stats.Free(), stats.Total)
+				alerts = append(alerts, alert{
+					fmt.Sprintf("pool %s %d", s.name, subnet.Id), text,
+					Event{Type: eventPoolExhausted, Server: s.name, Subnet: subnet.Subnet, Text: text}})
+			}
+			decline := u.config.DeclineAlert
+			if rate := s.declineRate(subnet.Id); decline.Enabled() && rate > decline.PerMinute {
+				text := fmt.Sprintf("%s declines rising (%.1f/min)", name, rate)
+				alerts = append(alerts, alert{
+					fmt.Sprintf("decline %s %d", s.name, subnet.Id), text,
+					Event{Type: eventDeclinesRising, Server: s.name, Subnet: subnet.Subnet, Text: text}})
+			}
+		}
+	}
+	for _, s := range u.servers {
+		samples := s.d2Failures
+		if !u.config.D2Alert.Enabled() || len(samples) < 2 {
+			continue
+		}
+		// A restart of kea-dhcp-ddns resets the counters, which does not alert
+		failures := samples[len(samples)-1].count - samples[0].count
+		if failures > u.config.D2Alert.Failures {
+			text := fmt.Sprintf("%s DNS updates failing (%d in %s)", s.name, failures,
+				samples[len(samples)-1].at.Sub(samples[0].at).Round(time.Minute))
+			alerts = append(alerts, alert{"d2 " + s.name, text,
+				Event{Type: eventDdnsFailing, Server: s.name, Text: text}})
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].text < alerts[j].text })
+	return alerts
+}
+
+// Shows the banner listing the alerts of all servers, unless they all
+// were dismissed, and fires the events of those that came up.
+// Conditions that ended are forgotten as dismissed, so they alert
+// again when they recur.
+func (u *ui) updateAlert() {
+	alerts := u.alerts()
+	alerting := map[string]bool{}
+	var texts []string
+	for _, a := range alerts {
+		alerting[a.key] = true
+		if !u.alerting[a.key] {
+			u.fireEvent(a.event)
+		}
+		if !u.dismissed[a.key] {
+			texts = append(texts, a.text)
+		}
+	}
+	u.alerting = alerting
+	for key := range u.dismissed {
+		if !alerting[key] {
+			delete(u.dismissed, key)
+		}
+	}
+	if len(texts) == 0 {
+		u.layout.ResizeItem(u.banner, 0, 0)
+		return
+	}
+	u.banner.SetText(" " + strings.Join(texts, ", ") + " (D dismisses)")
+	u.layout.ResizeItem(u.banner, 1, 0)
+}
+
+// Hides the banner until another alert comes up
+func (u *ui) dismissAlert() {
+	for _, a := range u.alerts() {
+		u.dismissed[a.key] = true
+	}
+	u.updateAlert()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Commands that may be sent to many servers at once, and whether they
+// change the servers and so need confirmation
+var broadcastCommands = map[string]bool{
+	"status-get":     false,
+	"version-get":    false,
+	"config-reload":  true,
+	"dhcp-disable":   true,
+	"dhcp-enable":    true,
+	"leases-reclaim": true,
+}
+
+type broadcastResult struct {
+	server *serverView
+	resp   *KeaResponse
+	err    error
+}
+
+// Sends a command to the dhcp4 service of every server in parallel
+// and returns the results in the order of the servers
+func broadcast(ctx context.Context, servers []*serverView, name, args string) []broadcastResult {
+	results := make([]broadcastResult, len(servers))
+	var wg sync.WaitGroup
+	for i, s := range servers {
+		wg.Add(1)
+		go func(i int, s *serverView) {
+			defer wg.Done()
+			results[i].server = s
+			reqctx, cancel := context.WithTimeout(ctx, requestTimeout)
+			defer cancel()
+			resps, err := s.client.Raw(reqctx, []string{"dhcp4"}, name, args)
+			if err == nil {
+				results[i].resp, err = resps.Get("dhcp4")
+			}
+			results[i].err = err
+		}(i, s)
+	}
+	wg.Wait()
+	return results
+}
+
+// Returns the servers a broadcast from the selected sidebar entry
+// goes to, and a description of them. A group or a server in a group
+// stands for the group, anything else for all open servers.
+func (u *ui) broadcastTargets() ([]*serverView, string) {
+	group := ""
+	if i := u.sidebar.GetCurrentItem(); i >= 0 && i < len(u.entries) {
+		group = u.entries[i].group
+		if s := u.entries[i].server; s != nil {
+			group = s.group
+		}
+	}
+	if group == "" {
+		return u.servers, "all servers"
+	}
+	var servers []*serverView
+	for _, s := range u.servers {
+		if s.group == group {
+			servers = append(servers, s)
+		}
+	}
+	return servers, "group " + group
+}
+
+// Runs :broadcast <command> [json arguments]
+func (u *ui) broadcastCommand(args string) {
+	name, args, _ := strings.Cut(strings.TrimSpace(args), " ")
+	changes, ok := broadcastCommands[name]
+	if !ok {
+		var names []string
+		for n := range broadcastCommands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		u.statusline.SetText("Usage: broadcast <" + strings.Join(names, " | ") + "> [json arguments]")
+		return
+	}
+	servers, targets := u.broadcastTargets()
+	run := func() {
+		results := broadcast(u.ctx, servers, name, strings.TrimSpace(args))
+		u.showTable(fmt.Sprintf("%s on %s", name, targets), broadcastTable(results))
+	}
+	if !changes {
+		run()
+		return
+	}
+	u.confirm(fmt.Sprintf("Send %s to %d servers (%s)?", name, len(servers), targets), run)
+}
+
+// Consolidates the results of a broadcast, one server per row
+func broadcastTable(results []broadcastResult) *tview.Table {
+	table := tview.NewTable().
+		SetSeparator(tview.Borders.Vertical).
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	for i, name := range []string{"Server", "Result", "Text", "Arguments"} {
+		table.SetCell(0, i, tview.NewTableCell(name).
+			SetTextColor(tcell.ColorYellow).
+			SetSelectable(false))
+	}
+	for i, r := range results {
+		table.SetCell(i+1, 0, tview.NewTableCell(r.server.name))
+		if r.err != nil {
+			table.SetCell(i+1, 1, tview.NewTableCell("error").SetTextColor(tcell.ColorRed))
+			table.SetCell(i+1, 2, tview.NewTableCell(r.err.Error()))
+			continue
+		}
+		color := tcell.ColorGreen
+		if r.resp.Err() != nil {
+			color = tcell.ColorRed
+		}
+		table.SetCell(i+1, 1, tview.NewTableCell(strconv.Itoa(r.resp.Result)).SetTextColor(color))
+		table.SetCell(i+1, 2, tview.NewTableCell(r.resp.Text))
+		var args bytes.Buffer
+		if len(r.resp.Arguments) > 0 && json.Compact(&args, r.resp.Arguments) == nil {
+			table.SetCell(i+1, 3, tview.NewTableCell(args.String()).SetMaxWidth(80))
+		}
+	}
+	return table
+}
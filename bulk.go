@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Width of the progress bar of bulk operations
+const progressWidth = 40
+
+// Number of changes the confirmation of a bulk operation lists
+const bulkPreview = 10
+
+// An operation on one row of a bulk operation
+type bulkItem struct {
+	// What the operation is on, for the report
+	label string
+	run   func(ctx context.Context) error
+	// Called on the UI goroutine after run succeeded, if set
+	after func()
+}
+
+// Minimum time between lease4-resend-ddns commands, so that D2 is not
+// flooded with updates
+var ddnsInterval = 100 * time.Millisecond
+
+// Returns the operations of a bulk operation on the leases in rows
+func (u *ui) bulkLeases(rows []int, run func(ctx context.Context, server *serverView, l *Lease4) error) []bulkItem {
+	var items []bulkItem
+	for _, row := range rows {
+		server, lease := u.rowLease(row)
+		if lease == nil {
+			continue
+		}
+		label := lease.IpAddress
+		if current, _ := u.current(); current == nil {
+			label = server.name + " " + label
+		}
+		items = append(items, bulkItem{label: label, run: func(ctx context.Context) error {
+			return run(ctx, server, lease)
+		}})
+	}
+	return items
+}
+
+// Runs the items of a bulk operation one after another in the
+// background, at most one per interval, while a popup shows the
+// progress. Failed items do not stop the others and are listed in a
+// report at the end. Escape stops the operation after the current
+// item. done is called on the UI goroutine when it ends. A dry run
+// sends only the commands that read, and shows the others at the end
+// instead of calling done.
+func (u *ui) runBulk(title string, items []bulkItem, interval time.Duration, dry bool, done func()) {
+	if dry {
+		title = "Dry run: " + title
+	}
+	ctx, cancel := context.WithCancel(u.ctx)
+	view := tview.NewTextView()
+	view.SetBorder(true)
+	view.SetTitle(title)
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			cancel()
+			view.SetTitle(title + " (stopping)")
+		}
+		return nil
+	})
+	progress := func(n, failed int) {
+		filled := progressWidth * n / len(items)
+		view.SetText(fmt.Sprintf("%s%s\n%d/%d, %d failed\n\nEscape stops",
+			strings.Repeat("█", filled), strings.Repeat("░", progressWidth-filled),
+			n, len(items), failed))
+	}
+	progress(0, 0)
+	u.prev = u.app.GetFocus()
+	u.pages.AddPage("progress", centered(view, progressWidth+2, 6), true, true)
+	u.app.SetFocus(view)
+	go func() {
+		defer cancel()
+		var failures []bulkItem
+		var errs []error
+		var planned []string
+		n := 0
+		var last time.Time
+		for _, item := range items {
+			if wait := interval - time.Since(last); wait > 0 {
+				select {
+				case <-ctx.Done():
+				case <-time.After(wait):
+				}
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			last = time.Now()
+			reqctx, cancelreq := context.WithTimeout(ctx, requestTimeout)
+			if dry {
+				label := item.label
+				reqctx = WithDryRun(reqctx, func(req *KeaRequest) {
+					planned = append(planned, label+": "+req.String())
+				})
+			}
+			err := item.run(reqctx)
+			cancelreq()
+			n++
+			if err != nil {
+				failures = append(failures, item)
+				errs = append(errs, err)
+			}
+			count, failed, after := n, len(failures), item.after
+			u.app.QueueUpdateDraw(func() {
+				if err == nil && after != nil && !dry {
+					after()
+				}
+				progress(count, failed)
+			})
+		}
+		u.app.QueueUpdateDraw(func() {
+			u.pages.RemovePage("progress")
+			u.app.SetFocus(u.prev)
+			summary := fmt.Sprintf("%s: %d of %d done, %d failed", title, n-len(failures), len(items), len(failures))
+			if n < len(items) {
+				summary += fmt.Sprintf(", %d not run", len(items)-n)
+			}
+			u.statusline.SetText(summary)
+			if dry {
+				for i, item := range failures {
+					planned = append(planned, item.label+": failed: "+errs[i].Error())
+				}
+				u.showText(summary, strings.Join(planned, "\n"))
+				return
+			}
+			if done != nil {
+				done()
+			}
+			if len(failures) > 0 {
+				u.showTable(summary, bulkReport(failures, errs))
+			}
+		})
+	}()
+}
+
+// Lists the failed items of a bulk operation and why they failed
+func bulkReport(failures []bulkItem, errs []error) *tview.Table {
+	table := tview.NewTable().
+		SetSeparator(tview.Borders.Vertical).
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	for i, name := range []string{"Item", "Error"} {
+		table.SetCell(0, i, tview.NewTableCell(name).
+			SetTextColor(tcell.ColorYellow).
+			SetSelectable(false))
+	}
+	for i, item := range failures {
+		table.SetCell(i+1, 0, tview.NewTableCell(item.label))
+		table.SetCell(i+1, 1, tview.NewTableCell(errs[i].Error()).SetTextColor(tcell.ColorRed))
+	}
+	return table
+}
+
+// Asks for confirmation of a bulk operation, which can be run dry to
+// see the commands it sends first. With -dry-run it can only be run
+// dry.
+func (u *ui) confirmBulk(text string, run func(dry bool)) {
+	buttons := []string{"Cancel", "Dry run", "OK"}
+	if dryRun {
+		buttons = buttons[:2]
+	}
+	u.ask(text, buttons, func(label string) {
+		if label == "Dry run" || label == "OK" {
+			run(label == "Dry run")
+		}
+	})
+}
+
+// Returns the text of a confirmation followed by the first lines of a
+// preview of what is going to change
+func previewText(text string, lines []string) string {
+	text += "\n"
+	for i, line := range lines {
+		if i == bulkPreview {
+			text += fmt.Sprintf("\nand %d more", len(lines)-bulkPreview)
+			break
+		}
+		text += "\n" + line
+	}
+	return text
+}
+
+// Deletes a lease, as an operation of a bulk operation
+func deleteLease(ctx context.Context, server *serverView, l *Lease4) error {
+	result, text, err := server.client.DelLease(ctx, l.IpAddress)
+	if err == nil && result != resultSuccess {
+		err = errors.New(text)
+	}
+	return err
+}
+
+// Deletes the leases in the selected rows after confirmation
+func (u *ui) bulkDelete() {
+	items := u.bulkLeases(u.selectedRows(), deleteLease)
+	if len(items) == 0 {
+		u.statusline.SetText("No leases selected")
+		return
+	}
+	u.prev = u.table
+	u.confirmBulk(fmt.Sprintf("Delete %d leases?", len(items)), func(dry bool) {
+		u.submit("Deleting leases", items, 0, dry, func() {
+			selected := u.selection()
+			for key := range selected {
+				delete(selected, key)
+			}
+			u.updateTable()
+		})
+	})
+}
+
+// Resends the DNS updates of the leases in the selected rows, or of
+// every lease shown if none is selected
+func (u *ui) bulkResendDdns() {
+	rows := u.selectedRows()
+	if len(rows) == 0 {
+		for row := 1; row < u.table.GetRowCount(); row++ {
+			rows = append(rows, row)
+		}
+	}
+	items := u.bulkLeases(rows, func(ctx context.Context, server *serverView, l *Lease4) error {
+		return server.client.ResendDdns(ctx, l.IpAddress)
+	})
+	if len(items) == 0 {
+		u.statusline.SetText("No leases shown")
+		return
+	}
+	u.prev = u.table
+	u.confirmBulk(fmt.Sprintf("Resend the DNS updates of %d leases?", len(items)), func(dry bool) {
+		u.runBulk("Resending DNS updates", items, ddnsInterval, dry, nil)
+	})
+}
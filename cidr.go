@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// Addresses of a subnet as derived from its prefix and pools
+type SubnetMath struct {
+	Network   net.IP
+	Broadcast net.IP
+	Netmask   net.IP
+	// Addresses hosts can have, all but the network and broadcast
+	// addresses except in /31 and /32 prefixes
+	Usable uint64
+	// Addresses in the prefix and in its pools, overlaps counted once
+	Size     uint64
+	PoolSize uint64
+	// Ranges of usable addresses outside of all pools
+	Gaps [][2]net.IP
+}
+
+// Computes the addresses of a subnet like 10.0.0.0/24 and its pools
+func NewSubnetMath(subnet string, pools []Pool) (*SubnetMath, error) {
+	_, ipnet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, err
+	}
+	network := ipnet.IP.To4()
+	if network == nil {
+		return nil, fmt.Errorf("%s is not an IPv4 subnet", subnet)
+	}
+	ones, bits := ipnet.Mask.Size()
+	size := uint64(1) << (bits - ones)
+	first := uint64(binary.BigEndian.Uint32(network))
+	last := first + size - 1
+	m := &SubnetMath{
+		Network:   network,
+		Broadcast: uint32IP(last),
+		Netmask:   net.IP(ipnet.Mask),
+		Size:      size,
+		Usable:    size,
+	}
+	if size > 2 {
+		m.Usable = size - 2
+		first, last = first+1, last-1
+	}
+	// Merges the pools into ranges that neither overlap nor touch
+	var ranges [][2]uint64
+	for i := range pools {
+		if from, to := pools[i].Range(); from != nil {
+			ranges = append(ranges, [2]uint64{
+				uint64(binary.BigEndian.Uint32(from)),
+				uint64(binary.BigEndian.Uint32(to))})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	var merged [][2]uint64
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r[0] <= merged[n-1][1]+1 {
+			if r[1] > merged[n-1][1] {
+				merged[n-1][1] = r[1]
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	next := first
+	for _, r := range merged {
+		m.PoolSize += r[1] - r[0] + 1
+		if r[0] > next && next <= last {
+			end := r[0] - 1
+			if end > last {
+				end = last
+			}
+			m.Gaps = append(m.Gaps, [2]net.IP{uint32IP(next), uint32IP(end)})
+		}
+		if r[1]+1 > next {
+			next = r[1] + 1
+		}
+	}
+	if next <= last {
+		m.Gaps = append(m.Gaps, [2]net.IP{uint32IP(next), uint32IP(last)})
+	}
+	return m, nil
+}
+
+// Returns the share of the prefix that the pools cover, in percent
+func (m *SubnetMath) Coverage() float64 {
+	return float64(m.PoolSize) * 100 / float64(m.Size)
+}
+
+func uint32IP(n uint64) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, uint32(n))
+	return ip
+}
+
+// Returns the number of addresses in a range of them
+func rangeSize(r [2]net.IP) uint32 {
+	return binary.BigEndian.Uint32(r[1].To4()) - binary.BigEndian.Uint32(r[0].To4()) + 1
+}
@@ -0,0 +1,617 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+// Layout of lease timestamps in the table and the CLI
+const timeFormat = "2006-01-02T15:04:05"
+
+// Exit codes of the command line mode
+const (
+	exitSuccess   = 0
+	exitKeaError  = 1
+	exitTransport = 2
+	exitEmpty     = 3
+)
+
+// Returned by subcommands that found nothing
+var errEmpty = errors.New("empty result")
+
+// Maps the outcome of a subcommand to its exit code. Errors that are
+// neither transport errors nor empty results, such as bad arguments,
+// are reported like Kea errors.
+func exitCode(err error) int {
+	var keaErr *KeaError
+	var transportErr *TransportError
+	switch {
+	case err == nil:
+		return exitSuccess
+	case errors.Is(err, errEmpty):
+		return exitEmpty
+	case errors.As(err, &keaErr) && keaErr.Result == resultEmpty:
+		return exitEmpty
+	case errors.As(err, &transportErr):
+		return exitTransport
+	}
+	return exitKeaError
+}
+
+// A subcommand of the headless command line mode
+type cliCommand struct {
+	usage string
+	run   func(ctx context.Context, client *Client, flags *flag.FlagSet, args []string) (*cliResult, error)
+}
+
+// Result of a subcommand, rendered according to -output. Tables and
+// CSV show the rows, JSON encodes the underlying records.
+type cliResult struct {
+	header  []string
+	rows    [][]string
+	records any
+}
+
+var outputFormats = []string{"table", "pretty", "markdown", "csv", "json"}
+
+func (r *cliResult) write(w io.Writer, format string) error {
+	switch format {
+	case "csv":
+		out := csv.NewWriter(w)
+		out.Write(r.header)
+		out.WriteAll(r.rows)
+		return out.Error()
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r.records)
+	case "pretty":
+		return printPretty(w, r.header, r.rows)
+	case "markdown":
+		return printMarkdown(w, r.header, r.rows)
+	}
+	return printTable(w, r.header, r.rows)
+}
+
+var cliCommands = map[string]cliCommand{
+	"leases":       {"leases <subnet>", cliLeases},
+	"reservations": {"reservations <subnet>", cliReservations},
+	"del-lease":    {"del-lease <ip>", cliDelLease},
+	"status":       {"status", cliStatus},
+	"get":          {"get <ip | mac | client-id | hostname>", cliGet},
+	"add-reservation": {"add-reservation [-hostname name] <subnet> <ip> <mac>",
+		cliAddReservation},
+	"watch":  {"watch [-subnet subnet] [-interval duration] [-renewals]", cliWatch},
+	"shell":  {"shell [-service services]", cliShell},
+	"doctor": {"doctor", cliDoctor},
+	"snapshot": {"snapshot [-dir directory] [-interval duration] [-subnet subnet] [-per-subnet]",
+		cliSnapshot},
+}
+
+func init() {
+	// batch runs the other commands, so it is added once the table
+	// exists to avoid an initialization cycle
+	cliCommands["batch"] = cliCommand{"batch <file | ->", cliBatch}
+}
+
+// Returns the flag set of a subcommand with the flags that every
+// subcommand has
+func newFlagSet(name string, cmd cliCommand) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [flags] %s\n", os.Args[0], cmd.usage)
+		flags.PrintDefaults()
+	}
+	flags.String("output", "table",
+		"output `format`: "+strings.Join(outputFormats, ", "))
+	return flags
+}
+
+// Runs a subcommand and returns the process exit code
+func runCLI(client *Client, name string, args []string) int {
+	cmd := cliCommands[name]
+	flags := newFlagSet(name, cmd)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if dryRun {
+		ctx = WithDryRun(ctx, func(req *KeaRequest) {
+			fmt.Fprintln(os.Stderr, "dry run:", req)
+		})
+	}
+	client.DetectVersion(ctx)
+	result, err := cmd.run(ctx, client, flags, args)
+	if result != nil {
+		output := flags.Lookup("output").Value.String()
+		if werr := result.write(os.Stdout, output); werr != nil && err == nil {
+			err = werr
+		}
+		if err == nil && result.rows == nil {
+			err = errEmpty
+		}
+	}
+	if err != nil && err != flag.ErrHelp && err != errEmpty {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return exitCode(err)
+}
+
+// Parses the flags of a subcommand and checks the output format and
+// the number of positional arguments. Without a client, as when only
+// the flags are wanted, nothing is left to run and the usage is shown.
+func parseArgs(client *Client, flags *flag.FlagSet, args []string, n int) ([]string, error) {
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if client == nil {
+		flags.Usage()
+		return nil, flag.ErrHelp
+	}
+	if output := flags.Lookup("output"); output != nil {
+		known := false
+		for _, f := range outputFormats {
+			known = known || f == output.Value.String()
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown output format %q", output.Value.String())
+		}
+	}
+	if flags.NArg() != n {
+		flags.Usage()
+		return nil, flag.ErrHelp
+	}
+	return flags.Args(), nil
+}
+
+// Finds a subnet by prefix or by ID
+func findSubnet(ctx context.Context, client *Client, arg string) (*Subnet4, error) {
+	subnets, err := client.GetSubnets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.Atoi(arg)
+	for i := range subnets {
+		if subnets[i].Subnet == arg || (err == nil && subnets[i].Id == id) {
+			return &subnets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("subnet %s not found", arg)
+}
+
+func printTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// Prints a table framed with ASCII borders, which survives being
+// pasted into places that do not keep tabs or use a monospace font
+// only inside code blocks
+func printPretty(w io.Writer, header []string, rows [][]string) error {
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			if i < len(widths) && utf8.RuneCountInString(cell) > widths[i] {
+				widths[i] = utf8.RuneCountInString(cell)
+			}
+		}
+	}
+	var rule strings.Builder
+	rule.WriteString("+")
+	for _, width := range widths {
+		rule.WriteString(strings.Repeat("-", width+2) + "+")
+	}
+	line := func(row []string) {
+		var b strings.Builder
+		b.WriteString("|")
+		for i, width := range widths {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			b.WriteString(" " + cell + strings.Repeat(" ", width-utf8.RuneCountInString(cell)) + " |")
+		}
+		fmt.Fprintln(w, b.String())
+	}
+	fmt.Fprintln(w, rule.String())
+	line(header)
+	fmt.Fprintln(w, rule.String())
+	for _, row := range rows {
+		line(row)
+	}
+	_, err := fmt.Fprintln(w, rule.String())
+	return err
+}
+
+// Prints a GitHub flavored markdown table
+func printMarkdown(w io.Writer, header []string, rows [][]string) error {
+	escape := strings.NewReplacer("|", "\\|", "\n", " ")
+	line := func(row []string) {
+		cells := make([]string, len(header))
+		for i := range cells {
+			if i < len(row) {
+				cells[i] = escape.Replace(row[i])
+			}
+		}
+		fmt.Fprintln(w, "| "+strings.Join(cells, " | ")+" |")
+	}
+	line(header)
+	rule := make([]string, len(header))
+	for i := range rule {
+		rule[i] = "---"
+	}
+	line(rule)
+	for _, row := range rows {
+		line(row)
+	}
+	return nil
+}
+
+func cliLeases(ctx context.Context, client *Client, flags *flag.FlagSet, args []string) (*cliResult, error) {
+	args, err := parseArgs(client, flags, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	subnet, err := findSubnet(ctx, client, args[0])
+	if err != nil {
+		return nil, err
+	}
+	leases, err := client.Leases(ctx, subnet.Id).All()
+	if err != nil {
+		return nil, err
+	}
+	return leasesResult(leases), nil
+}
+
+// Returns leases as the result of a subcommand
+func leasesResult(leases []Lease4) *cliResult {
+	if leases == nil {
+		leases = []Lease4{}
+	}
+	var rows [][]string
+	for _, l := range leases {
+		state, _ := LeaseState(l.State)
+		rows = append(rows, []string{
+			l.Hostname,
+			l.IpAddress,
+			l.HwAddress,
+			state,
+			time.Unix(l.Cltt, 0).Format(timeFormat),
+			l.ClientId,
+		})
+	}
+	return &cliResult{
+		header:  []string{"HOSTNAME", "IP", "MAC", "STATE", "TIMESTAMP", "CLIENT ID"},
+		rows:    rows,
+		records: leases,
+	}
+}
+
+func cliReservations(ctx context.Context, client *Client, flags *flag.FlagSet, args []string) (*cliResult, error) {
+	args, err := parseArgs(client, flags, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	subnet, err := findSubnet(ctx, client, args[0])
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	for _, r := range subnet.Reservations {
+		rows = append(rows, []string{
+			r.IpAddress,
+			r.HwAddress,
+			r.Hostname,
+			r.BootFileName,
+			r.NextServer,
+			r.ServerHostname,
+		})
+	}
+	return &cliResult{
+		header:  []string{"IP", "MAC", "HOSTNAME", "BOOTFILE", "NEXT SERVER", "SERVER HOSTNAME"},
+		rows:    rows,
+		records: append([]Reservation{}, subnet.Reservations...),
+	}, nil
+}
+
+func cliDelLease(ctx context.Context, client *Client, flags *flag.FlagSet, args []string) (*cliResult, error) {
+	args, err := parseArgs(client, flags, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(args[0]) == nil {
+		return nil, fmt.Errorf("%s is not an IP address", args[0])
+	}
+	result, text, err := client.DelLease(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if result != resultSuccess {
+		return nil, &KeaError{result, text}
+	}
+	return &cliResult{
+		header:  []string{"IP", "RESULT"},
+		rows:    [][]string{{args[0], text}},
+		records: map[string]string{"ip-address": args[0], "text": text},
+	}, nil
+}
+
+func cliStatus(ctx context.Context, client *Client, flags *flag.FlagSet, args []string) (*cliResult, error) {
+	if _, err := parseArgs(client, flags, args, 0); err != nil {
+		return nil, err
+	}
+	status, err := client.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	uptime := time.Duration(status.Uptime) * time.Second
+	reload := time.Duration(status.Reload) * time.Second
+	rows := [][]string{
+		{"Version", client.Compat.String()},
+		{"PID", strconv.Itoa(status.Pid)},
+		{"Uptime", uptime.String()},
+		{"Last reload", reload.String() + " ago"},
+		{"Multi-threading", strconv.FormatBool(status.MultiThreadingEnabled)},
+	}
+	for _, ha := range status.HighAvailability {
+		for _, name := range []string{"local", "remote"} {
+			server, ok := ha.Servers[name]
+			if !ok {
+				continue
+			}
+			rows = append(rows, []string{
+				"HA " + name,
+				fmt.Sprintf("%s %s %s", server.ServerName, server.Role, server.CurrentState()),
+			})
+		}
+	}
+	return &cliResult{
+		header:  []string{"FIELD", "VALUE"},
+		rows:    rows,
+		records: status,
+	}, nil
+}
+
+func cliGet(ctx context.Context, client *Client, flags *flag.FlagSet, args []string) (*cliResult, error) {
+	args, err := parseArgs(client, flags, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	leases, err := client.FindLeases(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	subnets, err := client.GetSubnets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := struct {
+		Leases       []Lease4      `json:"leases"`
+		Reservations []Reservation `json:"reservations"`
+	}{append([]Lease4{}, leases...), []Reservation{}}
+	var rows [][]string
+	for i := range leases {
+		if i > 0 {
+			rows = append(rows, []string{"", ""})
+		}
+		rows = append(rows, LeaseFields(&leases[i])...)
+	}
+	for _, subnet := range subnets {
+		for _, r := range subnet.Reservations {
+			match := r.IpAddress == args[0] || r.HwAddress == args[0] || r.Hostname == args[0]
+			for _, l := range leases {
+				match = match || r.IpAddress == l.IpAddress ||
+					(l.HwAddress != "" && r.HwAddress == l.HwAddress)
+			}
+			if !match {
+				continue
+			}
+			records.Reservations = append(records.Reservations, r)
+			rows = append(rows,
+				[]string{"", ""},
+				[]string{"Reservation", subnet.Subnet},
+				[]string{"IP address", r.IpAddress},
+				[]string{"MAC address", r.HwAddress},
+				[]string{"Hostname", r.Hostname})
+		}
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no lease or reservation found for %s: %w", args[0], errEmpty)
+	}
+	return &cliResult{
+		header:  []string{"FIELD", "VALUE"},
+		rows:    rows,
+		records: records,
+	}, nil
+}
+
+func cliAddReservation(ctx context.Context, client *Client, flags *flag.FlagSet, args []string) (*cliResult, error) {
+	hostname := flags.String("hostname", "", "`hostname` of the reservation")
+	args, err := parseArgs(client, flags, args, 3)
+	if err != nil {
+		return nil, err
+	}
+	subnet, err := findSubnet(ctx, client, args[0])
+	if err != nil {
+		return nil, err
+	}
+	r := Reservation{IpAddress: args[1], HwAddress: args[2], Hostname: *hostname}
+	if err = client.AddReservation(ctx, subnet.Id, r); err != nil {
+		return nil, err
+	}
+	return &cliResult{
+		header:  []string{"SUBNET", "IP", "MAC", "HOSTNAME"},
+		rows:    [][]string{{subnet.Subnet, r.IpAddress, r.HwAddress, r.Hostname}},
+		records: HostReservation{subnet.Id, r},
+	}, nil
+}
+
+type batchLine struct {
+	Line    int    `json:"line"`
+	Command string `json:"command"`
+	Ok      bool   `json:"ok"`
+	Message string `json:"message"`
+}
+
+type batchKey struct{}
+
+// Reports whether a subcommand runs as a line of a batch, where those
+// that would not return are refused
+func inBatch(ctx context.Context) bool {
+	return ctx.Value(batchKey{}) != nil
+}
+
+// Runs one subcommand per line of a file, continuing past failures.
+// Blank lines and lines starting with # are skipped. batch, shell and
+// watch, which would not return, are refused, and so is snapshot with
+// an interval.
+func cliBatch(ctx context.Context, client *Client, flags *flag.FlagSet, args []string) (*cliResult, error) {
+	args, err := parseArgs(client, flags, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, batchKey{}, true)
+	in := os.Stdin
+	if args[0] != "-" {
+		if in, err = os.Open(args[0]); err != nil {
+			return nil, err
+		}
+		defer in.Close()
+	}
+	var rows [][]string
+	lines := []batchLine{}
+	failed := 0
+	scanner := bufio.NewScanner(in)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		line := batchLine{Line: n, Command: text}
+		fields := strings.Fields(text)
+		cmd, ok := cliCommands[fields[0]]
+		switch {
+		case !ok:
+			line.Message = "unknown command " + fields[0]
+		case fields[0] == "batch" || fields[0] == "shell" || fields[0] == "watch":
+			line.Message = fields[0] + " cannot run in a batch"
+		default:
+			lineflags := newFlagSet(fields[0], cmd)
+			lineflags.SetOutput(io.Discard)
+			result, err := cmd.run(ctx, client, lineflags, fields[1:])
+			switch {
+			case err == flag.ErrHelp:
+				line.Message = "usage: " + cmd.usage
+			case err != nil:
+				line.Message = err.Error()
+			case result == nil:
+				// Commands like snapshot write files rather than rows
+				line.Ok = true
+			case len(result.rows) == 1:
+				line.Ok = true
+				line.Message = strings.Join(result.rows[0], " ")
+			default:
+				line.Ok = true
+				line.Message = fmt.Sprintf("%d rows", len(result.rows))
+			}
+		}
+		status := "ok"
+		if !line.Ok {
+			status = "FAILED"
+			failed++
+		}
+		lines = append(lines, line)
+		rows = append(rows, []string{strconv.Itoa(n), text, status, line.Message})
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "%d succeeded, %d failed\n", len(lines)-failed, failed)
+	result := &cliResult{
+		header:  []string{"LINE", "COMMAND", "STATUS", "MESSAGE"},
+		rows:    rows,
+		records: lines,
+	}
+	if failed > 0 {
+		return result, fmt.Errorf("%d of %d operations failed", failed, len(lines))
+	}
+	return result, nil
+}
+
+// Polls leases and logs every change until interrupted. Prints log
+// lines, or one JSON object per change with -output json.
+func cliWatch(ctx context.Context, client *Client, flags *flag.FlagSet, args []string) (*cliResult, error) {
+	subnetArg := flags.String("subnet", "", "only watch this `subnet`, by prefix or ID")
+	interval := flags.Duration("interval", 10*time.Second, "polling `interval`")
+	renewals := flags.Bool("renewals", false, "also log lease renewals")
+	if _, err := parseArgs(client, flags, args, 0); err != nil {
+		return nil, err
+	}
+	var subnets []int
+	if *subnetArg != "" {
+		subnet, err := findSubnet(ctx, client, *subnetArg)
+		if err != nil {
+			return nil, err
+		}
+		subnets = append(subnets, subnet.Id)
+	}
+	jsonLines := flags.Lookup("output").Value.String() == "json"
+	enc := json.NewEncoder(os.Stdout)
+	prev, err := client.Leases(ctx, subnets...).All()
+	if err != nil {
+		return nil, err
+	}
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-ticker.C:
+		}
+		cur, err := client.Leases(ctx, subnets...).All()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil
+			}
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		now := time.Now().Format(timeFormat)
+		for _, change := range DiffLeases(prev, cur) {
+			if change.Kind == LeaseRenewed && !*renewals {
+				continue
+			}
+			if jsonLines {
+				enc.Encode(struct {
+					Time string `json:"time"`
+					LeaseChange
+				}{now, change})
+				continue
+			}
+			l := change.Lease()
+			fmt.Printf("%s %-8s %-15s %-17s %s %s\n", now, change.Kind,
+				l.IpAddress, l.HwAddress, l.Hostname, change.Details())
+		}
+		prev = cur
+	}
+}
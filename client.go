@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type command string
+
+type KeaRequest struct {
+	Arguments Request  `json:"arguments,omitempty"`
+	Command   command  `json:"command"`
+	Service   []string `json:"service,omitempty"`
+}
+
+type KeaResponse struct {
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    int             `json:"result"`
+	Text      string          `json:"text,omitempty"`
+	// Service the response came from, matched by position against
+	// the service list of the request
+	Service string `json:"-"`
+}
+
+// Responses holds one response per addressed service, in the order
+// the services were listed in the request
+type Responses []KeaResponse
+
+const (
+	resultSuccess     = 0
+	resultError       = 1
+	resultUnsupported = 2
+	resultEmpty       = 3
+)
+
+// KeaError is a command that Kea rejected or failed to execute
+type KeaError struct {
+	Result int
+	Text   string
+}
+
+func (e *KeaError) Error() string {
+	return e.Text
+}
+
+// TransportError is a request that did not get a response from Kea
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// Returns the response text as an error for failed commands. Empty
+// results are not errors.
+func (r *KeaResponse) Err() error {
+	if r.Result == resultSuccess || r.Result == resultEmpty {
+		return nil
+	}
+	return &KeaError{r.Result, r.Text}
+}
+
+// Returns the response of the named service
+func (r Responses) Get(service string) (*KeaResponse, error) {
+	for i := range r {
+		if r[i].Service == service {
+			return &r[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no response from %s", service)
+}
+
+// Returns the errors of all failed services combined, prefixed with
+// the service names
+func (r Responses) Err() error {
+	var failed []string
+	for i := range r {
+		if err := r[i].Err(); err != nil {
+			failed = append(failed, r[i].Service+": "+err.Error())
+		}
+	}
+	if failed == nil {
+		return nil
+	}
+	return &KeaError{resultError, strings.Join(failed, "; ")}
+}
+
+// Client talks to the dhcp4 service through a Transport
+type Client struct {
+	Transport Transport
+	// Reject response fields that are not part of the model
+	Strict bool
+	// Refuse commands that change the server
+	ReadOnly bool
+	// Refuse commands that change the server unless the context
+	// records them for a dry run, see WithDryRun
+	DryRun bool
+	Compat Compat
+	// Upper bound for each request, on top of the deadline of the
+	// caller's context
+	Timeout time.Duration
+}
+
+func NewClient(transport Transport) *Client {
+	return &Client{Transport: transport, Timeout: requestTimeout, DryRun: dryRun}
+}
+
+// Returns a client for a server profile
+func NewProfileClient(p ServerProfile) (*Client, error) {
+	transport, err := NewProfileTransport(p)
+	if err != nil {
+		return nil, err
+	}
+	client := NewClient(transport)
+	client.ReadOnly = p.ReadOnly
+	return client, nil
+}
+
+// Returned for commands that would change a read-only server
+var ErrReadOnly = errors.New("server is read-only")
+
+// Returned for commands that would change a server during a dry run
+// outside of the operations that record them
+var ErrDryRun = errors.New("not sent in a dry run")
+
+// Reports whether a command only reads from the server. Kea names
+// those commands get, or get-something.
+func isReadCommand(c command) bool {
+	switch c {
+	case "list-commands", "build-report", "config-test", "ha-heartbeat", "gss-tsig-list", "cache-size":
+		return true
+	}
+	return strings.HasSuffix(string(c), "-get") || strings.Contains(string(c), "-get-")
+}
+
+// Sends a command to the dhcp4 service
+func (c *Client) Send(ctx context.Context, req Request) (Responses, error) {
+	return c.SendTo(ctx, []string{"dhcp4"}, req)
+}
+
+// Sends a command to the given services and returns their responses
+// tagged with the service names
+func (c *Client) SendTo(ctx context.Context, services []string, req Request) (Responses, error) {
+	if c.ReadOnly && !isReadCommand(req.Command()) {
+		return nil, fmt.Errorf("%s: %w", req.Command(), ErrReadOnly)
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	request := &KeaRequest{
+		Command:   req.Command(),
+		Arguments: req,
+		Service:   services}
+	if record := dryRunRecorder(ctx); record != nil && !isReadCommand(req.Command()) {
+		record(request)
+		var grades Responses
+		for _, service := range services {
+			grades = append(grades, KeaResponse{Result: resultSuccess, Text: "dry run", Service: service})
+		}
+		return grades, nil
+	}
+	if c.DryRun && !isReadCommand(req.Command()) {
+		return nil, fmt.Errorf("%s: %w", req.Command(), ErrDryRun)
+	}
+	grades, err := c.Transport.Do(ctx, request)
+	if err != nil {
+		return nil, &TransportError{err}
+	}
+	if len(grades) == 0 {
+		return nil, &TransportError{fmt.Errorf("%s: empty response", req.Command())}
+	}
+	for i := range grades {
+		if i < len(services) {
+			grades[i].Service = services[i]
+		}
+	}
+	return grades, nil
+}
+
+// Sends a command to the dhcp4 service and returns its response
+func (c *Client) dhcp4(ctx context.Context, req Request) (*KeaResponse, error) {
+	grades, err := c.Send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return grades.Get("dhcp4")
+}
+
+func (c *Client) GetSubnets(ctx context.Context) ([]Subnet4, error) {
+	config, err := c.dhcp4Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.subnetsOf(config)
+}
+
+// Returns the Dhcp4 configuration of the server by top-level key
+func (c *Client) dhcp4Config(ctx context.Context) (map[string]json.RawMessage, error) {
+	resp, err := c.dhcp4(ctx, ConfigGetRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if err = resp.Err(); err != nil {
+		return nil, err
+	}
+	var config ConfigGetResponse
+	if err = resp.Decode(&config, false); err != nil {
+		return nil, err
+	}
+	return config.Dhcp4, nil
+}
+
+// Returns the subnets of a Dhcp4 configuration
+func (c *Client) subnetsOf(config map[string]json.RawMessage) ([]Subnet4, error) {
+	var subnets []Subnet4
+	var err error
+	if raw, ok := config["subnet4"]; ok {
+		if err = decodeJSON(raw, &subnets, c.Strict); err != nil {
+			return nil, err
+		}
+	}
+	// The subnets of shared networks follow the others, grouped by
+	// network. Only the subnets of the networks are modeled.
+	var networks []map[string]json.RawMessage
+	if raw, ok := config["shared-networks"]; ok {
+		if err = json.Unmarshal(raw, &networks); err != nil {
+			return nil, fmt.Errorf("shared-networks: %w", err)
+		}
+	}
+	for _, network := range networks {
+		var name string
+		json.Unmarshal(network["name"], &name)
+		var members []Subnet4
+		if raw, ok := network["subnet4"]; ok {
+			if err = decodeJSON(raw, &members, c.Strict); err != nil {
+				return nil, fmt.Errorf("shared network %s: %w", name, err)
+			}
+		}
+		for i := range members {
+			members[i].SharedNetwork = name
+		}
+		subnets = append(subnets, members...)
+	}
+	for i := range subnets {
+		aliasRenamedParams(&subnets[i])
+	}
+	return subnets, nil
+}
+
+func (c *Client) DelLease(ctx context.Context, ip string) (int, string, error) {
+	resp, err := c.dhcp4(ctx, Lease4DelRequest{IpAddress: ip})
+	if err != nil {
+		return 0, "", err
+	}
+	return resp.Result, resp.Text, nil
+}
+
+// Replaces the reservation for the address of r. Servers without
+// reservation-update get it deleted and added again, and the old one
+// added back when adding the new one fails.
+func (c *Client) UpdateReservation(ctx context.Context, subnet int, r Reservation) error {
+	if c.Compat.Has(FeatureReservationUpdate) {
+		resp, err := c.dhcp4(ctx, ReservationUpdateRequest{HostReservation{subnet, r}})
+		if err != nil {
+			return err
+		}
+		return resp.Err()
+	}
+	resp, err := c.dhcp4(ctx, ReservationGetRequest{subnet, r.IpAddress})
+	if err != nil {
+		return err
+	}
+	if err = resp.Err(); err != nil {
+		return err
+	}
+	if resp.Result == resultEmpty {
+		return c.AddReservation(ctx, subnet, r)
+	}
+	var old HostReservation
+	if err = resp.Decode(&old, false); err != nil {
+		return err
+	}
+	if resp, err = c.dhcp4(ctx, ReservationDelRequest{subnet, r.IpAddress}); err != nil {
+		return err
+	}
+	if err = resp.Err(); err != nil {
+		return err
+	}
+	if err = c.AddReservation(ctx, subnet, r); err != nil {
+		if restoreErr := c.AddReservation(ctx, subnet, old.Reservation); restoreErr != nil {
+			return fmt.Errorf("%w; adding back the old reservation: %v", err, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Adds a lease with the address, client and subnet of l
+func (c *Client) AddLease(ctx context.Context, l *Lease4) error {
+	resp, err := c.dhcp4(ctx, Lease4AddRequest{
+		IpAddress: l.IpAddress,
+		HwAddress: l.HwAddress,
+		SubnetId:  l.SubnetId,
+		ClientId:  l.ClientId,
+		ValidLft:  l.ValidLft,
+		Hostname:  l.Hostname,
+	})
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
+// Asks the server to send the DNS updates of a lease to D2 again
+func (c *Client) ResendDdns(ctx context.Context, ip string) error {
+	resp, err := c.dhcp4(ctx, Lease4ResendDdnsRequest{IpAddress: ip})
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
+// Sends an arbitrary command to the given services. The arguments
+// must be a JSON object or empty.
+func (c *Client) Raw(ctx context.Context, services []string, name string, args string) (Responses, error) {
+	if args != "" && !json.Valid([]byte(args)) {
+		return nil, fmt.Errorf("arguments are not valid JSON")
+	}
+	return c.SendTo(ctx, services, RawRequest{Name: command(name), Arguments: json.RawMessage(args)})
+}
+
+// Top-level keys of the configurations of the services config-get
+// can be sent to
+var configSections = map[string]string{
+	"dhcp4":   "Dhcp4",
+	serviceD2: "DhcpDdns",
+}
+
+// Returns the configuration of a service as JSON, without the object
+// that wraps it
+func (c *Client) RawConfig(ctx context.Context, service string) (json.RawMessage, error) {
+	section, ok := configSections[service]
+	if !ok {
+		return nil, fmt.Errorf("no configuration known for service %s", service)
+	}
+	grades, err := c.SendTo(ctx, []string{service}, ConfigGetRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := grades.Get(service)
+	if err != nil {
+		return nil, err
+	}
+	if err = resp.Err(); err != nil {
+		return nil, err
+	}
+	var config map[string]json.RawMessage
+	if err = resp.Decode(&config, false); err != nil {
+		return nil, err
+	}
+	raw, ok := config[section]
+	if !ok {
+		return nil, fmt.Errorf("config-get of %s returned no %s", service, section)
+	}
+	return raw, nil
+}
+
+// Returns the commands a service supports, in sorted order
+func (c *Client) ListCommands(ctx context.Context, service string) ([]string, error) {
+	grades, err := c.SendTo(ctx, []string{service}, ListCommandsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := grades.Get(service)
+	if err != nil {
+		return nil, err
+	}
+	if err = resp.Err(); err != nil {
+		return nil, err
+	}
+	var commands []string
+	if err = resp.Decode(&commands, false); err != nil {
+		return nil, err
+	}
+	sort.Strings(commands)
+	return commands, nil
+}
+
+// Renders responses for display, one block per service with its
+// result, text and indented arguments
+func (r Responses) String() string {
+	var b strings.Builder
+	for _, resp := range r {
+		fmt.Fprintf(&b, "%s: result %d", resp.Service, resp.Result)
+		if resp.Text != "" {
+			fmt.Fprintf(&b, ", %s", resp.Text)
+		}
+		b.WriteString("\n")
+		if len(resp.Arguments) > 0 {
+			var args bytes.Buffer
+			if json.Indent(&args, resp.Arguments, "", "  ") == nil {
+				b.Write(args.Bytes())
+				b.WriteString("\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+func (c *Client) Status(ctx context.Context) (*KeaStatus, error) {
+	resp, err := c.dhcp4(ctx, StatusGetRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if err = resp.Err(); err != nil {
+		return nil, err
+	}
+	var status KeaStatus
+	if err = resp.Decode(&status, c.Strict); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Adds a reservation through host_cmds
+func (c *Client) AddReservation(ctx context.Context, subnet int, r Reservation) error {
+	resp, err := c.dhcp4(ctx, ReservationAddRequest{HostReservation{subnet, r}})
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
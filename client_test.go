@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Returns a client of a fake transport answering each command with
+// the responses given as the JSON the Control Agent sends
+func fakeClient(t *testing.T, responses map[command]string) (*Client, *FakeTransport) {
+	t.Helper()
+	transport := &FakeTransport{Responses: make(map[command][]KeaResponse)}
+	for name, data := range responses {
+		var grades []KeaResponse
+		if err := json.Unmarshal([]byte(data), &grades); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		transport.Responses[name] = grades
+	}
+	client := NewClient(transport)
+	client.DryRun = false
+	return client, transport
+}
+
+// Returns the commands a fake transport received, in order
+func sentCommands(transport *FakeTransport) []command {
+	var sent []command
+	for _, req := range transport.Requests {
+		sent = append(sent, req.Command)
+	}
+	return sent
+}
+
+func TestSendToServices(t *testing.T) {
+	client, transport := fakeClient(t, map[command]string{
+		"status-get": `[{"result":0,"arguments":{"pid":1}},{"result":0,"arguments":{"pid":2}}]`,
+	})
+	ctx := context.Background()
+	for _, services := range [][]string{{"dhcp4", "d2"}, {"d2", "dhcp4"}} {
+		grades, err := client.SendTo(ctx, services, StatusGetRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(grades) != 2 || grades[0].Service != services[0] || grades[1].Service != services[1] {
+			t.Errorf("responses %+v for %v", grades, services)
+		}
+	}
+	for _, grade := range transport.Responses["status-get"] {
+		if grade.Service != "" {
+			t.Errorf("canned response changed to service %s", grade.Service)
+		}
+	}
+	if len(transport.Requests) != 2 || !reflect.DeepEqual(transport.Requests[1].Service, []string{"d2", "dhcp4"}) {
+		t.Errorf("requests %+v", transport.Requests)
+	}
+}
+
+func TestSendUnsupported(t *testing.T) {
+	client, _ := fakeClient(t, nil)
+	resp, err := client.dhcp4(context.Background(), VersionGetRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result != resultUnsupported || resp.Err() == nil {
+		t.Errorf("response %+v", resp)
+	}
+}
+
+func TestSendRefused(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(c *Client)
+		want error
+	}{
+		{"read-only", func(c *Client) { c.ReadOnly = true }, ErrReadOnly},
+		{"dry run", func(c *Client) { c.DryRun = true }, ErrDryRun},
+	}
+	for _, test := range tests {
+		client, transport := fakeClient(t, map[command]string{
+			"lease4-del":     `[{"result":0,"text":"IPv4 lease deleted."}]`,
+			"lease4-get-all": `[{"result":3,"text":"0 IPv4 lease(s) found."}]`,
+		})
+		test.set(client)
+		ctx := context.Background()
+		if _, _, err := client.DelLease(ctx, "192.0.2.10"); !errors.Is(err, test.want) {
+			t.Errorf("%s: deleting got %v, want %v", test.name, err, test.want)
+		}
+		if _, err := client.Send(ctx, Lease4GetAllRequest{}); err != nil {
+			t.Errorf("%s: reading got %v", test.name, err)
+		}
+		if sent := sentCommands(transport); !reflect.DeepEqual(sent, []command{"lease4-get-all"}) {
+			t.Errorf("%s: sent %v", test.name, sent)
+		}
+	}
+}
+
+func TestSendDryRunRecorded(t *testing.T) {
+	client, transport := fakeClient(t, nil)
+	client.DryRun = true
+	var recorded []string
+	ctx := WithDryRun(context.Background(), func(req *KeaRequest) {
+		recorded = append(recorded, req.String())
+	})
+	code, _, err := client.DelLease(ctx, "192.0.2.10")
+	if err != nil || code != resultSuccess {
+		t.Fatalf("got %d, %v", code, err)
+	}
+	want := []string{`{"arguments":{"ip-address":"192.0.2.10"},"command":"lease4-del","service":["dhcp4"]}`}
+	if !reflect.DeepEqual(recorded, want) {
+		t.Errorf("recorded %v", recorded)
+	}
+	if len(transport.Requests) != 0 {
+		t.Errorf("sent %v", sentCommands(transport))
+	}
+}
+
+func TestDetectVersion(t *testing.T) {
+	client, _ := fakeClient(t, map[command]string{
+		"version-get": `[{"result":0,"text":"2.4.1","arguments":{"extended":"2.4.1 (isc20231123)"}}]`,
+	})
+	if err := client.DetectVersion(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if client.Compat.Version != (KeaVersion{2, 4, 1}) || !client.Compat.Has(FeatureLeasePaging) || client.Compat.Has(FeaturePoolId) {
+		t.Errorf("compat %+v", client.Compat)
+	}
+}
+
+func TestGetSubnets(t *testing.T) {
+	client, _ := fakeClient(t, map[command]string{
+		"config-get": `[{"result":0,"arguments":{"Dhcp4":{
+			"subnet4":[{"id":1,"subnet":"192.0.2.0/24","ddns-use-conflict-resolution":false}],
+			"shared-networks":[{"name":"office","subnet4":[{"id":2,"subnet":"198.51.100.0/24","ddns-conflict-resolution-mode":"check-exists-with-dhcid"}]}]}}}]`,
+	})
+	subnets, err := client.GetSubnets(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subnets) != 2 || subnets[0].Id != 1 || subnets[0].SharedNetwork != "" || subnets[1].Id != 2 || subnets[1].SharedNetwork != "office" {
+		t.Errorf("subnets %+v", subnets)
+	}
+	// Both names of renamed parameters are set, whichever was sent
+	if subnets[0].DdnsConflictResolutionMode != "no-check-with-dhcid" ||
+		subnets[1].DdnsUseConflictResolution == nil || !*subnets[1].DdnsUseConflictResolution {
+		t.Errorf("conflict resolution %+v", subnets)
+	}
+}
+
+func TestLeaseIterator(t *testing.T) {
+	page := `{"ip-address":"192.0.2.10","subnet-id":1}`
+	for i := 1; i < leasePageSize; i++ {
+		page += `,{"ip-address":"192.0.2.11","subnet-id":2}`
+	}
+	client, transport := fakeClient(t, map[command]string{
+		"lease4-get-page": `[{"result":0,"arguments":{"leases":[` + page + `]}}]`,
+		"lease4-get-all": `[{"result":0,"arguments":{"leases":[
+			{"ip-address":"192.0.2.10","subnet-id":1},
+			{"ip-address":"192.0.2.12","subnet-id":1}]}}]`,
+	})
+	ctx := context.Background()
+	leases, err := client.Leases(ctx, 1).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 2 || leases[0].IpAddress != "192.0.2.10" || leases[1].IpAddress != "192.0.2.12" {
+		t.Errorf("leases %+v", leases)
+	}
+	if len(transport.Requests) != 1 || !reflect.DeepEqual(transport.Requests[0].Arguments, Lease4GetAllRequest{Subnets: []int{1}}) {
+		t.Errorf("requests %+v", transport.Requests)
+	}
+
+	// A full page is followed by the next one, from its last address.
+	// The fake answers every page the same.
+	transport.Requests = nil
+	it := client.Leases(ctx)
+	for i := 0; i <= leasePageSize; i++ {
+		if !it.Next() {
+			t.Fatalf("lease %d: %v", i, it.Err())
+		}
+	}
+	want := []KeaRequest{
+		{Command: "lease4-get-page", Arguments: Lease4GetPageRequest{From: "start", Limit: leasePageSize}, Service: []string{"dhcp4"}},
+		{Command: "lease4-get-page", Arguments: Lease4GetPageRequest{From: "192.0.2.11", Limit: leasePageSize}, Service: []string{"dhcp4"}},
+	}
+	if !reflect.DeepEqual(transport.Requests, want) {
+		t.Errorf("requests %+v", transport.Requests)
+	}
+}
+
+func TestUpdateReservationRestores(t *testing.T) {
+	client, transport := fakeClient(t, map[command]string{
+		"version-get":     `[{"result":0,"text":"2.4.1"}]`,
+		"reservation-get": `[{"result":0,"text":"Host found.","arguments":{"subnet-id":1,"ip-address":"192.0.2.5","hw-address":"52:54:00:00:00:01","hostname":"printer"}}]`,
+		"reservation-del": `[{"result":0,"text":"Host deleted."}]`,
+		"reservation-add": `[{"result":1,"text":"Database duplicate entry error"}]`,
+	})
+	ctx := context.Background()
+	if err := client.DetectVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err := client.UpdateReservation(ctx, 1, Reservation{IpAddress: "192.0.2.5", HwAddress: "52:54:00:00:00:02"})
+	var keaErr *KeaError
+	if !errors.As(err, &keaErr) {
+		t.Fatalf("got %v", err)
+	}
+	want := []command{"version-get", "reservation-get", "reservation-del", "reservation-add", "reservation-add"}
+	if sent := sentCommands(transport); !reflect.DeepEqual(sent, want) {
+		t.Fatalf("sent %v", sent)
+	}
+	restored := transport.Requests[4].Arguments.(ReservationAddRequest).Reservation
+	if restored.HwAddress != "52:54:00:00:00:01" || restored.Hostname != "printer" {
+		t.Errorf("added back %+v", restored)
+	}
+}
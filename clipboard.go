@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+)
+
+// Copies text to the system clipboard with an OSC 52 escape sequence,
+// which the terminal emulator honours even over SSH. The sequence is
+// written to the controlling terminal so it never ends up in a pipe.
+func CopyToClipboard(text string) error {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if os.Getenv("TMUX") != "" {
+		// tmux only forwards escapes wrapped in a DCS passthrough
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	return writeTerminal(seq)
+}
+
+// Writes to the controlling terminal, which stays the terminal when
+// standard output goes to a pipe, as with -pick
+func writeTerminal(text string) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+	_, err = tty.WriteString(text)
+	return err
+}
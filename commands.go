@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Request is implemented by the arguments of every supported
+// command and names the command they belong to
+type Request interface {
+	Command() command
+}
+
+type ConfigGetRequest struct{}
+
+func (ConfigGetRequest) Command() command { return "config-get" }
+
+// Only the sections ybyra models are decoded from the configuration,
+// so they are kept raw until needed
+type ConfigGetResponse struct {
+	Dhcp4 map[string]json.RawMessage `json:"Dhcp4"`
+}
+
+type ListCommandsRequest struct{}
+
+func (ListCommandsRequest) Command() command { return "list-commands" }
+
+type StatusGetRequest struct{}
+
+func (StatusGetRequest) Command() command { return "status-get" }
+
+type StatisticGetAllRequest struct{}
+
+func (StatisticGetAllRequest) Command() command { return "statistic-get-all" }
+
+type Lease4GetAllRequest struct {
+	Subnets []int `json:"subnets,omitempty"`
+}
+
+func (Lease4GetAllRequest) Command() command { return "lease4-get-all" }
+
+type Lease4GetAllResponse struct {
+	Leases []Lease4 `json:"leases"`
+}
+
+type Lease4GetPageRequest struct {
+	From  string `json:"from"`
+	Limit int    `json:"limit"`
+}
+
+func (Lease4GetPageRequest) Command() command { return "lease4-get-page" }
+
+type Lease4GetPageResponse struct {
+	Count  int      `json:"count"`
+	Leases []Lease4 `json:"leases"`
+}
+
+type Lease4GetRequest struct {
+	IpAddress string `json:"ip-address"`
+}
+
+func (Lease4GetRequest) Command() command { return "lease4-get" }
+
+type Lease4GetByHwAddressRequest struct {
+	HwAddress string `json:"hw-address"`
+}
+
+func (Lease4GetByHwAddressRequest) Command() command { return "lease4-get-by-hw-address" }
+
+type Lease4GetByClientIdRequest struct {
+	ClientId string `json:"client-id"`
+}
+
+func (Lease4GetByClientIdRequest) Command() command { return "lease4-get-by-client-id" }
+
+type Lease4GetByHostnameRequest struct {
+	Hostname string `json:"hostname"`
+}
+
+func (Lease4GetByHostnameRequest) Command() command { return "lease4-get-by-hostname" }
+
+type Lease4DelRequest struct {
+	IpAddress string `json:"ip-address"`
+}
+
+func (Lease4DelRequest) Command() command { return "lease4-del" }
+
+type Lease4AddRequest struct {
+	IpAddress string `json:"ip-address"`
+	HwAddress string `json:"hw-address"`
+	SubnetId  int    `json:"subnet-id,omitempty"`
+	ClientId  string `json:"client-id,omitempty"`
+	ValidLft  int    `json:"valid-lft,omitempty"`
+	Hostname  string `json:"hostname,omitempty"`
+}
+
+func (Lease4AddRequest) Command() command { return "lease4-add" }
+
+type Lease4ResendDdnsRequest struct {
+	IpAddress string `json:"ip-address"`
+}
+
+func (Lease4ResendDdnsRequest) Command() command { return "lease4-resend-ddns" }
+
+type ReservationAddRequest struct {
+	Reservation HostReservation `json:"reservation"`
+}
+
+func (ReservationAddRequest) Command() command { return "reservation-add" }
+
+type ReservationUpdateRequest struct {
+	Reservation HostReservation `json:"reservation"`
+}
+
+func (ReservationUpdateRequest) Command() command { return "reservation-update" }
+
+type ReservationGetRequest struct {
+	SubnetId  int    `json:"subnet-id"`
+	IpAddress string `json:"ip-address"`
+}
+
+func (ReservationGetRequest) Command() command { return "reservation-get" }
+
+type ReservationDelRequest struct {
+	SubnetId  int    `json:"subnet-id"`
+	IpAddress string `json:"ip-address"`
+}
+
+func (ReservationDelRequest) Command() command { return "reservation-del" }
+
+type Subnet4GetRequest struct {
+	Id int `json:"id"`
+}
+
+func (Subnet4GetRequest) Command() command { return "subnet4-get" }
+
+// Subnets are sent whole, as subnet4-update replaces them
+type Subnet4UpdateRequest struct {
+	Subnets []json.RawMessage `json:"subnet4"`
+}
+
+func (Subnet4UpdateRequest) Command() command { return "subnet4-update" }
+
+// Commands of the host_cache hook
+type CacheGetRequest struct{}
+
+func (CacheGetRequest) Command() command { return "cache-get" }
+
+type CacheSizeRequest struct{}
+
+func (CacheSizeRequest) Command() command { return "cache-size" }
+
+type CacheClearRequest struct{}
+
+func (CacheClearRequest) Command() command { return "cache-clear" }
+
+// Removes the oldest Count entries. The arguments are the number
+// alone.
+type CacheFlushRequest struct {
+	Count int
+}
+
+func (CacheFlushRequest) Command() command { return "cache-flush" }
+
+func (r CacheFlushRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Count)
+}
+
+// A reservation together with the subnet it belongs to, as host_cmds
+// expects it
+type HostReservation struct {
+	SubnetId int `json:"subnet-id"`
+	Reservation
+}
+
+// RawRequest sends any command with arguments given as JSON, for
+// commands ybyra has no type for
+type RawRequest struct {
+	Name      command
+	Arguments json.RawMessage
+}
+
+func (r RawRequest) Command() command { return r.Name }
+
+func (r RawRequest) MarshalJSON() ([]byte, error) {
+	if len(r.Arguments) == 0 {
+		return []byte("{}"), nil
+	}
+	return r.Arguments, nil
+}
+
+// Decodes the arguments of a response into v. Responses without
+// arguments leave v untouched.
+func (r *KeaResponse) Decode(v any, strict bool) error {
+	if len(r.Arguments) == 0 {
+		return nil
+	}
+	return decodeJSON(r.Arguments, v, strict)
+}
+
+// Decodes data into v. In strict mode fields that v does not model
+// are an error, which makes schema changes between Kea versions
+// visible; otherwise they are ignored.
+func decodeJSON(data []byte, v any, strict bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+// Returns the JSON names of the fields of a struct type
+func jsonFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Commands of the gss_tsig hook of kea-dhcp-ddns
+type GssTsigGetAllRequest struct{}
+
+func (GssTsigGetAllRequest) Command() command { return "gss-tsig-get-all" }
+
+type GssTsigListRequest struct{}
+
+func (GssTsigListRequest) Command() command { return "gss-tsig-list" }
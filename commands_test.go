@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Reads a response recorded from Kea's control agent in testdata
+func loadFixture(t *testing.T, name string) KeaResponse {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var grades Responses
+	if err = json.Unmarshal(data, &grades); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if len(grades) != 1 {
+		t.Fatalf("%s: %d responses, want 1", name, len(grades))
+	}
+	return grades[0]
+}
+
+func TestRequestMarshal(t *testing.T) {
+	tests := []struct {
+		req  Request
+		want string
+	}{
+		{ConfigGetRequest{}, `{"arguments":{},"command":"config-get","service":["dhcp4"]}`},
+		{Lease4GetAllRequest{}, `{"arguments":{},"command":"lease4-get-all","service":["dhcp4"]}`},
+		{Lease4GetAllRequest{Subnets: []int{1, 2}}, `{"arguments":{"subnets":[1,2]},"command":"lease4-get-all","service":["dhcp4"]}`},
+		{Lease4GetPageRequest{From: "start", Limit: 100}, `{"arguments":{"from":"start","limit":100},"command":"lease4-get-page","service":["dhcp4"]}`},
+		{Lease4DelRequest{IpAddress: "192.0.2.10"}, `{"arguments":{"ip-address":"192.0.2.10"},"command":"lease4-del","service":["dhcp4"]}`},
+		{Lease4AddRequest{IpAddress: "192.0.2.10", HwAddress: "52:54:00:12:34:56"}, `{"arguments":{"ip-address":"192.0.2.10","hw-address":"52:54:00:12:34:56"},"command":"lease4-add","service":["dhcp4"]}`},
+		{Subnet4GetRequest{Id: 1}, `{"arguments":{"id":1},"command":"subnet4-get","service":["dhcp4"]}`},
+		{ReservationDelRequest{SubnetId: 1, IpAddress: "192.0.2.5"}, `{"arguments":{"subnet-id":1,"ip-address":"192.0.2.5"},"command":"reservation-del","service":["dhcp4"]}`},
+		{ReservationAddRequest{HostReservation{1, Reservation{HwAddress: "52:54:00:00:00:01", IpAddress: "192.0.2.5"}}}, `{"arguments":{"reservation":{"subnet-id":1,"hw-address":"52:54:00:00:00:01","ip-address":"192.0.2.5"}},"command":"reservation-add","service":["dhcp4"]}`},
+		{CacheFlushRequest{Count: 10}, `{"arguments":10,"command":"cache-flush","service":["dhcp4"]}`},
+		{RawRequest{Name: "version-get"}, `{"arguments":{},"command":"version-get","service":["dhcp4"]}`},
+	}
+	for _, test := range tests {
+		got, err := json.Marshal(&KeaRequest{Command: test.req.Command(), Arguments: test.req, Service: []string{"dhcp4"}})
+		if err != nil {
+			t.Errorf("%s: %v", test.req.Command(), err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s:\n got %s\nwant %s", test.req.Command(), got, test.want)
+		}
+	}
+}
+
+func TestDecodeFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		strict  bool
+		decoded func() any
+		check   func(t *testing.T, v any)
+	}{
+		{
+			fixture: "lease4-get-all.json",
+			strict:  true,
+			decoded: func() any { return &Lease4GetAllResponse{} },
+			check: func(t *testing.T, v any) {
+				leases := v.(*Lease4GetAllResponse).Leases
+				if len(leases) != 2 {
+					t.Fatalf("%d leases, want 2", len(leases))
+				}
+				l := leases[0]
+				if l.IpAddress != "192.0.2.10" || l.HwAddress != "52:54:00:12:34:56" || l.Hostname != "laptop.example.org." ||
+					l.SubnetId != 1 || l.ValidLft != 3600 || l.Cltt != 1700000000 || !l.FqdnFwd || l.State != stateDefault {
+					t.Errorf("lease %+v", l)
+				}
+				l = leases[1]
+				if l.State != stateDeclined || l.PoolId != 1 || len(l.UserContext) == 0 || l.Extra != nil {
+					t.Errorf("lease %+v", l)
+				}
+			},
+		},
+		{
+			// subnet4-get sends parameters Subnet4 does not model
+			fixture: "subnet4-get.json",
+			decoded: func() any {
+				return &struct {
+					Subnets []Subnet4 `json:"subnet4"`
+				}{}
+			},
+			check: func(t *testing.T, v any) {
+				subnets := v.(*struct {
+					Subnets []Subnet4 `json:"subnet4"`
+				}).Subnets
+				if len(subnets) != 1 {
+					t.Fatalf("%d subnets, want 1", len(subnets))
+				}
+				s := subnets[0]
+				if s.Id != 1 || s.Subnet != "192.0.2.0/24" || s.ValidLifetime != 3600 || s.T2Percent != 0.875 {
+					t.Errorf("subnet %+v", s)
+				}
+				if len(s.Pools) != 1 || s.Pools[0].Pool != "192.0.2.10-192.0.2.200" {
+					t.Errorf("pools %+v", s.Pools)
+				}
+				if len(s.OptionData) != 1 || s.OptionData[0].Name != "routers" || s.OptionData[0].Data != "192.0.2.1" {
+					t.Errorf("options %+v", s.OptionData)
+				}
+				if !reflect.DeepEqual(s.Relay.Addresses(), []string{"192.0.2.254"}) {
+					t.Errorf("relays %v", s.Relay.Addresses())
+				}
+				if len(s.Reservations) != 1 || s.Reservations[0].IpAddress != "192.0.2.5" || s.Reservations[0].Hostname != "printer" {
+					t.Errorf("reservations %+v", s.Reservations)
+				}
+			},
+		},
+		{
+			fixture: "reservation-get.json",
+			strict:  true,
+			decoded: func() any { return &HostReservation{} },
+			check: func(t *testing.T, v any) {
+				r := v.(*HostReservation)
+				if r.SubnetId != 1 || r.IpAddress != "192.0.2.5" || r.HwAddress != "52:54:00:00:00:01" ||
+					r.BootFileName != "pxelinux.0" || r.NextServer != "192.0.2.2" || len(r.ClientClasses) != 1 || len(r.OptionData) != 1 {
+					t.Errorf("reservation %+v", r)
+				}
+				var userContext map[string]string
+				if err := json.Unmarshal(r.UserContext, &userContext); err != nil || userContext["location"] != "2nd floor" {
+					t.Errorf("user context %s", r.UserContext)
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			resp := loadFixture(t, test.fixture)
+			if err := resp.Err(); err != nil {
+				t.Fatal(err)
+			}
+			v := test.decoded()
+			if err := resp.Decode(v, test.strict); err != nil {
+				t.Fatal(err)
+			}
+			test.check(t, v)
+
+			// Encoding and decoding again keeps everything
+			first, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again := test.decoded()
+			if err = decodeJSON(first, again, true); err != nil {
+				t.Fatal(err)
+			}
+			test.check(t, again)
+			second, err := json.Marshal(again)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(first, second) {
+				t.Errorf("round trip changed\n%s\nto\n%s", first, second)
+			}
+		})
+	}
+}
+
+func TestLease4Extra(t *testing.T) {
+	data := []byte(`{"ip-address":"192.0.2.10","hw-address":"52:54:00:12:34:56","subnet-id":1,"remote-id":"01:02","relay-id":"03:04"}`)
+	var lease Lease4
+	if err := json.Unmarshal(data, &lease); err != nil {
+		t.Fatal(err)
+	}
+	if len(lease.Extra) != 2 || string(lease.Extra["remote-id"]) != `"01:02"` {
+		t.Errorf("extra fields %v", lease.Extra)
+	}
+	if err := checkStrict([]Lease4{lease}, true); err == nil {
+		t.Error("unknown fields accepted in strict mode")
+	}
+	if err := checkStrict([]Lease4{lease}, false); err != nil {
+		t.Error(err)
+	}
+	encoded, err := json.Marshal(lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again Lease4
+	if err = json.Unmarshal(encoded, &again); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lease, again) {
+		t.Errorf("round trip changed %+v to %+v", lease, again)
+	}
+}
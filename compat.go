@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KeaVersion is the major.minor.patch version reported by version-get
+type KeaVersion struct {
+	Major, Minor, Patch int
+}
+
+// Parses versions like "2.4.1" or "2.5.0-git"
+func ParseKeaVersion(text string) (KeaVersion, error) {
+	var v KeaVersion
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return v, fmt.Errorf("unrecognized Kea version %q", text)
+	}
+	fields := strings.SplitN(words[0], ".", 3)
+	if len(fields) < 2 {
+		return v, fmt.Errorf("unrecognized Kea version %q", text)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, f := range fields {
+		// drop suffixes like "-git"
+		if end := strings.IndexFunc(f, func(r rune) bool { return r < '0' || r > '9' }); end >= 0 {
+			f = f[:end]
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return v, fmt.Errorf("unrecognized Kea version %q", text)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+func (v KeaVersion) Less(o KeaVersion) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+func (v KeaVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Feature is a capability that depends on the Kea version
+type Feature int
+
+const (
+	// lease4-get-page
+	FeatureLeasePaging Feature = iota
+	// lease4-get-by-hw-address, -client-id and -hostname
+	FeatureLeaseLookup
+	// reservations-global, -in-subnet and -out-of-pool replacing
+	// reservation-mode
+	FeatureReservationFlags
+	// multi-threading state in status-get
+	FeatureStatusMultiThreading
+	// pool-id on leases and per-pool statistics
+	FeaturePoolId
+	// reservation-update in host_cmds
+	FeatureReservationUpdate
+	// ddns-conflict-resolution-mode replacing
+	// ddns-use-conflict-resolution
+	FeatureConflictResolutionMode
+)
+
+// First stable release that supports each feature
+var featureSince = map[Feature]KeaVersion{
+	FeatureLeasePaging:            {1, 5, 0},
+	FeatureLeaseLookup:            {1, 7, 0},
+	FeatureReservationFlags:       {2, 0, 0},
+	FeatureStatusMultiThreading:   {2, 0, 0},
+	FeaturePoolId:                 {2, 6, 0},
+	FeatureReservationUpdate:      {2, 6, 0},
+	FeatureConflictResolutionMode: {2, 6, 0},
+}
+
+// Values of ddns-conflict-resolution-mode by the
+// ddns-use-conflict-resolution they stand for, as Kea converts them
+var conflictResolutionModes = map[bool]string{
+	true:  "check-with-dhcid",
+	false: "no-check-with-dhcid",
+}
+
+// Fills in the parameters of a subnet that Kea renamed from the name
+// the server sent, so that either name reads the same on every
+// version
+func aliasRenamedParams(s *Subnet4) {
+	switch {
+	case s.DdnsConflictResolutionMode == "" && s.DdnsUseConflictResolution != nil:
+		s.DdnsConflictResolutionMode = conflictResolutionModes[*s.DdnsUseConflictResolution]
+	case s.DdnsConflictResolutionMode != "" && s.DdnsUseConflictResolution == nil:
+		use := strings.HasPrefix(s.DdnsConflictResolutionMode, "check-")
+		s.DdnsUseConflictResolution = &use
+	}
+}
+
+// Compat holds the known differences between Kea versions so that
+// callers ask for features instead of comparing versions. The zero
+// value stands for an unknown version and assumes everything is
+// supported.
+type Compat struct {
+	Version KeaVersion
+	Known   bool
+}
+
+func (c Compat) Has(f Feature) bool {
+	since, ok := featureSince[f]
+	return !c.Known || !ok || !c.Version.Less(since)
+}
+
+func (c Compat) String() string {
+	if !c.Known {
+		return "Kea version unknown"
+	}
+	return "Kea " + c.Version.String()
+}
+
+type VersionGetRequest struct{}
+
+func (VersionGetRequest) Command() command { return "version-get" }
+
+// Queries the dhcp4 version and sets Compat accordingly. On failure
+// Compat is left unknown.
+func (c *Client) DetectVersion(ctx context.Context) error {
+	resp, err := c.dhcp4(ctx, VersionGetRequest{})
+	if err != nil {
+		return err
+	}
+	if err = resp.Err(); err != nil {
+		return err
+	}
+	v, err := ParseKeaVersion(resp.Text)
+	if err != nil {
+		return err
+	}
+	c.Compat = Compat{Version: v, Known: true}
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Upper bound for querying the server while completing, so that a
+// server that is down does not hang the shell
+const completionTimeout = 2 * time.Second
+
+// Completion scripts for the supported shells. They pass the words on
+// the command line to ybyra __complete and offer what it prints, so
+// all the completion logic lives in complete.
+var completionScripts = map[string]string{
+	"bash": `_ybyra() {
+	local IFS=$'\n'
+	COMPREPLY=($("${COMP_WORDS[0]}" __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _ybyra ybyra
+`,
+	"zsh": `#compdef ybyra
+_ybyra() {
+	local -a candidates
+	candidates=(${(f)"$("${words[1]}" __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+	compadd -a candidates
+}
+compdef _ybyra ybyra
+`,
+	"fish": `function __ybyra_complete
+	set -l words (commandline -opc)
+	$words[1] __complete $words[2..-1] (commandline -ct) 2>/dev/null
+end
+complete -c ybyra -f -a '(__ybyra_complete)'
+`,
+}
+
+// Commands whose first argument is a subnet
+var subnetArgs = map[string]bool{
+	"leases":          true,
+	"reservations":    true,
+	"add-reservation": true,
+}
+
+// Writes the completion script for a shell
+func printCompletion(w io.Writer, args []string) error {
+	if len(args) == 1 {
+		if script, ok := completionScripts[args[0]]; ok {
+			_, err := io.WriteString(w, script)
+			return err
+		}
+	}
+	return fmt.Errorf("usage: completion bash | zsh | fish")
+}
+
+// Writes the candidates for the last of words, which are the
+// arguments typed so far ending with the word under the cursor
+func complete(w io.Writer, words []string) error {
+	cur := ""
+	if len(words) > 0 {
+		cur = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+	values, rest, pending := scanFlags(flag.CommandLine, words)
+	configPath := defaultConfigPath()
+	if path, ok := values["config"]; ok {
+		configPath = path
+	}
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	server := flag.Lookup("server").DefValue
+	if s, ok := values["server"]; ok {
+		server = s
+	}
+	subnets := func() []string {
+		client, err := NewProfileClient(config.Profile(server))
+		if err != nil {
+			return nil
+		}
+		client.Timeout = completionTimeout
+		list, err := client.GetSubnets(context.Background())
+		if err != nil {
+			return nil
+		}
+		var prefixes []string
+		for _, s := range list {
+			prefixes = append(prefixes, s.Subnet)
+		}
+		return prefixes
+	}
+	var candidates []string
+	switch {
+	case pending == "server":
+		candidates = config.ServerNames()
+	case pending != "":
+	case len(rest) == 0 && strings.HasPrefix(cur, "-"):
+		candidates = flagNames(flag.CommandLine)
+	case len(rest) == 0:
+		candidates = append(candidates, "completion")
+		for name := range cliCommands {
+			candidates = append(candidates, name)
+		}
+		sort.Strings(candidates)
+		candidates = append(candidates, config.ServerNames()...)
+	case rest[0] == "completion":
+		if len(rest) == 1 {
+			for shell := range completionScripts {
+				candidates = append(candidates, shell)
+			}
+			sort.Strings(candidates)
+		}
+	default:
+		cmd, ok := cliCommands[rest[0]]
+		if !ok {
+			break
+		}
+		flags := commandFlags(rest[0], cmd)
+		_, args, pending := scanFlags(flags, rest[1:])
+		switch {
+		case pending == "output":
+			candidates = outputFormats
+		case pending == "subnet":
+			candidates = subnets()
+		case pending != "":
+		case strings.HasPrefix(cur, "-"):
+			candidates = flagNames(flags)
+		case len(args) == 0 && subnetArgs[rest[0]]:
+			candidates = subnets()
+		}
+	}
+	for _, c := range candidates {
+		if strings.HasPrefix(c, cur) {
+			fmt.Fprintln(w, c)
+		}
+	}
+	return nil
+}
+
+// Returns the flag set of a subcommand with its own flags declared.
+// Subcommands declare their flags when they run and parse them before
+// anything else, so running them with -h only declares them.
+func commandFlags(name string, cmd cliCommand) *flag.FlagSet {
+	flags := newFlagSet(name, cmd)
+	flags.SetOutput(io.Discard)
+	cmd.run(context.Background(), nil, flags, []string{"-h"})
+	return flags
+}
+
+// Splits words into the values of the flags of fs and the remaining
+// arguments. If the words end with a flag that still expects its
+// value, that flag is returned as pending.
+func scanFlags(fs *flag.FlagSet, words []string) (values map[string]string, rest []string, pending string) {
+	values = map[string]string{}
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if word == "--" {
+			return values, words[i+1:], ""
+		}
+		if !strings.HasPrefix(word, "-") || word == "-" {
+			return values, words[i:], ""
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(word, "-"), "=")
+		if f := fs.Lookup(name); f == nil || hasValue || isBoolFlag(f) {
+			values[name] = value
+			continue
+		}
+		if i+1 == len(words) {
+			return values, nil, name
+		}
+		i++
+		values[name] = words[i]
+	}
+	return values, nil, ""
+}
+
+func flagNames(fs *flag.FlagSet) []string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+	return names
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
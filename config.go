@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Config is read from ybyra/config.json in the user configuration
+// directory and names the servers ybyra talks to, so that -server and
+// the positional address accept a profile name instead of an address.
+// Servers can belong to a group, whose profile holds the defaults for
+// its members, and can be discovered instead of listed.
+type Config struct {
+	Servers   map[string]ServerProfile `json:"servers"`
+	Groups    map[string]ServerProfile `json:"groups"`
+	Discovery []Discovery              `json:"discovery"`
+	// Show expired-reclaimed leases, which are hidden unless x
+	// toggles them
+	ShowReclaimed bool `json:"show-reclaimed"`
+	// Queue changes for review instead of running them at once,
+	// until :queue switches
+	QueueChanges bool `json:"queue-changes"`
+	// Leave the mouse to the terminal, which then selects text by
+	// dragging, until :mouse switches
+	DisableMouse bool `json:"disable-mouse"`
+	// When to alert of subnets running out of addresses
+	PoolAlert PoolAlert `json:"pool-alert"`
+	// When to alert of declined addresses rising quickly
+	DeclineAlert DeclineAlert `json:"decline-alert"`
+	// When to alert of DNS updates of kea-dhcp-ddns failing
+	D2Alert D2Alert `json:"d2-alert"`
+	// Commands and URLs run on events of the TUI
+	Hooks []Hook `json:"hooks"`
+	// How to draw attention to alerts: "bell" rings the terminal
+	// bell, "osc9" sends a desktop notification through the terminal
+	Notify string `json:"notify"`
+	// Lease filter of the new leases to notify of, like mac=aa:bb:..
+	NotifyLeases string `json:"notify-leases"`
+	// OUI database of MAC address vendors, see LoadOUI, and whether
+	// the lease tables show the vendors from the start
+	OUIFile    string `json:"oui-file"`
+	ShowVendor bool   `json:"show-vendor"`
+	// Path in the user context of leases, like ddns.result, where a
+	// hook records the outcome of their DNS updates, shown in a DNS
+	// status column of the lease tables when set
+	DdnsStatusKey string `json:"ddns-status-key"`
+	// Routers and switches whose ARP and MAC tables annotate leases
+	SNMP []SNMPDevice `json:"snmp"`
+	// External commands launched on a lease with !
+	Tools []Tool `json:"tools"`
+	// Named lease filter expressions
+	Filters map[string]string `json:"filters"`
+	// File the configuration was read from
+	path string
+}
+
+type ServerProfile struct {
+	Address string `json:"address"`
+	// Basic authentication for the Control Agent
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	// CA certificate to verify the Control Agent with instead of the
+	// system roots, and the client certificate for agents that
+	// require one
+	CAFile   string `json:"ca-file,omitempty"`
+	CertFile string `json:"cert-file,omitempty"`
+	KeyFile  string `json:"key-file,omitempty"`
+	// Skip verifying the certificate of the Control Agent
+	Insecure bool `json:"insecure,omitempty"`
+	// Refuse commands that change the server
+	ReadOnly bool   `json:"read-only,omitempty"`
+	Group    string `json:"group,omitempty"`
+}
+
+// Fills the settings p leaves empty from the defaults of a group.
+// Flags set in either are set.
+func (p ServerProfile) withDefaults(d ServerProfile) ServerProfile {
+	for _, f := range []struct{ v, d *string }{
+		{&p.User, &d.User},
+		{&p.Password, &d.Password},
+		{&p.CAFile, &d.CAFile},
+		{&p.CertFile, &d.CertFile},
+		{&p.KeyFile, &d.KeyFile},
+	} {
+		if *f.v == "" {
+			*f.v = *f.d
+		}
+	}
+	p.Insecure = p.Insecure || d.Insecure
+	p.ReadOnly = p.ReadOnly || d.ReadOnly
+	return p
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ybyra", "config.json")
+}
+
+// Reads the configuration file. A missing file is an empty
+// configuration.
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{path: path}
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// Tools are picked by name
+	tools := map[string]bool{}
+	for _, t := range config.Tools {
+		if tools[t.Name] {
+			return nil, fmt.Errorf("%s: tool %q is defined twice", path, t.Name)
+		}
+		tools[t.Name] = true
+	}
+	return config, nil
+}
+
+// Names a filter expression and saves it to the configuration file.
+// Only the filters of the file are rewritten, as the configuration
+// holds discovered servers that are not part of it.
+func (c *Config) SaveFilter(name, filter string) error {
+	if c.path == "" {
+		return errors.New("no configuration file")
+	}
+	file := map[string]json.RawMessage{}
+	data, err := os.ReadFile(c.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		err = os.MkdirAll(filepath.Dir(c.path), 0o700)
+	case err == nil:
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.path, err)
+	}
+	filters := map[string]string{}
+	for n, f := range c.Filters {
+		filters[n] = f
+	}
+	filters[name] = filter
+	if file["filters"], err = json.Marshal(filters); err != nil {
+		return err
+	}
+	if data, err = json.MarshalIndent(file, "", "  "); err != nil {
+		return err
+	}
+	if err = os.WriteFile(c.path, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	c.Filters = filters
+	return nil
+}
+
+// Returns the names of the saved filters in sorted order
+func (c *Config) FilterNames() []string {
+	var names []string
+	for name := range c.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the named server profile with the defaults of its group,
+// or a profile with the argument as its address if there is none of
+// that name
+func (c *Config) Profile(server string) ServerProfile {
+	p, ok := c.Servers[server]
+	if !ok {
+		return ServerProfile{Address: server}
+	}
+	if g, ok := c.Groups[p.Group]; ok && p.Group != "" {
+		p = p.withDefaults(g)
+	}
+	return p
+}
+
+// Returns the names of the profiles in a group in sorted order
+func (c *Config) GroupServers(group string) []string {
+	var names []string
+	for _, name := range c.ServerNames() {
+		if c.Servers[name].Group == group {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Returns the profile names in sorted order
+func (c *Config) ServerNames() []string {
+	var names []string
+	for name := range c.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// A setting that differs between two configurations. Old or New is
+// empty when the setting is missing on that side, otherwise it holds
+// the value as JSON.
+type ConfigDiff struct {
+	Path string `json:"path"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// Keys that identify the elements of configuration lists, in order of
+// preference. Lists whose elements all have one of them are compared
+// element by element regardless of their order.
+var configKeys = []string{"subnet", "name", "pool", "ip-address", "hw-address", "library", "id", "code"}
+
+// Returns the Dhcp4 configuration of the server
+func (c *Client) GetConfig(ctx context.Context) (map[string]any, error) {
+	resp, err := c.dhcp4(ctx, ConfigGetRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if err = resp.Err(); err != nil {
+		return nil, err
+	}
+	var config struct {
+		Dhcp4 map[string]any `json:"Dhcp4"`
+	}
+	if err = resp.Decode(&config, false); err != nil {
+		return nil, err
+	}
+	return config.Dhcp4, nil
+}
+
+// Compares two configurations and returns the differences, in the
+// order of the keys of objects and of the elements of lists
+func DiffConfigs(a, b map[string]any) []ConfigDiff {
+	var diffs []ConfigDiff
+	diffValues("", a, b, &diffs)
+	return diffs
+}
+
+func diffValues(path string, a, b any, diffs *[]ConfigDiff) {
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		var keys []string
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			diffMember(joinPath(path, k), av, bv, k, diffs)
+		}
+		return
+	case []any:
+		bv, ok := b.([]any)
+		if !ok {
+			break
+		}
+		if key := listKey(av, bv); key != "" {
+			am, bm := keyedElements(av, key), keyedElements(bv, key)
+			var ids []string
+			seen := map[string]bool{}
+			for _, e := range append(append([]any{}, av...), bv...) {
+				id := fmt.Sprint(e.(map[string]any)[key])
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+			for _, id := range ids {
+				diffMember(fmt.Sprintf("%s[%s]", path, id), am, bm, id, diffs)
+			}
+			return
+		}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(bv):
+				*diffs = append(*diffs, ConfigDiff{p, configValue(av[i]), ""})
+			case i >= len(av):
+				*diffs = append(*diffs, ConfigDiff{p, "", configValue(bv[i])})
+			default:
+				diffValues(p, av[i], bv[i], diffs)
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		*diffs = append(*diffs, ConfigDiff{path, configValue(a), configValue(b)})
+	}
+}
+
+// Compares the member k of two maps, either of which may lack it
+func diffMember(path string, a, b map[string]any, k string, diffs *[]ConfigDiff) {
+	va, oka := a[k]
+	vb, okb := b[k]
+	switch {
+	case !okb:
+		*diffs = append(*diffs, ConfigDiff{path, configValue(va), ""})
+	case !oka:
+		*diffs = append(*diffs, ConfigDiff{path, "", configValue(vb)})
+	default:
+		diffValues(path, va, vb, diffs)
+	}
+}
+
+// Returns the key that identifies every element of both lists, or
+// nothing if the lists are not lists of objects with such a key
+func listKey(a, b []any) string {
+	all := append(append([]any{}, a...), b...)
+	if len(all) == 0 {
+		return ""
+	}
+	for _, key := range configKeys {
+		ok := true
+		for _, e := range all {
+			m, isMap := e.(map[string]any)
+			if !isMap {
+				return ""
+			}
+			if _, has := m[key]; !has {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return key
+		}
+	}
+	return ""
+}
+
+func keyedElements(list []any, key string) map[string]any {
+	m := make(map[string]any, len(list))
+	for _, e := range list {
+		m[fmt.Sprint(e.(map[string]any)[key])] = e
+	}
+	return m
+}
+
+func joinPath(path, k string) string {
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
+
+func configValue(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
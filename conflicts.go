@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Color of the IP addresses that were leased to several MAC addresses
+// or are reserved for another one
+const conflictColor = tcell.ColorFuchsia
+
+// MAC addresses remembered per IP address, the oldest are forgotten
+const macHistorySize = 5
+
+// A MAC address an IP address was leased to, and when
+type macSeen struct {
+	mac   string
+	first time.Time
+	last  time.Time
+}
+
+// Remembers the MAC address of each lease of a fetch, so that an
+// address leased to another MAC address later is found
+func (v *viewState) recordMACs(leases []Lease4, now time.Time) {
+	if v.macs == nil {
+		v.macs = map[string][]macSeen{}
+	}
+	for _, l := range leases {
+		if l.HwAddress == "" {
+			continue
+		}
+		seen := v.macs[l.IpAddress]
+		found := false
+		for i := range seen {
+			if strings.EqualFold(seen[i].mac, l.HwAddress) {
+				seen[i].last, found = now, true
+			}
+		}
+		if !found {
+			seen = append(seen, macSeen{l.HwAddress, now, now})
+			if len(seen) > macHistorySize {
+				seen = seen[1:]
+			}
+		}
+		v.macs[l.IpAddress] = seen
+	}
+}
+
+// Returns why a lease conflicts, or nothing: when its address was
+// leased to another MAC address before, is reserved for another, or
+// answers ARP from another
+func (v *viewState) macConflict(l *Lease4, reservation *Reservation) string {
+	if mac := arpMismatch(l); mac != "" {
+		return "answers from " + mac
+	}
+	if reservation != nil && reservation.HwAddress != "" && l.HwAddress != "" &&
+		!strings.EqualFold(reservation.HwAddress, l.HwAddress) {
+		return "reserved for " + reservation.HwAddress
+	}
+	var others []string
+	for _, seen := range v.macs[l.IpAddress] {
+		if !strings.EqualFold(seen.mac, l.HwAddress) {
+			others = append(others, seen.mac)
+		}
+	}
+	if len(others) > 0 {
+		return "also leased to " + strings.Join(others, ", ")
+	}
+	return ""
+}
+
+// Lists the addresses of all servers that were leased to several MAC
+// addresses since ybyra started, and the leases whose addresses are
+// reserved for other MAC addresses
+func (u *ui) showConflicts() {
+	table := tview.NewTable().
+		SetSeparator(tview.Borders.Vertical).
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	for i, name := range []string{"Server", "IP", "MAC", "First seen", "Last seen"} {
+		table.SetCell(0, i, tview.NewTableCell(name).
+			SetTextColor(tcell.ColorYellow).
+			SetSelectable(false))
+	}
+	row := 1
+	add := func(server, ip, mac, first, last string, color tcell.Color) {
+		for i, text := range []string{server, ip, mac, first, last} {
+			table.SetCell(row, i, tview.NewTableCell(text).SetTextColor(color))
+		}
+		row++
+	}
+	for _, s := range u.servers {
+		var ips []string
+		for ip, seen := range s.view.macs {
+			if len(seen) > 1 {
+				ips = append(ips, ip)
+			}
+		}
+		sort.Strings(ips)
+		for _, ip := range ips {
+			for _, seen := range s.view.macs[ip] {
+				add(s.name, ip, seen.mac, seen.first.Format(timeFormat), seen.last.Format(timeFormat), tcell.ColorDefault)
+			}
+		}
+		for _, subnet := range s.subnets {
+			for _, r := range subnet.Reservations {
+				seen := s.view.macs[r.IpAddress]
+				if len(seen) == 0 || r.HwAddress == "" ||
+					strings.EqualFold(seen[len(seen)-1].mac, r.HwAddress) {
+					continue
+				}
+				last := seen[len(seen)-1]
+				add(s.name, r.IpAddress, last.mac, last.first.Format(timeFormat), last.last.Format(timeFormat), tcell.ColorDefault)
+				add(s.name, r.IpAddress, r.HwAddress+" (reserved)", "", "", conflictColor)
+			}
+		}
+	}
+	if row == 1 {
+		u.statusline.SetText("No address conflicts seen")
+		return
+	}
+	u.prev = u.app.GetFocus()
+	u.showTable(fmt.Sprintf("Address conflicts (%d rows)", row-1), table)
+}
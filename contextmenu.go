@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Size of the context menu of lease rows
+const (
+	contextMenuWidth  = 16
+	contextMenuHeight = 8
+)
+
+// Switches the mouse handling of the TUI on or off. Off, the terminal
+// gets the mouse back for selecting text.
+func (u *ui) setMouse(on bool) {
+	u.mouse = on
+	u.app.EnableMouse(on)
+}
+
+// Opens the context menu of the lease in a row when it is clicked
+// with the right button. Other mouse actions go on to the table, and
+// clicks close the menu.
+func (u *ui) tableMouse(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+	if action == tview.MouseLeftDown {
+		u.pages.RemovePage("menu")
+	}
+	if action != tview.MouseRightClick {
+		return action, event
+	}
+	x, y := event.Position()
+	row := tableRowAt(u.table, y)
+	if _, lease := u.rowLease(row); lease == nil {
+		return action, event
+	}
+	if rows, _ := u.table.GetSelectable(); rows {
+		_, col := u.table.GetSelection()
+		u.table.Select(row, col)
+	}
+	u.contextMenu(row, x, y)
+	return action, nil
+}
+
+// Returns the number of fixed rows of a table. tview does not tell, so
+// this goes by the convention of the tables here: the header rows that
+// stay on screen are the leading rows that cannot be selected.
+func fixedRows(table *tview.Table) int {
+	rows := 0
+	for rows < table.GetRowCount() && table.GetCell(rows, 0).NotSelectable {
+		rows++
+	}
+	return rows
+}
+
+// Returns the row of a table at a screen line, -1 for none. tview
+// draws the fixed rows first and the others from the row offset on.
+func tableRowAt(table *tview.Table, y int) int {
+	_, top, _, _ := table.GetInnerRect()
+	row := y - top
+	if row < 0 {
+		return -1
+	}
+	if fixed := fixedRows(table); row >= fixed {
+		offset, _ := table.GetOffset()
+		row += offset
+	}
+	if row >= table.GetRowCount() {
+		return -1
+	}
+	return row
+}
+
+// Shows the actions on the lease in a row in a menu at a screen
+// position. Escape closes it.
+func (u *ui) contextMenu(row, x, y int) {
+	server, lease := u.rowLease(row)
+	closeMenu := func() {
+		u.pages.RemovePage("menu")
+		u.app.SetFocus(u.table)
+	}
+	copyText := func(what, text string) func() {
+		return func() {
+			closeMenu()
+			if err := CopyToClipboard(text); err != nil {
+				u.statusline.SetText(err.Error())
+				return
+			}
+			u.statusline.SetText("Copied " + what + " \"" + text + "\"")
+		}
+	}
+	menu := tview.NewList().ShowSecondaryText(false).
+		AddItem("Delete", "", 'd', func() {
+			closeMenu()
+			u.prev = u.table
+			u.confirm(fmt.Sprintf("Delete lease %s?", lease.IpAddress), func() {
+				u.submit("Deleting leases", u.bulkLeases([]int{row}, deleteLease), 0, dryRun, u.updateTable)
+			})
+		}).
+		AddItem("Details", "", 'i', func() {
+			closeMenu()
+			u.showDetails(row)
+		}).
+		AddItem("Copy IP", "", 'y', copyText("IP", lease.IpAddress)).
+		AddItem("Copy MAC", "", 'm', copyText("MAC", lease.HwAddress)).
+		AddItem("Reserve", "", 'r', func() {
+			closeMenu()
+			u.reserveLease(server, lease)
+		}).
+		AddItem("Ping", "", 'P', func() {
+			closeMenu()
+			u.pingRow(row)
+		})
+	menu.SetDoneFunc(closeMenu)
+	menu.SetBorder(true)
+	// Keeps the menu on screen
+	_, _, width, height := u.pages.GetRect()
+	if x+contextMenuWidth > width {
+		x = width - contextMenuWidth
+	}
+	if y+contextMenuHeight > height {
+		y = height - contextMenuHeight
+	}
+	menu.SetRect(x, y, contextMenuWidth, contextMenuHeight)
+	u.pages.AddPage("menu", menu, false, true)
+	u.app.SetFocus(menu)
+}
+
+// Turns a lease into a host reservation in its subnet with the
+// lease's address, MAC and hostname
+func (u *ui) reserveLease(server *serverView, lease *Lease4) {
+	var subnet *Subnet4
+	for i := range server.subnets {
+		if server.subnets[i].Id == lease.SubnetId {
+			subnet = &server.subnets[i]
+		}
+	}
+	if subnet == nil {
+		u.statusline.SetText(fmt.Sprintf("Subnet %d of %s is not loaded", lease.SubnetId, lease.IpAddress))
+		return
+	}
+	for _, r := range subnet.Reservations {
+		if r.IpAddress == lease.IpAddress {
+			u.statusline.SetText(lease.IpAddress + " is already reserved")
+			return
+		}
+	}
+	r := ReservationFromLease(lease)
+	items := []bulkItem{{
+		label: r.IpAddress,
+		run: func(ctx context.Context) error {
+			return server.client.AddReservation(ctx, subnet.Id, r)
+		},
+		after: func() { subnet.Reservations = append(subnet.Reservations, r) },
+	}}
+	u.prev = u.table
+	u.submit("Reserving "+r.IpAddress, items, 0, dryRun, u.updateTable)
+}
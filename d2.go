@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The service of kea-dhcp-ddns behind the control agent
+const serviceD2 = "d2"
+
+// Configuration of kea-dhcp-ddns, only the parts the status view
+// summarizes
+type D2Config struct {
+	IpAddress        string      `json:"ip-address"`
+	Port             int         `json:"port"`
+	DnsServerTimeout int         `json:"dns-server-timeout"`
+	NcrProtocol      string      `json:"ncr-protocol"`
+	ForwardDdns      D2DdnsZones `json:"forward-ddns"`
+	ReverseDdns      D2DdnsZones `json:"reverse-ddns"`
+	TsigKeys         []struct {
+		Name      string `json:"name"`
+		Algorithm string `json:"algorithm"`
+	} `json:"tsig-keys"`
+}
+
+type D2DdnsZones struct {
+	Domains []D2DdnsDomain `json:"ddns-domains"`
+}
+
+type D2DdnsDomain struct {
+	Name       string `json:"name"`
+	KeyName    string `json:"key-name"`
+	DnsServers []struct {
+		IpAddress string `json:"ip-address"`
+		Port      int    `json:"port"`
+	} `json:"dns-servers"`
+}
+
+// What the control agent tells of kea-dhcp-ddns. The parts it could
+// not get have their errors set.
+type D2Status struct {
+	Status    *KeaStatus
+	StatusErr error
+	// Latest value of each statistic
+	Stats    map[string]int64
+	StatsErr error
+	Config   *D2Config
+	ConfErr  error
+	// Servers and keys of the gss_tsig hook, nil when it is not loaded
+	GssTsig    []GssTsigServer
+	GssTsigErr error
+}
+
+// Sends a command to kea-dhcp-ddns and decodes the arguments of its
+// response into v
+func (c *Client) d2(ctx context.Context, req Request, v interface{}) error {
+	grades, err := c.SendTo(ctx, []string{serviceD2}, req)
+	if err != nil {
+		return err
+	}
+	resp, err := grades.Get(serviceD2)
+	if err != nil {
+		return err
+	}
+	if err = resp.Err(); err != nil {
+		return err
+	}
+	return resp.Decode(v, false)
+}
+
+// Returns the latest value of each statistic of kea-dhcp-ddns
+func (c *Client) D2Stats(ctx context.Context) (map[string]int64, error) {
+	var all map[string][][]json.RawMessage
+	if err := c.d2(ctx, StatisticGetAllRequest{}, &all); err != nil {
+		return nil, err
+	}
+	stats := map[string]int64{}
+	for name, samples := range all {
+		if len(samples) == 0 || len(samples[0]) == 0 {
+			continue
+		}
+		// The latest sample comes first, as value and timestamp
+		if value, err := strconv.ParseInt(string(samples[0][0]), 10, 64); err == nil {
+			stats[name] = value
+		}
+	}
+	return stats, nil
+}
+
+// Returns the DNS updates of kea-dhcp-ddns that timed out or failed,
+// and the name change requests it could not process
+func d2Failures(stats map[string]int64) int64 {
+	return stats["update-timeout"] + stats["update-error"] + stats["ncr-error"]
+}
+
+// Asks kea-dhcp-ddns for its status, statistics and configuration.
+// Kea before 2.0 has no statistics in kea-dhcp-ddns.
+func (c *Client) D2Status(ctx context.Context) (*D2Status, error) {
+	var d2 D2Status
+	var status KeaStatus
+	if d2.StatusErr = c.d2(ctx, StatusGetRequest{}, &status); d2.StatusErr == nil {
+		d2.Status = &status
+	}
+	d2.Stats, d2.StatsErr = c.D2Stats(ctx)
+	var config struct {
+		DhcpDdns D2Config `json:"DhcpDdns"`
+	}
+	if d2.ConfErr = c.d2(ctx, ConfigGetRequest{}, &config); d2.ConfErr == nil {
+		d2.Config = &config.DhcpDdns
+	}
+	d2.GssTsig, d2.GssTsigErr = c.GssTsig(ctx)
+	// Without a response from the control agent there is nothing to show
+	var transportErr *TransportError
+	if errors.As(d2.StatusErr, &transportErr) {
+		return nil, d2.StatusErr
+	}
+	return &d2, nil
+}
+
+// Renders the status of kea-dhcp-ddns for the status view
+func (d *D2Status) String() string {
+	var b strings.Builder
+	if d.Status != nil {
+		fmt.Fprintf(&b, "kea-dhcp-ddns is running, pid %d, up %s",
+			d.Status.Pid, time.Duration(d.Status.Uptime)*time.Second)
+		if d.Status.Reload != d.Status.Uptime {
+			fmt.Fprintf(&b, ", configuration loaded %s ago", time.Duration(d.Status.Reload)*time.Second)
+		}
+		b.WriteString("\n")
+	} else {
+		fmt.Fprintf(&b, "kea-dhcp-ddns is not reachable: %s\n", d.StatusErr)
+	}
+
+	b.WriteString("\nQueue\n")
+	switch {
+	case d.Stats != nil:
+		st := d.Stats
+		fmt.Fprintf(&b, "  Name change requests received %d, invalid %d, failed %d\n",
+			st["ncr-received"], st["ncr-invalid"], st["ncr-error"])
+		if full := st["queue-mgr-queue-full"]; full > 0 {
+			fmt.Fprintf(&b, "  The queue was full %d times, requests were dropped\n", full)
+		} else {
+			b.WriteString("  The queue was never full\n")
+		}
+		// kea-dhcp-ddns tells no queue length, the updates sent without
+		// an answer yet are the closest to it
+		inFlight := st["update-sent"] - st["update-success"] - st["update-timeout"] - st["update-error"]
+		if inFlight < 0 {
+			inFlight = 0
+		}
+		fmt.Fprintf(&b, "  DNS updates sent %d, in flight %d\n", st["update-sent"], inFlight)
+		fmt.Fprintf(&b, "  DNS updates succeeded %d, timed out %d, failed %d\n",
+			st["update-success"], st["update-timeout"], st["update-error"])
+		if st["update-signed"] > 0 {
+			fmt.Fprintf(&b, "  DNS updates signed %d, unsigned %d\n", st["update-signed"], st["update-unsigned"])
+		}
+	case d.Status != nil:
+		fmt.Fprintf(&b, "  No statistics: %s\n", d.StatsErr)
+	}
+
+	b.WriteString("\nConfiguration\n")
+	if d.Config == nil {
+		if d.ConfErr != nil {
+			fmt.Fprintf(&b, "  Not available: %s\n", d.ConfErr)
+		}
+		return b.String()
+	}
+	c := d.Config
+	fmt.Fprintf(&b, "  Listens on %s port %d over %s, DNS timeout %dms\n",
+		c.IpAddress, c.Port, strings.ToUpper(c.NcrProtocol), c.DnsServerTimeout)
+	for _, zones := range []struct {
+		name string
+		D2DdnsZones
+	}{{"Forward", c.ForwardDdns}, {"Reverse", c.ReverseDdns}} {
+		if len(zones.Domains) == 0 {
+			fmt.Fprintf(&b, "  %s: no domains, updates are not sent\n", zones.name)
+			continue
+		}
+		fmt.Fprintf(&b, "  %s:\n", zones.name)
+		for _, domain := range zones.Domains {
+			var servers []string
+			for _, s := range domain.DnsServers {
+				server := s.IpAddress
+				if s.Port != 0 && s.Port != 53 {
+					server += ":" + strconv.Itoa(s.Port)
+				}
+				servers = append(servers, server)
+			}
+			fmt.Fprintf(&b, "    %s → %s", domain.Name, strings.Join(servers, ", "))
+			if domain.KeyName != "" {
+				fmt.Fprintf(&b, " (key %s)", domain.KeyName)
+			}
+			b.WriteString("\n")
+		}
+	}
+	for _, key := range c.TsigKeys {
+		fmt.Fprintf(&b, "  TSIG key %s, %s\n", key.Name, key.Algorithm)
+	}
+	if d.GssTsig != nil || d.GssTsigErr != nil {
+		b.WriteString("\nGSS-TSIG\n")
+		writeGssTsig(&b, d.GssTsig, d.GssTsigErr, time.Now())
+	}
+	return b.String()
+}
+
+// Shows the status of kea-dhcp-ddns behind the selected server
+func (u *ui) showD2() {
+	server, _ := u.current()
+	if server == nil {
+		u.statusline.SetText("Select a server first")
+		return
+	}
+	reqctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+	d2, err := server.client.D2Status(reqctx)
+	cancel()
+	if err != nil {
+		u.statusline.SetText(err.Error())
+		return
+	}
+	u.showText("DHCP-DDNS of "+server.name, d2.String())
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Returns which DNS updates Kea does for a lease: fwd for the A
+// record, rev for the PTR record, or none
+func DdnsFlags(l *Lease4) string {
+	var flags []string
+	if l.FqdnFwd {
+		flags = append(flags, "fwd")
+	}
+	if l.FqdnRev {
+		flags = append(flags, "rev")
+	}
+	if len(flags) == 0 {
+		return "none"
+	}
+	return strings.Join(flags, " ")
+}
+
+// Explains the DDNS flags of a lease with the DDNS settings of its
+// subnet. Kea sets the flags when it grants or renews the lease and
+// removes the records they name when the lease goes away.
+func ExplainDdns(l *Lease4, subnet *Subnet4) string {
+	send := "ddns-send-updates is inherited, true unless the shared network or global scope turn it off"
+	if subnet.DdnsSendUpdates != nil && *subnet.DdnsSendUpdates {
+		send = "ddns-send-updates is true in the subnet"
+	}
+	var why string
+	switch {
+	case subnet.DdnsSendUpdates != nil && !*subnet.DdnsSendUpdates:
+		if l.FqdnFwd || l.FqdnRev {
+			return "ddns-send-updates is false in the subnet, so the flags date from before it was turned off; Kea still removes the records when the lease goes away"
+		}
+		return "ddns-send-updates is false in the subnet, so Kea sends no DNS updates for its leases"
+	case l.FqdnFwd && l.FqdnRev:
+		why = "Kea adds the A and PTR records through kea-dhcp-ddns"
+		if subnet.DdnsOverrideClientUpdate != nil && *subnet.DdnsOverrideClientUpdate {
+			why += ", whatever the client asked, as ddns-override-client-update is true"
+		}
+	case l.FqdnRev:
+		why = "the client updates its A record itself, Kea only the PTR record"
+	case l.FqdnFwd:
+		why = "Kea updates the A record but not the PTR record"
+	case l.Hostname == "":
+		why = "the lease has no hostname, so there is nothing to put in DNS"
+	case subnet.DdnsOverrideNoUpdate != nil && *subnet.DdnsOverrideNoUpdate:
+		why = "no updates, although ddns-override-no-update is true: dhcp-ddns is probably disabled"
+	default:
+		why = "no updates: the client asked to do them itself and ddns-override-client-update is off, or dhcp-ddns is disabled"
+	}
+	if subnet.DdnsQualifyingSuffix != "" {
+		why += "; partial hostnames get the suffix " + subnet.DdnsQualifyingSuffix
+	}
+	return send + "; " + why
+}
+
+// Returns the DDNS settings of a subnet as name and value pairs, in
+// the names of the Kea configuration. Settings the subnet inherits
+// are left out.
+func (s *Subnet4) DdnsFields() [][]string {
+	var fields [][]string
+	addBool := func(name string, v *bool) {
+		if v != nil {
+			fields = append(fields, []string{name, strconv.FormatBool(*v)})
+		}
+	}
+	addString := func(name string, v string) {
+		if v != "" {
+			fields = append(fields, []string{name, v})
+		}
+	}
+	addBool("ddns-send-updates", s.DdnsSendUpdates)
+	addBool("ddns-override-client-update", s.DdnsOverrideClientUpdate)
+	addBool("ddns-override-no-update", s.DdnsOverrideNoUpdate)
+	addString("ddns-replace-client-name", s.DdnsReplaceClientName)
+	addString("ddns-generated-prefix", s.DdnsGeneratedPrefix)
+	addString("ddns-qualifying-suffix", s.DdnsQualifyingSuffix)
+	addBool("ddns-update-on-renew", s.DdnsUpdateOnRenew)
+	addBool("ddns-use-conflict-resolution", s.DdnsUseConflictResolution)
+	addString("ddns-conflict-resolution-mode", s.DdnsConflictResolutionMode)
+	if s.DdnsTtlPercent != nil {
+		fields = append(fields, []string{"ddns-ttl-percent", strconv.FormatFloat(*s.DdnsTtlPercent, 'f', -1, 64)})
+	}
+	if s.HostnameCharSet != nil {
+		fields = append(fields, []string{"hostname-char-set", strconv.Quote(*s.HostnameCharSet)})
+	}
+	if s.HostnameCharReplacement != nil {
+		fields = append(fields, []string{"hostname-char-replacement", strconv.Quote(*s.HostnameCharReplacement)})
+	}
+	if len(fields) == 0 {
+		return [][]string{{"DDNS", "inherited from the shared network or global scope"}}
+	}
+	return fields
+}
+
+// Defaults of Kea for the settings that shape the names it registers
+const (
+	defaultHostnameCharSet     = "[^A-Za-z0-9.-]"
+	defaultDdnsGeneratedPrefix = "myhost"
+)
+
+// Returns the name Kea registers in DNS for a client of the subnet
+// that sends a hostname and gets an address: the hostname lowercased,
+// with the characters of hostname-char-set replaced, or generated
+// from the address when ddns-replace-client-name says so, and
+// qualified with ddns-qualifying-suffix. Settings the subnet inherits
+// are taken at the defaults of Kea. Nothing is registered for an empty
+// name.
+func (s *Subnet4) RegisteredName(hostname, ip string) (string, error) {
+	hostname = strings.TrimSpace(hostname)
+	switch s.DdnsReplaceClientName {
+	case "always", "when-present":
+		if hostname != "" || s.DdnsReplaceClientName == "always" {
+			hostname = ""
+			if ip != "" {
+				hostname = s.generatedName(ip)
+			}
+		}
+	case "when-not-present":
+		if hostname == "" && ip != "" {
+			hostname = s.generatedName(ip)
+		}
+	}
+	charset, replacement := defaultHostnameCharSet, ""
+	if s.HostnameCharSet != nil {
+		charset = *s.HostnameCharSet
+	}
+	if s.HostnameCharReplacement != nil {
+		replacement = *s.HostnameCharReplacement
+	}
+	name := strings.ToLower(hostname)
+	if charset != "" {
+		re, err := regexp.Compile(charset)
+		if err != nil {
+			return "", fmt.Errorf("hostname-char-set: %w", err)
+		}
+		name = re.ReplaceAllLiteralString(name, replacement)
+	}
+	name = strings.Trim(name, ".")
+	if name == "" {
+		return "", nil
+	}
+	suffix := strings.Trim(s.DdnsQualifyingSuffix, ".")
+	if suffix != "" && !strings.HasSuffix(strings.TrimSuffix(hostname, "."), "."+suffix) &&
+		!strings.HasSuffix(hostname, ".") {
+		name += "." + suffix
+	}
+	return name + ".", nil
+}
+
+// Returns the name Kea makes up for an address from
+// ddns-generated-prefix, like myhost-10-0-0-5
+func (s *Subnet4) generatedName(ip string) string {
+	prefix := s.DdnsGeneratedPrefix
+	if prefix == "" {
+		prefix = defaultDdnsGeneratedPrefix
+	}
+	return prefix + "-" + strings.ReplaceAll(ip, ".", "-")
+}
+
+// Shows the name Kea registers for a hostname a client of the subnet
+// shown sends, like :sanitize Bob's MacBook
+func (u *ui) sanitizePreview(args string) {
+	hostname := strings.TrimSpace(args)
+	_, subnet := u.current()
+	switch {
+	case hostname == "":
+		u.statusline.SetText("Usage: sanitize <hostname>")
+		return
+	case subnet == nil:
+		u.statusline.SetText("Select a subnet first")
+		return
+	}
+	// Names generated from the address show where the address goes
+	name, err := subnet.RegisteredName(hostname, "a.b.c.d")
+	switch {
+	case err != nil:
+		u.statusline.SetText(err.Error())
+	case name == "":
+		u.statusline.SetText(fmt.Sprintf("%q registers no name in %s", hostname, subnet.Subnet))
+	default:
+		u.statusline.SetText(fmt.Sprintf("%q registers as %s in %s", hostname, name, subnet.Subnet))
+	}
+}
+
+// Outcomes of DNS updates in the DNS status column
+const (
+	ddnsOK      = "ok"
+	ddnsPending = "pending"
+	ddnsFailed  = "failed"
+	ddnsNA      = "n-a"
+)
+
+// Path in the user context of leases, from the ddns-status-key
+// setting, where a hook records the outcome of their DNS updates. The
+// lease tables have a DNS status column only when it is set.
+var ddnsStatusKey string
+
+var ddnsStatusColors = map[string]tcell.Color{
+	ddnsOK:      tcell.ColorGreen,
+	ddnsPending: tcell.ColorYellow,
+	ddnsFailed:  tcell.ColorRed,
+	ddnsNA:      tcell.ColorGray,
+}
+
+// Returns the outcome of the DNS updates of a lease recorded under
+// ddnsStatusKey: ok or failed for true and false, the recorded text
+// otherwise, and n-a when nothing is recorded or no updates are done.
+// It is read once, when the lease is decoded.
+func DdnsStatus(l *Lease4) string {
+	if l.ddnsStatus == "" {
+		return ddnsNA
+	}
+	return l.ddnsStatus
+}
+
+// Reads the outcome of the DNS updates of a lease from its user
+// context, "" for none
+func parseDdnsStatus(l *Lease4) string {
+	if ddnsStatusKey == "" || len(l.UserContext) == 0 || (!l.FqdnFwd && !l.FqdnRev) {
+		return ""
+	}
+	var v any
+	if json.Unmarshal(l.UserContext, &v) != nil {
+		return ""
+	}
+	for _, name := range strings.Split(ddnsStatusKey, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return ""
+		}
+		v = m[name]
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return ddnsOK
+		}
+		return ddnsFailed
+	}
+	return ""
+}
+
+// Returns the cell of the DNS status column for a lease
+func ddnsStatusCell(l *Lease4) *tview.TableCell {
+	status := DdnsStatus(l)
+	cell := tview.NewTableCell(status)
+	if color, ok := ddnsStatusColors[status]; ok {
+		cell.SetTextColor(color)
+	}
+	return cell
+}
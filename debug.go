@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Number of exchanges the debug log keeps
+const debugLogSize = 100
+
+// An exchange with a server as recorded by DebugTransport
+type DebugEntry struct {
+	Time     time.Time
+	Server   string
+	Duration time.Duration
+	Request  []byte
+	Response []byte
+	Err      error
+}
+
+func (e *DebugEntry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s (%s)\n> %s\n", e.Time.Format(timeFormat+".000"),
+		e.Server, e.Duration.Round(time.Millisecond), e.Request)
+	if len(e.Response) > 0 {
+		fmt.Fprintf(&b, "< %s\n", e.Response)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&b, "! %s\n", e.Err)
+	}
+	return b.String()
+}
+
+type wireKey struct{}
+
+// The bytes of an exchange as they went over the wire
+type wire struct {
+	sent     []byte
+	received []byte
+}
+
+// Returns a context in which transports keep the bytes they send and
+// receive in the returned wire, before they decode anything
+func withWire(ctx context.Context) (context.Context, *wire) {
+	w := &wire{}
+	return context.WithValue(ctx, wireKey{}, w), w
+}
+
+// Keeps the bytes sent in the wire of a context, if any
+func wireSent(ctx context.Context, data []byte) {
+	if w, ok := ctx.Value(wireKey{}).(*wire); ok {
+		w.sent = append([]byte{}, data...)
+	}
+}
+
+// Keeps the bytes received in the wire of a context, if any
+func wireReceived(ctx context.Context, data []byte) {
+	if w, ok := ctx.Value(wireKey{}).(*wire); ok {
+		w.received = append([]byte{}, data...)
+	}
+}
+
+// DebugLog keeps the last exchanges of the transports that record
+// into it
+type DebugLog struct {
+	mu      sync.Mutex
+	entries []DebugEntry
+	changed func()
+}
+
+// Sets a function to call after every exchange, or none if f is nil
+func (l *DebugLog) OnChange(f func()) {
+	l.mu.Lock()
+	l.changed = f
+	l.mu.Unlock()
+}
+
+func (l *DebugLog) add(e DebugEntry) {
+	l.mu.Lock()
+	l.entries = append(l.entries, e)
+	if len(l.entries) > debugLogSize {
+		l.entries = l.entries[len(l.entries)-debugLogSize:]
+	}
+	changed := l.changed
+	l.mu.Unlock()
+	if changed != nil {
+		changed()
+	}
+}
+
+// Returns the exchanges recorded, oldest first
+func (l *DebugLog) Entries() []DebugEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]DebugEntry{}, l.entries...)
+}
+
+func (l *DebugLog) String() string {
+	var b strings.Builder
+	for _, e := range l.Entries() {
+		b.WriteString(e.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Exchanges of the servers opened in the UI
+var debugLog DebugLog
+
+// DebugTransport records every request sent through a transport and
+// the response to it, as the bytes exchanged, so that responses that
+// fail to decode are logged too. Transports that do not keep the
+// bytes get the request and responses encoded again.
+type DebugTransport struct {
+	Transport
+	Server string
+	Log    *DebugLog
+}
+
+func (t *DebugTransport) Do(ctx context.Context, req *KeaRequest) ([]KeaResponse, error) {
+	e := DebugEntry{Time: time.Now(), Server: t.Server}
+	ctx, w := withWire(ctx)
+	grades, err := t.Transport.Do(ctx, req)
+	e.Duration = time.Since(e.Time)
+	e.Err = err
+	e.Request, e.Response = w.sent, w.received
+	if e.Request == nil {
+		e.Request, _ = json.Marshal(req)
+	}
+	if e.Response == nil && err == nil {
+		e.Response, _ = json.Marshal(grades)
+	}
+	t.Log.add(e)
+	return grades, err
+}
+
+// Toggles the pane showing the debug log. It follows new exchanges
+// while open, y copies the whole log.
+func (u *ui) toggleDebug() {
+	if u.pages.HasPage("debug") {
+		debugLog.OnChange(nil)
+		u.pages.RemovePage("debug")
+		u.app.SetFocus(u.prev)
+		return
+	}
+	view := tview.NewTextView().SetText(debugLog.String())
+	view.SetBorder(true)
+	view.SetTitle("Debug log")
+	view.ScrollToEnd()
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyF12, event.Key() == tcell.KeyEscape:
+			u.toggleDebug()
+			return nil
+		case event.Rune() == 'y':
+			if err := CopyToClipboard(debugLog.String()); err != nil {
+				u.statusline.SetText(err.Error())
+			} else {
+				u.statusline.SetText("Copied the debug log")
+			}
+			return nil
+		}
+		return event
+	})
+	// Exchanges can be recorded on the UI goroutine, so refreshes are
+	// queued from another one, and at most one at a time
+	var queued int32
+	debugLog.OnChange(func() {
+		if !atomic.CompareAndSwapInt32(&queued, 0, 1) {
+			return
+		}
+		go u.app.QueueUpdateDraw(func() {
+			atomic.StoreInt32(&queued, 0)
+			view.SetText(debugLog.String())
+			view.ScrollToEnd()
+		})
+	})
+	u.prev = u.app.GetFocus()
+	u.pages.AddPage("debug", view, true, true)
+	u.app.SetFocus(view)
+}
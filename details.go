@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Returns the fields of a reservation as name and value pairs for
+// detailed display. subnet is the subnet the reservation is in, used
+// to find the pool holding the reserved address.
+func ReservationFields(r *Reservation, subnet *Subnet4) [][]string {
+	fields := [][]string{
+		{"IP address", r.IpAddress},
+		{"MAC address", r.HwAddress},
+	}
+	if vendor := macVendor(r.HwAddress); vendor != "" {
+		fields = append(fields, []string{"Vendor", vendor})
+	}
+	fields = append(fields, []string{"Hostname", r.Hostname})
+	if subnet != nil {
+		pool := "none"
+		if p := PoolOf(subnet, r.IpAddress); p != nil {
+			pool = p.Pool
+		}
+		fields = append(fields,
+			[]string{"Subnet", fmt.Sprintf("%s (ID %d)", subnet.Subnet, subnet.Id)},
+			[]string{"Pool", pool})
+	}
+	fields = append(fields,
+		[]string{"Client classes", strings.Join(r.Classes(), ", ")},
+		[]string{"Next server", r.NextServer},
+		[]string{"Server hostname", r.ServerHostname},
+		[]string{"Boot file", r.BootFileName})
+	for _, raw := range r.OptionData {
+		var opt OptionData
+		if json.Unmarshal(raw, &opt) != nil {
+			fields = append(fields, []string{"Option", string(raw)})
+			continue
+		}
+		fields = append(fields, []string{
+			fmt.Sprintf("%s (%d)", opt.DisplayName(), opt.Code), opt.Value()})
+	}
+	if len(r.UserContext) > 0 {
+		fields = append(fields, []string{"User context", string(r.UserContext)})
+	}
+	return fields
+}
+
+// Returns the pool of the subnet that contains an address, or nil
+func PoolOf(subnet *Subnet4, ip string) *Pool {
+	addr := net.ParseIP(ip).To4()
+	if addr == nil {
+		return nil
+	}
+	for i, p := range subnet.Pools {
+		first, last := p.Range()
+		if first != nil && bytes.Compare(addr, first) >= 0 && bytes.Compare(addr, last) <= 0 {
+			return &subnet.Pools[i]
+		}
+	}
+	return nil
+}
+
+// Returns the first and the last address of a pool, which is either
+// a range like "192.0.2.10 - 192.0.2.20" or a prefix, or nil if it is
+// neither
+func (p *Pool) Range() (net.IP, net.IP) {
+	if _, prefix, err := net.ParseCIDR(strings.TrimSpace(p.Pool)); err == nil {
+		first := prefix.IP.To4()
+		if first == nil {
+			return nil, nil
+		}
+		last := make(net.IP, len(first))
+		for i := range first {
+			last[i] = first[i] | ^prefix.Mask[i]
+		}
+		return first, last
+	}
+	from, to, ok := strings.Cut(p.Pool, "-")
+	if !ok {
+		return nil, nil
+	}
+	first := net.ParseIP(strings.TrimSpace(from)).To4()
+	last := net.ParseIP(strings.TrimSpace(to)).To4()
+	if first == nil || last == nil {
+		return nil, nil
+	}
+	return first, last
+}
+
+// Shows the details of the lease or reservation in a table row, or
+// the JSON value of a row as a tree. y and Y copy from the details
+// like they do from tables.
+func (u *ui) showDetails(row int) {
+	if data, ok := u.table.GetCell(row, 1).GetReference().(json.RawMessage); ok {
+		u.showJSON(u.table.GetCell(row, 0).Text, data)
+		return
+	}
+	var title string
+	var fields [][]string
+	var record any
+	switch ref := u.table.GetCell(row, 0).GetReference().(type) {
+	case Reservation:
+		_, subnet := u.current()
+		title, fields = "Reservation "+ref.IpAddress, ReservationFields(&ref, subnet)
+		record = ref
+	default:
+		server, lease := u.rowLease(row)
+		if lease == nil {
+			return
+		}
+		title, fields = "Lease "+lease.IpAddress, LeaseFields(lease)
+		record = lease
+		var reservation *Reservation
+		for i := range server.subnets {
+			for j, r := range server.subnets[i].Reservations {
+				if r.IpAddress == lease.IpAddress {
+					reservation = &server.subnets[i].Reservations[j]
+				}
+			}
+		}
+		for i := range server.subnets {
+			if server.subnets[i].Id == lease.SubnetId {
+				subnet := &server.subnets[i]
+				fields = append(fields, []string{"DDNS explained", ExplainDdns(lease, subnet)})
+				if name, err := subnet.RegisteredName(lease.Hostname, lease.IpAddress); err == nil &&
+					name != "" && name != strings.TrimSuffix(lease.Hostname, ".")+"." {
+					fields = append(fields, []string{"Registered as", name})
+				}
+			}
+		}
+		if issue := server.view.dnsIssues[lease.IpAddress]; issue != "" {
+			fields = append(fields, []string{"DNS check", issue})
+		}
+		fields = append(fields, SNMPFields(lease)...)
+		if conflict := server.view.macConflict(lease, reservation); conflict != "" {
+			fields = append(fields, []string{"Conflict", conflict})
+		}
+	}
+	table := tview.NewTable().SetSelectable(true, false)
+	for i, f := range fields {
+		table.SetCell(i, 0, tview.NewTableCell(f[0]).SetTextColor(tcell.ColorYellow))
+		if isJSONTree(f[1]) {
+			table.SetCell(i, 1, jsonCell([]byte(f[1])))
+		} else {
+			table.SetCell(i, 1, tview.NewTableCell(f[1]))
+		}
+	}
+	// Enter expands JSON values into a tree
+	table.SetSelectedFunc(func(row, col int) {
+		if data, ok := table.GetCell(row, 1).GetReference().(json.RawMessage); ok {
+			u.showJSON(table.GetCell(row, 0).Text, data)
+		}
+	})
+	// y copies the value of the selected field, Y the whole record
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		var text, copied string
+		switch event.Rune() {
+		case 'y':
+			row, _ := table.GetSelection()
+			text = table.GetCell(row, 1).Text
+			copied = "\"" + text + "\""
+		case 'Y':
+			data, err := json.MarshalIndent(record, "", "  ")
+			if err != nil {
+				u.statusline.SetText(err.Error())
+				return nil
+			}
+			text, copied = string(data), title+" as JSON"
+		default:
+			return event
+		}
+		if err := CopyToClipboard(text); err != nil {
+			u.statusline.SetText(err.Error())
+			return nil
+		}
+		u.statusline.SetText("Copied " + copied)
+		return nil
+	})
+	u.prev = u.table
+	u.showTable(title, table)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Kinds of lease changes between two fetches
+const (
+	LeaseNew     = "new"
+	LeaseExpired = "expired"
+	LeaseDeleted = "deleted"
+	LeaseChanged = "changed"
+	LeaseRenewed = "renewed"
+)
+
+type LeaseChange struct {
+	Kind string  `json:"kind"`
+	Old  *Lease4 `json:"old,omitempty"`
+	New  *Lease4 `json:"new,omitempty"`
+}
+
+// The lease after the change, or before it for leases that are gone
+func (c *LeaseChange) Lease() *Lease4 {
+	if c.New != nil {
+		return c.New
+	}
+	return c.Old
+}
+
+// Describes what changed between the old and the new lease
+func (c *LeaseChange) Details() string {
+	if c.Old == nil || c.New == nil {
+		return ""
+	}
+	var diffs []string
+	field := func(name, old, new string) {
+		if old != new {
+			diffs = append(diffs, fmt.Sprintf("%s %q -> %q", name, old, new))
+		}
+	}
+	oldState, _ := LeaseState(c.Old.State)
+	newState, _ := LeaseState(c.New.State)
+	field("mac", c.Old.HwAddress, c.New.HwAddress)
+	field("hostname", c.Old.Hostname, c.New.Hostname)
+	field("client-id", c.Old.ClientId, c.New.ClientId)
+	field("state", oldState, newState)
+	return strings.Join(diffs, ", ")
+}
+
+// Compares two fetches of the same leases by IP address. Leases that
+// were reclaimed, or vanished after their valid lifetime, count as
+// expired, leases that vanished before as deleted, and leases that
+// only got a new transaction time as renewed.
+func DiffLeases(prev, cur []Lease4) []LeaseChange {
+	before := make(map[string]*Lease4, len(prev))
+	for i := range prev {
+		before[prev[i].IpAddress] = &prev[i]
+	}
+	var changes []LeaseChange
+	for i := range cur {
+		n := &cur[i]
+		o, ok := before[n.IpAddress]
+		delete(before, n.IpAddress)
+		switch {
+		case !ok:
+			changes = append(changes, LeaseChange{LeaseNew, nil, n})
+		case n.State == stateExpiredReclaimed && o.State != stateExpiredReclaimed:
+			changes = append(changes, LeaseChange{LeaseExpired, o, n})
+		case o.HwAddress != n.HwAddress || o.Hostname != n.Hostname ||
+			o.ClientId != n.ClientId || o.State != n.State:
+			changes = append(changes, LeaseChange{LeaseChanged, o, n})
+		case o.Cltt != n.Cltt:
+			changes = append(changes, LeaseChange{LeaseRenewed, o, n})
+		}
+	}
+	now := time.Now().Unix()
+	for i := range prev {
+		if o, ok := before[prev[i].IpAddress]; ok {
+			kind := LeaseExpired
+			if o.State != stateExpiredReclaimed && o.Cltt+int64(o.ValidLft) > now {
+				kind = LeaseDeleted
+			}
+			changes = append(changes, LeaseChange{kind, o, nil})
+		}
+	}
+	return changes
+}
+
+// Colors of the kinds of lease changes in the changes panel
+var changeColors = map[string]tcell.Color{
+	LeaseNew:     tcell.ColorGreen,
+	LeaseExpired: tcell.ColorGray,
+	LeaseDeleted: tcell.ColorRed,
+	LeaseChanged: tcell.ColorYellow,
+}
+
+// Shows what changed in the subnet shown between its last two fetches,
+// apart from renewals
+func (u *ui) showChanges() {
+	server, subnet := u.current()
+	if subnet == nil || server.view.subnet != subnet || server.view.dispmode != displayLeases {
+		u.statusline.SetText("Changes are shown for the leases of a subnet")
+		return
+	}
+	changes := server.view.changes
+	if changes == nil {
+		u.statusline.SetText("No changes, the leases were fetched once only")
+		return
+	}
+	table := tview.NewTable().
+		SetSeparator(tview.Borders.Vertical).
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	for i, name := range []string{"Change", "IP", "MAC", "Hostname", "Details"} {
+		table.SetCell(0, i, tview.NewTableCell(name).
+			SetTextColor(tcell.ColorYellow).
+			SetSelectable(false))
+	}
+	for i, c := range changes {
+		l := c.Lease()
+		table.SetCell(i+1, 0, tview.NewTableCell(c.Kind).SetTextColor(changeColors[c.Kind]))
+		table.SetCell(i+1, 1, tview.NewTableCell(l.IpAddress))
+		table.SetCell(i+1, 2, tview.NewTableCell(l.HwAddress))
+		table.SetCell(i+1, 3, tview.NewTableCell(l.Hostname))
+		table.SetCell(i+1, 4, tview.NewTableCell(c.Details()))
+	}
+	u.prev = u.app.GetFocus()
+	u.showTable(fmt.Sprintf("%d changes since the previous refresh", len(changes)), table)
+}
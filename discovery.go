@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Discovery is a source of Control Agents listed in the configuration.
+// Exactly one of SRV, Consul and Etcd is set. Discovered servers get
+// a profile, in Group if set, unless a profile of that name exists.
+// It is named after the host for SRV, the service ID for Consul and
+// the last element of the key for etcd.
+type Discovery struct {
+	// DNS SRV name like _kea-ctrl-agent._tcp.example.com
+	SRV string `json:"srv,omitempty"`
+	// Base URL of a Consul agent and the service the agents are
+	// registered as
+	Consul  string `json:"consul,omitempty"`
+	Service string `json:"service,omitempty"`
+	// Base URL of the etcd v3 JSON gateway and the key prefix under
+	// which each key holds the address of a server
+	Etcd   string `json:"etcd,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	// "https" for agents that use TLS
+	Scheme string `json:"scheme,omitempty"`
+	Group  string `json:"group,omitempty"`
+}
+
+// Looks up the servers of every discovery source and adds profiles
+// for them. Sources that fail are skipped and reported.
+func (c *Config) Discover(ctx context.Context) []error {
+	var errs []error
+	for _, d := range c.Discovery {
+		found, err := d.lookup(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if c.Servers == nil {
+			c.Servers = map[string]ServerProfile{}
+		}
+		for name, addr := range found {
+			if _, ok := c.Servers[name]; !ok {
+				c.Servers[name] = ServerProfile{Address: addr, Group: d.Group}
+			}
+		}
+	}
+	return errs
+}
+
+// Returns the addresses of the servers found, by name
+func (d *Discovery) lookup(ctx context.Context) (map[string]string, error) {
+	scheme := d.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	addr := func(host string, port int) string {
+		return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	found := map[string]string{}
+	switch {
+	case d.SRV != "":
+		_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", d.SRV)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range records {
+			host := strings.TrimSuffix(r.Target, ".")
+			found[host] = addr(host, int(r.Port))
+		}
+	case d.Consul != "":
+		var services []struct {
+			ServiceID      string
+			Address        string
+			ServiceAddress string
+			ServicePort    int
+		}
+		u := strings.TrimSuffix(d.Consul, "/") + "/v1/catalog/service/" + url.PathEscape(d.Service)
+		if err := discoveryRequest(ctx, "GET", u, nil, &services); err != nil {
+			return nil, err
+		}
+		for _, s := range services {
+			host := s.ServiceAddress
+			if host == "" {
+				host = s.Address
+			}
+			// A node can run several agents, each its own service
+			found[s.ServiceID] = addr(host, s.ServicePort)
+		}
+	case d.Etcd != "":
+		req := map[string]string{
+			"key":       base64.StdEncoding.EncodeToString([]byte(d.Prefix)),
+			"range_end": base64.StdEncoding.EncodeToString(prefixEnd(d.Prefix)),
+		}
+		var resp struct {
+			Kvs []struct {
+				Key   []byte `json:"key"`
+				Value []byte `json:"value"`
+			} `json:"kvs"`
+		}
+		u := strings.TrimSuffix(d.Etcd, "/") + "/v3/kv/range"
+		if err := discoveryRequest(ctx, "POST", u, req, &resp); err != nil {
+			return nil, err
+		}
+		for _, kv := range resp.Kvs {
+			found[path.Base(string(kv.Key))] = strings.TrimSpace(string(kv.Value))
+		}
+	default:
+		return nil, fmt.Errorf("discovery without srv, consul or etcd")
+	}
+	return found, nil
+}
+
+// Returns the end of the etcd key range holding every key that starts
+// with a prefix, as etcd's clientv3.GetPrefixEnd computes it: the
+// prefix up to its last byte below 0xff, which is incremented. The
+// empty prefix, and those of 0xff bytes only, take every key, for
+// which etcd expects the end "\x00".
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return []byte{0}
+}
+
+// Sends a JSON request to a discovery service and decodes the
+// response into v
+func discoveryRequest(ctx context.Context, method, u string, body any, v any) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
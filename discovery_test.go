@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   []byte
+	}{
+		{"", []byte{0}},
+		{"/kea/", []byte("/kea0")},
+		{"a\xff", []byte("b")},
+		{"a\xff\xff", []byte("b")},
+		{"\xff\xff", []byte{0}},
+	}
+	for _, test := range tests {
+		if got := prefixEnd(test.prefix); !bytes.Equal(got, test.want) {
+			t.Errorf("prefixEnd(%q) = %q, want %q", test.prefix, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Color of the DDNS cells of leases whose DNS records do not match
+const dnsIssueColor = tcell.ColorRed
+
+// Resolves the hostname and the address of a lease as far as Kea
+// updates them, and returns how the records differ from the lease, or
+// nothing when they match
+func CheckDNS(ctx context.Context, resolver *net.Resolver, l *Lease4) string {
+	host := strings.TrimSuffix(l.Hostname, ".")
+	var problems []string
+	if l.FqdnFwd {
+		addrs, err := resolver.LookupHost(ctx, host)
+		switch {
+		case err != nil:
+			problems = append(problems, "no A record for "+host)
+		case !containsString(addrs, l.IpAddress):
+			problems = append(problems, host+" resolves to "+strings.Join(addrs, ", "))
+		}
+	}
+	if l.FqdnRev {
+		names, err := resolver.LookupAddr(ctx, l.IpAddress)
+		found := false
+		for _, name := range names {
+			found = found || sameHost(name, host)
+		}
+		switch {
+		case err != nil || len(names) == 0:
+			problems = append(problems, "no PTR record")
+		case !found:
+			problems = append(problems, "PTR record is "+strings.TrimSuffix(names[0], "."))
+		}
+	}
+	return strings.Join(problems, "; ")
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the index of the column of a lease field in the lease table,
+// -1 when it is not shown
+func leaseColumn(field int) int {
+	for i, f := range shownLeaseFields() {
+		if f == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// Checks the DNS records of the active leases with DDNS flags in the
+// subnet shown, at most ptrRate lookups a second, then colors the DDNS
+// cells of those that do not match and lists them
+func (u *ui) checkDNS() {
+	server, subnet := u.current()
+	if subnet == nil || server.view.dispmode != displayLeases || server.view.leases == nil {
+		u.statusline.SetText("DNS is checked for the leases of a subnet")
+		return
+	}
+	var leases []Lease4
+	for _, l := range server.view.leases {
+		if (l.FqdnFwd || l.FqdnRev) && l.Hostname != "" && l.State == stateDefault {
+			leases = append(leases, l)
+		}
+	}
+	if len(leases) == 0 {
+		u.statusline.SetText("No leases with DNS updates to check")
+		return
+	}
+	u.statusline.SetText(fmt.Sprintf("Checking DNS of %d leases…", len(leases)))
+	go func() {
+		ticker := time.NewTicker(time.Second / ptrRate)
+		defer ticker.Stop()
+		issues := map[string]string{}
+		for i := range leases {
+			select {
+			case <-u.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			ctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+			if problem := CheckDNS(ctx, net.DefaultResolver, &leases[i]); problem != "" {
+				issues[leases[i].IpAddress] = problem
+			}
+			cancel()
+		}
+		u.app.QueueUpdateDraw(func() {
+			if server.view.subnet != subnet {
+				return
+			}
+			server.view.dnsIssues = issues
+			u.refreshTable(server)
+			if len(issues) == 0 {
+				u.statusline.SetText(fmt.Sprintf("The DNS records of all %d leases match", len(leases)))
+				return
+			}
+			u.showDNSIssues(server, leases)
+		})
+	}()
+}
+
+// Lists the leases whose DNS records did not match in the last check.
+// r resends the DNS updates of them all.
+func (u *ui) showDNSIssues(server *serverView, leases []Lease4) {
+	table := tview.NewTable().
+		SetSeparator(tview.Borders.Vertical).
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	for i, name := range []string{"IP", "Hostname", "DDNS", "Problem"} {
+		table.SetCell(0, i, tview.NewTableCell(name).
+			SetTextColor(tcell.ColorYellow).
+			SetSelectable(false))
+	}
+	row := 1
+	for i := range leases {
+		l := &leases[i]
+		problem, ok := server.view.dnsIssues[l.IpAddress]
+		if !ok {
+			continue
+		}
+		table.SetCell(row, 0, tview.NewTableCell(l.IpAddress))
+		table.SetCell(row, 1, tview.NewTableCell(l.Hostname))
+		table.SetCell(row, 2, tview.NewTableCell(DdnsFlags(l)))
+		table.SetCell(row, 3, tview.NewTableCell(problem).SetTextColor(dnsIssueColor))
+		row++
+	}
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Rune() != 'r' {
+			return event
+		}
+		u.pages.RemovePage("popup")
+		u.resendMismatched(server)
+		return nil
+	})
+	u.prev = u.app.GetFocus()
+	u.showTable(fmt.Sprintf("%d leases with DNS mismatches, r resends their updates", row-1), table)
+}
+
+// Resends the DNS updates of every lease the last DNS check flagged in
+// the subnet shown. Leases whose updates were resent are no longer
+// flagged until the next check.
+func (u *ui) resendMismatched(server *serverView) {
+	view := &server.view
+	var items []bulkItem
+	for ip := range view.dnsIssues {
+		ip := ip
+		items = append(items, bulkItem{
+			label: ip,
+			run: func(ctx context.Context) error {
+				return server.client.ResendDdns(ctx, ip)
+			},
+			after: func() { delete(view.dnsIssues, ip) },
+		})
+	}
+	if len(items) == 0 {
+		u.statusline.SetText("No DNS mismatches, :dns-check looks for them")
+		return
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(items[i].label), net.ParseIP(items[j].label)) < 0
+	})
+	u.prev = u.table
+	u.confirmBulk(fmt.Sprintf("Resend the DNS updates of %d mismatched leases?", len(items)), func(dry bool) {
+		u.runBulk("Resending DNS updates", items, ddnsInterval, dry, func() {
+			u.refreshTable(server)
+			u.statusline.SetText(u.statusline.GetText(false) + ", :dns-check verifies the records")
+		})
+	})
+}
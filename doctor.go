@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Outcomes of a doctor check
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+type doctorCheck struct {
+	Name   string `json:"check"`
+	Result string `json:"result"`
+	Detail string `json:"detail"`
+}
+
+// The parts of the dhcp4 configuration the doctor looks at
+type doctorConfig struct {
+	HooksLibraries []HookLibrary `json:"hooks-libraries"`
+	LeaseDatabase  struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+		Host string `json:"host"`
+	} `json:"lease-database"`
+}
+
+// Reports whether the TLS handshake with the Control Agent failed
+func isTLSError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var header tls.RecordHeaderError
+	return errors.As(err, &unknownAuthority) || errors.As(err, &hostname) ||
+		errors.As(err, &invalid) || errors.As(err, &header)
+}
+
+// Checks the server step by step and prints a pass/fail report. Once
+// a check fails, the checks that depend on it are skipped.
+func cliDoctor(ctx context.Context, client *Client, flags *flag.FlagSet, args []string) (*cliResult, error) {
+	if _, err := parseArgs(client, flags, args, 0); err != nil {
+		return nil, err
+	}
+	var checks []doctorCheck
+	add := func(name, result, detail string) {
+		checks = append(checks, doctorCheck{name, result, detail})
+	}
+	httpTransport, isHTTP := client.Transport.(*HTTPTransport)
+
+	// Without a service the request goes to the Control Agent itself,
+	// or to the daemon behind a control socket
+	grades, err := client.SendTo(ctx, nil, VersionGetRequest{})
+	switch {
+	case err == nil:
+		add("Connectivity", checkPass, "")
+	case errors.Is(err, ErrUnauthorized), isTLSError(err):
+		add("Connectivity", checkPass, "")
+	default:
+		add("Connectivity", checkFail, err.Error())
+	}
+	switch {
+	case !isHTTP:
+		add("TLS", checkSkip, "control socket")
+	case !strings.HasPrefix(httpTransport.URL, "https://"):
+		add("TLS", checkSkip, "plain HTTP")
+	case isTLSError(err):
+		add("TLS", checkFail, err.Error())
+	case err == nil || errors.Is(err, ErrUnauthorized):
+		add("TLS", checkPass, "")
+	default:
+		add("TLS", checkSkip, "not connected")
+	}
+	switch {
+	case !isHTTP:
+		add("Authentication", checkSkip, "control socket")
+	case errors.Is(err, ErrUnauthorized):
+		add("Authentication", checkFail, err.Error())
+	case err != nil:
+		add("Authentication", checkSkip, "not connected")
+	case httpTransport.User == "":
+		add("Authentication", checkPass, "not required")
+	default:
+		add("Authentication", checkPass, "as "+httpTransport.User)
+	}
+	connected := err == nil
+	switch {
+	case !isHTTP:
+		add("Control Agent version", checkSkip, "control socket")
+	case !connected:
+		add("Control Agent version", checkSkip, "not connected")
+	case grades[0].Err() != nil:
+		add("Control Agent version", checkFail, grades[0].Text)
+	default:
+		add("Control Agent version", checkPass, grades[0].Text)
+	}
+
+	skip := func(names ...string) {
+		for _, name := range names {
+			add(name, checkSkip, "not connected")
+		}
+	}
+	if !connected {
+		skip("DHCPv4 version", "Hooks", "Lease backend", "High availability")
+		return doctorResult(checks)
+	}
+	if err = client.DetectVersion(ctx); err != nil {
+		add("DHCPv4 version", checkFail, err.Error())
+	} else {
+		add("DHCPv4 version", checkPass, client.Compat.String())
+	}
+
+	config, err := doctorGetConfig(ctx, client)
+	if err != nil {
+		add("Hooks", checkFail, err.Error())
+		add("Lease backend", checkFail, err.Error())
+	} else {
+		loaded := map[string]bool{}
+		var names []string
+		for _, h := range config.HooksLibraries {
+			loaded[h.Name()] = true
+			names = append(names, strings.TrimSuffix(path.Base(h.Library), ".so"))
+		}
+		switch {
+		case !loaded["lease_cmds"]:
+			add("Hooks", checkFail, "lease_cmds is not loaded, leases cannot be listed")
+		case !loaded["host_cmds"]:
+			add("Hooks", checkPass, strings.Join(names, ", ")+
+				"; host_cmds is not loaded, reservations cannot be changed")
+		default:
+			add("Hooks", checkPass, strings.Join(names, ", "))
+		}
+		db := config.LeaseDatabase
+		if db.Type == "" {
+			db.Type = "memfile"
+		}
+		detail := db.Type
+		if db.Host != "" || db.Name != "" {
+			detail = fmt.Sprintf("%s %s/%s", db.Type, db.Host, db.Name)
+		}
+		add("Lease backend", checkPass, detail)
+	}
+
+	status, err := client.Status(ctx)
+	switch {
+	case err != nil:
+		add("High availability", checkFail, err.Error())
+	case len(status.HighAvailability) == 0:
+		add("High availability", checkSkip, "not configured")
+	default:
+		result := checkPass
+		var states []string
+		for _, ha := range status.HighAvailability {
+			for _, name := range []string{"local", "remote"} {
+				server, ok := ha.Servers[name]
+				if !ok {
+					continue
+				}
+				state := server.CurrentState()
+				if server.CommunicationInterrupted {
+					state += " (communication interrupted)"
+					result = checkFail
+				}
+				if haUnhealthy[server.CurrentState()] {
+					result = checkFail
+				}
+				states = append(states, fmt.Sprintf("%s %s", server.ServerName, state))
+			}
+		}
+		add("High availability", result, strings.Join(states, ", "))
+	}
+	return doctorResult(checks)
+}
+
+func doctorGetConfig(ctx context.Context, client *Client) (*doctorConfig, error) {
+	resp, err := client.dhcp4(ctx, ConfigGetRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if err = resp.Err(); err != nil {
+		return nil, err
+	}
+	var full ConfigGetResponse
+	if err = resp.Decode(&full, false); err != nil {
+		return nil, err
+	}
+	var config doctorConfig
+	for key, v := range map[string]any{
+		"hooks-libraries": &config.HooksLibraries,
+		"lease-database":  &config.LeaseDatabase,
+	} {
+		if raw, ok := full.Dhcp4[key]; ok {
+			if err = decodeJSON(raw, v, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &config, nil
+}
+
+func doctorResult(checks []doctorCheck) (*cliResult, error) {
+	var rows [][]string
+	failed := 0
+	for _, c := range checks {
+		if c.Result == checkFail {
+			failed++
+		}
+		rows = append(rows, []string{c.Name, strings.ToUpper(c.Result), c.Detail})
+	}
+	result := &cliResult{
+		header:  []string{"CHECK", "RESULT", "DETAIL"},
+		rows:    rows,
+		records: checks,
+	}
+	if failed > 0 {
+		return result, fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return result, nil
+}
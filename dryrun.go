@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Set by -dry-run: commands that change a server are logged instead of
+// sent, by the CLI and by every bulk operation of the TUI. Clients
+// refuse those sent any other way, like :raw and :broadcast.
+var dryRun bool
+
+type dryRunKey struct{}
+
+// Returns a context in which clients pass the commands that would
+// change a server to record instead of sending them, and answer them
+// with success. Commands that only read are still sent, so that the
+// operations that depend on them can be planned.
+func WithDryRun(ctx context.Context, record func(req *KeaRequest)) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, record)
+}
+
+// Returns the record function of a dry-run context, nil for others
+func dryRunRecorder(ctx context.Context) func(req *KeaRequest) {
+	record, _ := ctx.Value(dryRunKey{}).(func(req *KeaRequest))
+	return record
+}
+
+// Returns a request as the JSON sent to the Control Agent, which is
+// what a dry run logs
+func (r *KeaRequest) String() string {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return string(r.Command) + ": " + err.Error()
+	}
+	return string(data)
+}
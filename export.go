@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Exporter struct {
+	Name  string
+	Write func(w io.Writer, subnet *Subnet4, hosts []Reservation) error
+}
+
+var exporters = []Exporter{
+	{"Kea reservations", ExportKeaReservations},
+	{"dnsmasq", ExportDnsmasq},
+	{"ISC dhcpd", ExportDhcpd},
+	{"phpIPAM CSV", ExportPhpipam},
+	{"hosts file", ExportHosts},
+	{"BIND zone", ExportZone},
+}
+
+// Converts a lease into the equivalent host reservation
+func ReservationFromLease(l *Lease4) Reservation {
+	return Reservation{
+		Hostname:  l.Hostname,
+		HwAddress: l.HwAddress,
+		IpAddress: l.IpAddress,
+	}
+}
+
+// Writes the hosts as a "reservations" block that can be pasted
+// into the subnet4 section of a Kea configuration
+func ExportKeaReservations(w io.Writer, subnet *Subnet4, hosts []Reservation) error {
+	if hosts == nil {
+		hosts = []Reservation{}
+	}
+	block, err := json.MarshalIndent(hosts, "", "    ")
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(w, "\"reservations\": "); err != nil {
+		return err
+	}
+	_, err = w.Write(append(block, '\n'))
+	return err
+}
+
+// Writes one dhcp-host line per host in dnsmasq syntax
+func ExportDnsmasq(w io.Writer, subnet *Subnet4, hosts []Reservation) error {
+	for _, h := range hosts {
+		var fields []string
+		for _, f := range []string{h.HwAddress, h.IpAddress, h.Hostname} {
+			if f != "" {
+				fields = append(fields, dnsmasqValue(f))
+			}
+		}
+		if _, err := fmt.Fprintf(w, "dhcp-host=%s\n", strings.Join(fields, ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes one host block per host in ISC dhcpd syntax. Hosts without
+// a hostname, or with one that is no name in dhcpd's syntax, are named
+// after their IP address.
+func ExportDhcpd(w io.Writer, subnet *Subnet4, hosts []Reservation) error {
+	for _, h := range hosts {
+		name := h.Hostname
+		if !hostnameChars.MatchString(name) {
+			name = "host-" + strings.NewReplacer(".", "-", ":", "-").Replace(h.IpAddress)
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "host %s {\n", name)
+		if h.HwAddress != "" {
+			fmt.Fprintf(&b, "    hardware ethernet %s;\n", h.HwAddress)
+		}
+		if h.IpAddress != "" {
+			fmt.Fprintf(&b, "    fixed-address %s;\n", h.IpAddress)
+		}
+		if h.Hostname != "" {
+			fmt.Fprintf(&b, "    option host-name %s;\n", dhcpdString(h.Hostname))
+		}
+		if h.NextServer != "" {
+			fmt.Fprintf(&b, "    next-server %s;\n", h.NextServer)
+		}
+		if h.BootFileName != "" {
+			fmt.Fprintf(&b, "    filename %s;\n", dhcpdString(h.BootFileName))
+		}
+		b.WriteString("}\n")
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes the hosts as CSV in the column layout of the phpIPAM
+// address import
+func ExportPhpipam(w io.Writer, subnet *Subnet4, hosts []Reservation) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"IP address", "Hostname", "MAC address", "Description", "Subnet"})
+	for _, h := range hosts {
+		out.Write([]string{
+			h.IpAddress,
+			h.Hostname,
+			h.HwAddress,
+			"Kea subnet " + strconv.Itoa(subnet.Id),
+			subnet.Subnet,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// Writes /etc/hosts lines for the hosts that have a hostname. The
+// format has no quoting, so names with other characters than those
+// of hostnames are left out with a comment.
+func ExportHosts(w io.Writer, subnet *Subnet4, hosts []Reservation) error {
+	for _, h := range hosts {
+		if h.Hostname == "" {
+			continue
+		}
+		line := fmt.Sprintf("%s\t%s\n", h.IpAddress, h.Hostname)
+		if !hostnameChars.MatchString(h.Hostname) {
+			line = fmt.Sprintf("# %s left out, %s is no valid hostname\n", h.IpAddress, strconv.Quote(h.Hostname))
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes A and PTR records for the hosts that have a hostname. A
+// records keep the hostname as given so they pick up the $ORIGIN of
+// the zone they are pasted into, while PTR targets, which live in the
+// reverse zone, are made absolute. Single-label hostnames are
+// qualified with the ddns-qualifying-suffix of the subnet, and get no
+// PTR record when it has none.
+func ExportZone(w io.Writer, subnet *Subnet4, hosts []Reservation) error {
+	var a, ptr strings.Builder
+	suffix := strings.Trim(subnet.DdnsQualifyingSuffix, ".")
+	for _, h := range hosts {
+		ip := net.ParseIP(h.IpAddress).To4()
+		if h.Hostname == "" || ip == nil {
+			continue
+		}
+		name := zoneName(h.Hostname)
+		fmt.Fprintf(&a, "%s\tIN\tA\t%s\n", name, ip)
+		target := name
+		switch {
+		case strings.HasSuffix(target, "."):
+		case strings.Contains(target, "."):
+			target += "."
+		case suffix != "":
+			target += "." + zoneName(suffix) + "."
+		default:
+			fmt.Fprintf(&ptr, "; no PTR for %s, %s is not qualified and the subnet has no ddns-qualifying-suffix\n", ip, name)
+			continue
+		}
+		fmt.Fprintf(&ptr, "%d.%d.%d.%d.in-addr.arpa.\tIN\tPTR\t%s\n",
+			ip[3], ip[2], ip[1], ip[0], target)
+	}
+	_, err := fmt.Fprintf(w, "; A records\n%s\n; PTR records\n%s", a.String(), ptr.String())
+	return err
+}
+
+// Expands the {ip}, {mac}, {hostname}, {client-id} and {state}
+// placeholders of format with the fields of a lease
+func FormatLease(format string, l *Lease4) string {
+	state, _ := LeaseState(l.State)
+	return strings.NewReplacer(
+		"{ip}", l.IpAddress,
+		"{mac}", l.HwAddress,
+		"{hostname}", l.Hostname,
+		"{client-id}", l.ClientId,
+		"{state}", state,
+	).Replace(format)
+}
+
+// Characters of hostnames, which the formats without quoting can hold
+var hostnameChars = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Returns a value of a dnsmasq option, in double quotes with
+// backslash escapes when it holds characters that end or split it
+func dnsmasqValue(v string) string {
+	if hostnameChars.MatchString(v) || net.ParseIP(v) != nil || hexPairs.MatchString(v) {
+		return v
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range v {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteString("\\" + string(r))
+		case r == '\n':
+			b.WriteString("\\n")
+		case r == '\t':
+			b.WriteString("\\t")
+		case r < ' ' || r == 0x7f:
+			// dnsmasq has no escape for other control characters
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Returns a string of dhcpd.conf in double quotes, with backslash and
+// octal escapes
+func dhcpdString(v string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c == 0x7f:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Returns a domain name for a zone file, with the characters that
+// master files give a meaning escaped as RFC 1035 describes: \X for
+// printable ones and \DDD for the others. Dots separate labels.
+func zoneName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '*', c == '/':
+			b.WriteByte(c)
+		case c > ' ' && c < 0x7f:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "\\%03d", c)
+		}
+	}
+	return b.String()
+}
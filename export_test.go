@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportEscapes(t *testing.T) {
+	subnet := &Subnet4{Id: 1, Subnet: "192.0.2.0/24", DdnsQualifyingSuffix: "example.org."}
+	hosts := []Reservation{
+		{IpAddress: "192.0.2.5", HwAddress: "52:54:00:00:00:01", Hostname: `Bob's "Mac", 2`},
+		{IpAddress: "192.0.2.6", HwAddress: "52:54:00:00:00:02", Hostname: "printer"},
+	}
+	tests := []struct {
+		name  string
+		write func(*strings.Builder) error
+		want  string
+	}{
+		{"dnsmasq", func(b *strings.Builder) error { return ExportDnsmasq(b, subnet, hosts) },
+			"dhcp-host=52:54:00:00:00:01,192.0.2.5,\"Bob's \\\"Mac\\\", 2\"\n" +
+				"dhcp-host=52:54:00:00:00:02,192.0.2.6,printer\n"},
+		{"hosts", func(b *strings.Builder) error { return ExportHosts(b, subnet, hosts) },
+			"# 192.0.2.5 left out, \"Bob's \\\"Mac\\\", 2\" is no valid hostname\n" +
+				"192.0.2.6\tprinter\n"},
+		{"zone", func(b *strings.Builder) error { return ExportZone(b, subnet, hosts) },
+			"; A records\n" +
+				"Bob\\'s\\032\\\"Mac\\\"\\,\\0322\tIN\tA\t192.0.2.5\n" +
+				"printer\tIN\tA\t192.0.2.6\n\n" +
+				"; PTR records\n" +
+				"5.2.0.192.in-addr.arpa.\tIN\tPTR\tBob\\'s\\032\\\"Mac\\\"\\,\\0322.example.org.\n" +
+				"6.2.0.192.in-addr.arpa.\tIN\tPTR\tprinter.example.org.\n"},
+	}
+	for _, test := range tests {
+		var b strings.Builder
+		if err := test.write(&b); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if b.String() != test.want {
+			t.Errorf("%s:\n got %q\nwant %q", test.name, b.String(), test.want)
+		}
+	}
+
+	var b strings.Builder
+	if err := ExportDhcpd(&b, subnet, hosts[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); !strings.HasPrefix(got, "host host-192-0-2-5 {\n") ||
+		!strings.Contains(got, "option host-name \"Bob's \\\"Mac\\\", 2\";") {
+		t.Errorf("dhcpd:\n%s", got)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lease fields that filter terms can be scoped to, by the prefix of
+// the term
+var filterColumns = map[string]func(l *Lease4) string{
+	"host":   func(l *Lease4) string { return l.Hostname },
+	"ip":     func(l *Lease4) string { return l.IpAddress },
+	"mac":    func(l *Lease4) string { return l.HwAddress },
+	"client": func(l *Lease4) string { return l.ClientId },
+	"state": func(l *Lease4) string {
+		state, _ := LeaseState(l.State)
+		return state
+	},
+	"subnet": func(l *Lease4) string { return strconv.Itoa(l.SubnetId) },
+}
+
+// Lease times that filter terms like age<1h compare with a duration
+var filterTimes = map[string]func(l *Lease4, now time.Time) time.Duration{
+	// Since the last transaction
+	"age": func(l *Lease4, now time.Time) time.Duration {
+		return now.Sub(time.Unix(l.Cltt, 0))
+	},
+	// Until the lease expires, negative once it has
+	"expires": func(l *Lease4, now time.Time) time.Duration {
+		return time.Unix(l.Cltt+int64(l.ValidLft), 0).Sub(now)
+	},
+}
+
+// LeaseFilter is what is typed in the filter bar, an expression of
+// terms combined with AND, OR, NOT and parentheses, where terms next
+// to each other must all match. A term is
+//   - a word or quoted string, looked for ignoring case in the
+//     hostname, IP address, MAC address and client ID
+//   - column:text, which looks in one column only, like mac:aa:bb
+//   - column=text or column!=text, which compare a whole column
+//     ignoring case
+//   - column~regexp or column!~regexp, like host!~"^printer"
+//   - age or expires, followed by < or > and a duration, like age<1h
+//     for leases allocated in the last hour
+type LeaseFilter func(l *Lease4) bool
+
+// Reports whether a lease matches the filter. Every lease matches an
+// empty one.
+func (f LeaseFilter) Match(l *Lease4) bool {
+	return f == nil || f(l)
+}
+
+type filterToken struct {
+	text string
+	// Quoted strings are never keywords or operators
+	quoted bool
+}
+
+// Splits a filter into words, quoted strings, operators and
+// parentheses
+func tokenizeFilter(text string) ([]filterToken, error) {
+	var tokens []filterToken
+	isOp := func(c byte) bool { return strings.IndexByte("=~<>!", c) >= 0 }
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, filterToken{text: text[i : i+1]})
+			i++
+		case c == '"':
+			var s strings.Builder
+			j := i + 1
+			for ; j < len(text) && text[j] != '"'; j++ {
+				if text[j] == '\\' && j+1 < len(text) {
+					j++
+				}
+				s.WriteByte(text[j])
+			}
+			if j == len(text) {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, filterToken{s.String(), true})
+			i = j + 1
+		case isOp(c):
+			j := i + 1
+			if c == '!' && j < len(text) && (text[j] == '=' || text[j] == '~') {
+				j++
+			} else if c == '!' {
+				return nil, errors.New("! must be followed by = or ~")
+			}
+			tokens = append(tokens, filterToken{text: text[i:j]})
+			i = j
+		default:
+			j := i
+			for j < len(text) && strings.IndexByte(" \t()\"", text[j]) < 0 && !isOp(text[j]) {
+				j++
+			}
+			tokens = append(tokens, filterToken{text: text[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+	now    time.Time
+}
+
+func ParseLeaseFilter(text string) (LeaseFilter, error) {
+	tokens, err := tokenizeFilter(text)
+	if err != nil || len(tokens) == 0 {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens, now: time.Now()}
+	f, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return f, err
+}
+
+// Reports whether the next token is an unquoted keyword or symbol,
+// and consumes it if so
+func (p *filterParser) accept(keyword string) bool {
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].quoted &&
+		strings.EqualFold(p.tokens[p.pos].text, keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) or() (LeaseFilter, error) {
+	f, err := p.and()
+	for err == nil && p.accept("OR") {
+		var g LeaseFilter
+		if g, err = p.and(); err == nil {
+			f1 := f
+			f = func(l *Lease4) bool { return f1(l) || g(l) }
+		}
+	}
+	return f, err
+}
+
+func (p *filterParser) and() (LeaseFilter, error) {
+	f, err := p.not()
+	for err == nil && p.pos < len(p.tokens) {
+		if !p.accept("AND") {
+			// Terms next to each other are joined by AND too
+			t := p.tokens[p.pos]
+			if !t.quoted && (t.text == ")" || strings.EqualFold(t.text, "OR")) {
+				break
+			}
+		}
+		var g LeaseFilter
+		if g, err = p.not(); err == nil {
+			f1 := f
+			f = func(l *Lease4) bool { return f1(l) && g(l) }
+		}
+	}
+	return f, err
+}
+
+func (p *filterParser) not() (LeaseFilter, error) {
+	if p.accept("NOT") {
+		f, err := p.not()
+		return func(l *Lease4) bool { return !f(l) }, err
+	}
+	if p.accept("(") {
+		f, err := p.or()
+		if err == nil && !p.accept(")") {
+			err = errors.New("missing )")
+		}
+		return f, err
+	}
+	return p.term()
+}
+
+func (p *filterParser) term() (LeaseFilter, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, errors.New("incomplete filter")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	if !t.quoted && strings.IndexAny(t.text, "=~<>!()") == 0 {
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+	if p.pos+1 < len(p.tokens) && !t.quoted && !p.tokens[p.pos].quoted &&
+		strings.IndexAny(p.tokens[p.pos].text, "=~<>!") == 0 {
+		op, value := p.tokens[p.pos].text, p.tokens[p.pos+1]
+		p.pos += 2
+		return p.compare(strings.ToLower(t.text), op, value.text)
+	}
+	if name, value, ok := strings.Cut(t.text, ":"); ok && !t.quoted {
+		if column, ok := filterColumns[strings.ToLower(name)]; ok {
+			value = strings.ToLower(value)
+			return func(l *Lease4) bool {
+				return strings.Contains(strings.ToLower(column(l)), value)
+			}, nil
+		}
+	}
+	word := strings.ToLower(t.text)
+	return func(l *Lease4) bool {
+		for _, field := range []string{l.Hostname, l.IpAddress, l.HwAddress, l.ClientId} {
+			if strings.Contains(strings.ToLower(field), word) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// Returns the test of a comparison like state=declined or age<1h
+func (p *filterParser) compare(name, op, value string) (LeaseFilter, error) {
+	if leaseTime, ok := filterTimes[name]; ok {
+		limit, err := parseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		now := p.now
+		switch op {
+		case "<":
+			// Leases that expired already do not expire within
+			// any time
+			return func(l *Lease4) bool {
+				t := leaseTime(l, now)
+				return t >= 0 && t < limit
+			}, nil
+		case ">":
+			return func(l *Lease4) bool { return leaseTime(l, now) > limit }, nil
+		}
+		return nil, fmt.Errorf("%s compares with < or >", name)
+	}
+	column, ok := filterColumns[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown column %q", name)
+	}
+	switch op {
+	case "=", "!=":
+		return func(l *Lease4) bool {
+			return strings.EqualFold(column(l), value) == (op == "=")
+		}, nil
+	case "~", "!~":
+		re, err := regexp.Compile("(?i)" + value)
+		if err != nil {
+			return nil, err
+		}
+		return func(l *Lease4) bool {
+			return re.MatchString(column(l)) == (op == "~")
+		}, nil
+	}
+	return nil, fmt.Errorf("%s compares with =, !=, ~ or !~", name)
+}
+
+// Parses a duration, which unlike for time.ParseDuration can also be
+// given in days like 2d
+func parseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		return time.Duration(days) * 24 * time.Hour, err
+	}
+	return time.ParseDuration(s)
+}
+
+// Returns a lease with the fields of a reservation, so that the
+// filter bar narrows down reservations like leases. Columns that
+// reservations lack are empty, and the lease times those of a lease
+// that never had a transaction.
+func (r *Reservation) filterLease() *Lease4 {
+	return &Lease4{
+		Hostname:  r.Hostname,
+		IpAddress: r.IpAddress,
+		HwAddress: r.HwAddress,
+		State:     -1,
+	}
+}
+
+// Which leases to show by whether a reservation backs them
+type reservedFilter uint8
+
+const (
+	reservedAll reservedFilter = iota
+	reservedOnly
+	reservedDynamic
+)
+
+func (r reservedFilter) String() string {
+	switch r {
+	case reservedOnly:
+		return "reserved leases"
+	case reservedDynamic:
+		return "dynamic leases"
+	}
+	return "all leases"
+}
+
+func (r reservedFilter) match(reserved bool) bool {
+	switch r {
+	case reservedOnly:
+		return reserved
+	case reservedDynamic:
+		return !reserved
+	}
+	return true
+}
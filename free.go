@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/rivo/tview"
+)
+
+// Free addresses the free address list shows at most
+const freeListSize = 1000
+
+// Returns the first limit addresses of the pools of a subnet that are
+// neither leased nor reserved, in address order, and how many there
+// are in all. Addresses of expired-reclaimed leases are free.
+func FreeAddresses(subnet *Subnet4, leases []Lease4, limit int) ([]string, int) {
+	used := map[uint32]bool{}
+	use := func(s string) {
+		if ip := net.ParseIP(s).To4(); ip != nil {
+			used[binary.BigEndian.Uint32(ip)] = true
+		}
+	}
+	for _, l := range leases {
+		if l.State != stateExpiredReclaimed {
+			use(l.IpAddress)
+		}
+	}
+	for _, r := range subnet.Reservations {
+		use(r.IpAddress)
+	}
+	// Walking the pools in address order lists the addresses in order
+	var ranges [][2]uint32
+	for i := range subnet.Pools {
+		if first, last := subnet.Pools[i].Range(); first != nil {
+			ranges = append(ranges, [2]uint32{binary.BigEndian.Uint32(first), binary.BigEndian.Uint32(last)})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	var free []string
+	count := 0
+	// Pools can overlap, every address counts once
+	counted := map[uint32]bool{}
+	for _, r := range ranges {
+		from, to := r[0], r[1]
+		for n := from; n <= to && n >= from; n++ {
+			if used[n] || counted[n] {
+				continue
+			}
+			counted[n] = true
+			count++
+			if len(free) < limit {
+				ip := make(net.IP, net.IPv4len)
+				binary.BigEndian.PutUint32(ip, n)
+				free = append(free, ip.String())
+			}
+		}
+	}
+	return free, count
+}
+
+// Lists the free addresses of the subnet shown. Enter copies one.
+func (u *ui) showFree() {
+	server, subnet := u.current()
+	if subnet == nil {
+		u.statusline.SetText("Select a subnet to find free addresses in")
+		return
+	}
+	leases, err := server.client.Leases(u.ctx, subnet.Id).All()
+	if err != nil {
+		u.statusline.SetText(err.Error())
+		return
+	}
+	free, count := FreeAddresses(subnet, leases, freeListSize)
+	if count == 0 {
+		u.statusline.SetText("No free addresses in the pools of " + subnet.Subnet)
+		return
+	}
+	table := tview.NewTable().SetSelectable(true, false)
+	for i, ip := range free {
+		table.SetCell(i, 0, tview.NewTableCell(ip))
+	}
+	table.SetSelectedFunc(func(row, col int) {
+		ip := table.GetCell(row, 0).Text
+		if err := CopyToClipboard(ip); err != nil {
+			u.statusline.SetText(err.Error())
+			return
+		}
+		u.statusline.SetText("Copied \"" + ip + "\"")
+	})
+	title := fmt.Sprintf("%d free addresses in %s", count, subnet.Subnet)
+	if count > len(free) {
+		title += fmt.Sprintf(", first %d", len(free))
+	}
+	u.prev = u.app.GetFocus()
+	u.showTable(title, table)
+}
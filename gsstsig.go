@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format of the dates of GSS-TSIG keys, in UTC
+const gssTsigDateFormat = "2006-01-02 15:04:05.999999"
+
+// A DNS server that kea-dhcp-ddns negotiates GSS-TSIG keys with, as
+// listed by the gss_tsig hook
+type GssTsigServer struct {
+	Id              string       `json:"id"`
+	IpAddress       string       `json:"ip-address"`
+	Port            int          `json:"port"`
+	ServerPrincipal string       `json:"server-principal"`
+	TkeyLifetime    int          `json:"tkey-lifetime"`
+	Keys            []GssTsigKey `json:"keys"`
+}
+
+type GssTsigKey struct {
+	Name          string `json:"name"`
+	ServerId      string `json:"server-id"`
+	InceptionDate string `json:"inception-date"`
+	ExpireDate    string `json:"expire-date"`
+	// "not yet ready", "ready", "expired" or "in error"
+	Status string `json:"status"`
+}
+
+// Returns when the key expires, zero if it does not tell
+func (k *GssTsigKey) Expires() time.Time {
+	t, err := time.ParseInLocation(gssTsigDateFormat, k.ExpireDate, time.UTC)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// Reports whether the key can sign updates at a time
+func (k *GssTsigKey) Usable(now time.Time) bool {
+	expires := k.Expires()
+	return k.Status == "ready" && (expires.IsZero() || expires.After(now))
+}
+
+// Returns the GSS-TSIG servers of kea-dhcp-ddns and their keys, or
+// nil when the gss_tsig hook is not loaded. Versions of the hook
+// without gss-tsig-get-all only list the names of servers and keys.
+func (c *Client) GssTsig(ctx context.Context) ([]GssTsigServer, error) {
+	var all struct {
+		Servers []GssTsigServer `json:"gss-tsig-servers"`
+	}
+	err := c.d2(ctx, GssTsigGetAllRequest{}, &all)
+	var keaErr *KeaError
+	if !errors.As(err, &keaErr) || keaErr.Result != resultUnsupported {
+		if err != nil {
+			return nil, err
+		}
+		if all.Servers == nil {
+			all.Servers = []GssTsigServer{}
+		}
+		return all.Servers, nil
+	}
+	var list struct {
+		Servers []string `json:"gss-tsig-servers"`
+		Keys    []string `json:"gss-tsig-keys"`
+	}
+	err = c.d2(ctx, GssTsigListRequest{}, &list)
+	if errors.As(err, &keaErr) && keaErr.Result == resultUnsupported {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	servers := []GssTsigServer{}
+	for _, id := range list.Servers {
+		servers = append(servers, GssTsigServer{Id: id})
+	}
+	if len(list.Keys) > 0 {
+		servers = append(servers, GssTsigServer{Id: "keys of all servers"})
+		for _, name := range list.Keys {
+			servers[len(servers)-1].Keys = append(servers[len(servers)-1].Keys, GssTsigKey{Name: name})
+		}
+	}
+	return servers, nil
+}
+
+// Writes the servers and keys of the gss_tsig hook for the D2 status
+// view. Servers without a usable key are called out, since the DNS
+// updates to them fail without a word in the DHCP server.
+func writeGssTsig(b *strings.Builder, servers []GssTsigServer, err error, now time.Time) {
+	if err != nil {
+		fmt.Fprintf(b, "  Not available: %s\n", err)
+		return
+	}
+	if len(servers) == 0 {
+		b.WriteString("  No servers configured\n")
+		return
+	}
+	for _, s := range servers {
+		fmt.Fprintf(b, "  %s", s.Id)
+		if s.IpAddress != "" {
+			fmt.Fprintf(b, " (%s, %s)", s.IpAddress, s.ServerPrincipal)
+		}
+		b.WriteString("\n")
+		usable := false
+		for i := range s.Keys {
+			k := &s.Keys[i]
+			usable = usable || k.Usable(now)
+			fmt.Fprintf(b, "    %s", k.Name)
+			if k.Status != "" {
+				fmt.Fprintf(b, ", %s", k.Status)
+			}
+			if expires := k.Expires(); !expires.IsZero() {
+				if expires.After(now) {
+					fmt.Fprintf(b, ", expires in %s", expires.Sub(now).Round(time.Second))
+				} else {
+					fmt.Fprintf(b, ", EXPIRED %s ago", now.Sub(expires).Round(time.Second))
+				}
+			}
+			b.WriteString("\n")
+		}
+		// Keys listed by name only tell nothing of their state
+		if !usable && s.IpAddress != "" {
+			b.WriteString("    No usable key: secure updates to this server fail, check the Kerberos ticket of kea-dhcp-ddns\n")
+		}
+	}
+}
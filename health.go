@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Health of a server as shown by the dot in the sidebar
+type health uint8
+
+const (
+	healthUnknown health = iota
+	healthOK
+	// Reachable, but restarted since the last poll or with HA not
+	// working normally
+	healthDegraded
+	healthDown
+)
+
+// Interval between status-get polls of each server, 0 disables them
+var healthInterval = 30 * time.Second
+
+// HA states in which a server does not serve its share of clients, or
+// serves it without its partner
+var haUnhealthy = map[string]bool{
+	"partner-down":           true,
+	"partner-in-maintenance": true,
+	"in-maintenance":         true,
+	"terminated":             true,
+	"waiting":                true,
+	"syncing":                true,
+	"unavailable":            true,
+}
+
+// Returns the dot for a health with tview color tags, or nothing
+// before the first poll
+func (h health) dot() string {
+	switch h {
+	case healthOK:
+		return "[green]●[-]"
+	case healthDegraded:
+		return "[yellow]●[-]"
+	case healthDown:
+		return "[red]●[-]"
+	}
+	return ""
+}
+
+// Rates a status-get result. prevUptime is the uptime seen by the
+// previous poll, or 0.
+func rateHealth(status *KeaStatus, err error, prevUptime int) (health, string) {
+	if err != nil {
+		return healthDown, err.Error()
+	}
+	if status.Uptime < prevUptime {
+		return healthDegraded, fmt.Sprintf("restarted %s ago",
+			time.Duration(status.Uptime)*time.Second)
+	}
+	for _, ha := range status.HighAvailability {
+		for _, server := range ha.Servers {
+			if server.CommunicationInterrupted {
+				return healthDegraded, "HA communication with " + server.ServerName + " interrupted"
+			}
+			if haUnhealthy[server.CurrentState()] {
+				return healthDegraded, "HA " + server.ServerName + " " + server.CurrentState()
+			}
+		}
+	}
+	return healthOK, ""
+}
+
+// Polls status-get of a server until the context ends and updates its
+// health dot. Every server has its own poller, so a server that times
+// out does not delay the polls of the others.
+func (u *ui) pollServer(s *serverView) {
+	if healthInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+	for {
+		reqctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+		status, err := s.client.Status(reqctx)
+		cancel()
+		var stats map[int]SubnetStats
+		if err == nil && u.config.pollStats() {
+			reqctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+			stats, _ = s.client.SubnetStats(reqctx)
+			cancel()
+		}
+		var d2stats map[string]int64
+		if err == nil && u.config.D2Alert.Enabled() {
+			reqctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+			d2stats, _ = s.client.D2Stats(reqctx)
+			cancel()
+		}
+		if u.ctx.Err() != nil {
+			return
+		}
+		u.app.QueueUpdateDraw(func() {
+			uptime := 0
+			if status != nil {
+				uptime = status.Uptime
+			}
+			s.health, s.healthText = rateHealth(status, err, s.uptime)
+			s.uptime = uptime
+			u.haEvents(s, s.status, status)
+			s.status = status
+			u.updateHealth()
+			if u.config.pollStats() {
+				u.applyStats(s, stats, time.Now())
+			}
+			if u.config.D2Alert.Enabled() {
+				u.applyD2Stats(s, d2stats, time.Now())
+			}
+		})
+		select {
+		case <-u.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Redraws the health dots of the servers in the sidebar
+func (u *ui) updateHealth() {
+	if len(u.servers) == 1 {
+		u.sidebar.SetTitle(strings.TrimSpace(u.servers[0].health.dot() + " Subnets"))
+		return
+	}
+	for i, e := range u.entries {
+		if e.server != nil && e.subnet == nil {
+			u.sidebar.SetItemText(i, u.serverLabel(e.server), "")
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// A library in the hooks-libraries list of the configuration
+type HookLibrary struct {
+	Library    string         `json:"library"`
+	Parameters map[string]any `json:"parameters"`
+}
+
+// Returns the short name of a hook library, lease_cmds for
+// /usr/lib/kea/hooks/libdhcp_lease_cmds.so
+func (h *HookLibrary) Name() string {
+	name := strings.TrimSuffix(path.Base(h.Library), ".so")
+	return strings.TrimPrefix(name, "libdhcp_")
+}
+
+// Features of ybyra that depend on hook libraries, with the commands
+// they need and the service and library that provide them. Commands
+// that are only used when present, like lease4-get-page and
+// reservation-update, are left out.
+var hookFeatures = []struct {
+	feature  string
+	service  string
+	hook     string
+	commands []string
+}{
+	{"Lease tables", "dhcp4", "lease_cmds", []string{"lease4-get-all"}},
+	{"Searching leases by MAC, client ID or hostname", "dhcp4", "lease_cmds", []string{"lease4-get-by-hw-address", "lease4-get-by-client-id", "lease4-get-by-hostname"}},
+	{"Adding and deleting leases", "dhcp4", "lease_cmds", []string{"lease4-add", "lease4-del"}},
+	{"Resending DNS updates", "dhcp4", "lease_cmds", []string{"lease4-resend-ddns"}},
+	{"Changing reservations", "dhcp4", "host_cmds", []string{"reservation-add", "reservation-del"}},
+	{"Editing options (:options)", "dhcp4", "subnet_cmds", []string{"subnet4-get", "subnet4-update"}},
+	{"Editing user contexts of subnets and pools (:user-context)", "dhcp4", "subnet_cmds", []string{"subnet4-get", "subnet4-update"}},
+	{"Host cache (:cache)", "dhcp4", "host_cache", []string{"cache-get", "cache-size", "cache-clear", "cache-flush"}},
+	{"HA states", "dhcp4", "ha", []string{"ha-heartbeat"}},
+	{"GSS-TSIG keys (:d2)", serviceD2, "gss_tsig", []string{"gss-tsig-list"}},
+}
+
+// Replaces the values of secret parameters in hook parameters, at any
+// depth
+func redactSecrets(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		redacted := map[string]any{}
+		for key, value := range v {
+			if isSecretParam(key) {
+				redacted[key] = "***"
+			} else {
+				redacted[key] = redactSecrets(value)
+			}
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(v))
+		for i, value := range v {
+			redacted[i] = redactSecrets(value)
+		}
+		return redacted
+	}
+	return v
+}
+
+// Describes the hook libraries of the services of a server with their
+// parameters, and for every feature that needs a hook whether the
+// server supports it, going by the commands each service lists.
+// Services missing from commands could not be asked.
+func HooksReport(libraries map[string][]HookLibrary, commands map[string][]string) string {
+	var b strings.Builder
+	loaded := map[string]bool{}
+	for _, service := range []string{"dhcp4", serviceD2} {
+		if _, ok := commands[service]; !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "Hook libraries of %s\n", service)
+		if len(libraries[service]) == 0 {
+			b.WriteString("  none\n")
+		}
+		for i := range libraries[service] {
+			h := &libraries[service][i]
+			loaded[service+" "+h.Name()] = true
+			fmt.Fprintf(&b, "  %s (%s)\n", h.Name(), h.Library)
+			if len(h.Parameters) == 0 {
+				continue
+			}
+			data, _ := json.MarshalIndent(redactSecrets(h.Parameters), "    ", "  ")
+			fmt.Fprintf(&b, "    %s\n", data)
+		}
+		b.WriteString("\n")
+	}
+	supported := map[string]bool{}
+	for service, list := range commands {
+		for _, command := range list {
+			supported[service+" "+command] = true
+		}
+	}
+	b.WriteString("Features\n")
+	for _, f := range hookFeatures {
+		var missing []string
+		for _, command := range f.commands {
+			if !supported[f.service+" "+command] {
+				missing = append(missing, command)
+			}
+		}
+		_, asked := commands[f.service]
+		switch {
+		case !asked:
+			fmt.Fprintf(&b, "  %s: unknown, %s did not answer\n", f.feature, f.service)
+		case len(missing) == 0:
+			fmt.Fprintf(&b, "  %s: available\n", f.feature)
+		case !loaded[f.service+" "+f.hook]:
+			fmt.Fprintf(&b, "  %s: unavailable, %s is not loaded in %s\n", f.feature, f.hook, f.service)
+		default:
+			sort.Strings(missing)
+			fmt.Fprintf(&b, "  %s: unavailable, the loaded %s does not offer %s\n", f.feature, f.hook, strings.Join(missing, ", "))
+		}
+	}
+	return b.String()
+}
+
+// Shows the hook libraries of the selected server and which features
+// they make available. kea-dhcp-ddns is asked as well, and left out
+// when it does not answer.
+func (u *ui) showHooks() {
+	server, _ := u.current()
+	if server == nil || server.settings == nil {
+		u.statusline.SetText("Select a server whose configuration was read")
+		return
+	}
+	reqctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+	defer cancel()
+	dhcp4, err := server.client.ListCommands(reqctx, "dhcp4")
+	if err != nil {
+		u.statusline.SetText(err.Error())
+		return
+	}
+	libraries := map[string][]HookLibrary{"dhcp4": server.settings.HooksLibraries}
+	commands := map[string][]string{"dhcp4": dhcp4}
+	if d2, err := server.client.ListCommands(reqctx, serviceD2); err == nil {
+		commands[serviceD2] = d2
+		var config struct {
+			HooksLibraries []HookLibrary `json:"hooks-libraries"`
+		}
+		if raw, err := server.client.RawConfig(reqctx, serviceD2); err == nil {
+			json.Unmarshal(raw, &config)
+		}
+		libraries[serviceD2] = config.HooksLibraries
+	}
+	u.prev = u.app.GetFocus()
+	u.showText("Hooks of "+server.name, HooksReport(libraries, commands))
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Events the TUI runs hooks for
+const (
+	// A lease appeared in the subnet shown
+	eventNewLease = "new-lease"
+	// A subnet ran low on free addresses
+	eventPoolExhausted = "pool-exhausted"
+	// The declined addresses of a subnet rose quickly
+	eventDeclinesRising = "declines-rising"
+	// An HA server changed state
+	eventHAState = "ha-state"
+	// The DNS updates of kea-dhcp-ddns failed repeatedly
+	eventDdnsFailing = "ddns-failing"
+)
+
+// Hook is a command run or a URL posted to on events, with the event
+// as JSON on standard input or as the body
+type Hook struct {
+	// Events the hook runs on, all if empty
+	Events []string `json:"events,omitempty"`
+	// Lease filter new-lease events must match, see LeaseFilter
+	Filter string `json:"filter,omitempty"`
+	// Command and its arguments
+	Command []string `json:"command,omitempty"`
+	URL     string   `json:"url,omitempty"`
+}
+
+// Event is what a hook is passed. Which fields are set depends on
+// the type.
+type Event struct {
+	Type   string    `json:"event"`
+	Time   time.Time `json:"time"`
+	Server string    `json:"server"`
+	Subnet string    `json:"subnet,omitempty"`
+	// New lease
+	Lease *Lease4 `json:"lease,omitempty"`
+	// HA state change
+	HAServer      string `json:"ha-server,omitempty"`
+	State         string `json:"state,omitempty"`
+	PreviousState string `json:"previous-state,omitempty"`
+	// What happened, in the words of the alert banner
+	Text string `json:"text,omitempty"`
+}
+
+// Reports whether a hook runs on an event
+func (h *Hook) matches(e *Event) (bool, error) {
+	match := len(h.Events) == 0
+	for _, t := range h.Events {
+		match = match || t == e.Type
+	}
+	if !match || e.Lease == nil || h.Filter == "" {
+		return match, nil
+	}
+	filter, err := ParseLeaseFilter(h.Filter)
+	if err != nil {
+		return false, fmt.Errorf("filter %q: %w", h.Filter, err)
+	}
+	return filter.Match(e.Lease), nil
+}
+
+// Runs the command of a hook or posts to its URL
+func (h *Hook) run(ctx context.Context, e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if len(h.Command) > 0 {
+		cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Env = append(os.Environ(), "YBYRA_EVENT="+e.Type)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %w: %s", h.Command[0], err, bytes.TrimSpace(out))
+		}
+	}
+	if h.URL != "" {
+		req, err := http.NewRequestWithContext(ctx, "POST", h.URL, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("%s: %s", h.URL, resp.Status)
+		}
+	}
+	return nil
+}
+
+// Notifies of an event if it alerts and runs its hooks in the
+// background. Failures of hooks are shown in the status line.
+func (u *ui) fireEvent(e Event) {
+	e.Time = time.Now()
+	u.notify(&e)
+	for i := range u.config.Hooks {
+		h := &u.config.Hooks[i]
+		match, err := h.matches(&e)
+		if err == nil && !match {
+			continue
+		}
+		go func() {
+			if err == nil {
+				ctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+				err = h.run(ctx, &e)
+				cancel()
+			}
+			if err != nil {
+				u.app.QueueUpdateDraw(func() {
+					u.statusline.SetText(e.Type + " hook: " + err.Error())
+				})
+			}
+		}()
+	}
+}
+
+// Returns the HA state of each server a status reports, by name
+func haStates(status *KeaStatus) map[string]string {
+	states := map[string]string{}
+	if status == nil {
+		return states
+	}
+	for _, ha := range status.HighAvailability {
+		for _, server := range ha.Servers {
+			states[server.ServerName] = server.CurrentState()
+		}
+	}
+	return states
+}
+
+// Fires an event for each HA server whose state differs between two
+// polls of a server. Servers that one of the polls lacks are not
+// compared, so a poll that failed changes nothing.
+func (u *ui) haEvents(s *serverView, prev, status *KeaStatus) {
+	if prev == nil || status == nil {
+		return
+	}
+	before := haStates(prev)
+	for name, state := range haStates(status) {
+		if old, ok := before[name]; ok && old != state {
+			u.fireEvent(Event{
+				Type:          eventHAState,
+				Server:        s.name,
+				HAServer:      name,
+				State:         state,
+				PreviousState: old,
+				Text:          fmt.Sprintf("HA %s %s → %s", name, old, state),
+			})
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Entries the host cache panel offers to flush
+var cacheFlushCounts = []string{"10", "100", "1000"}
+
+// A host in the cache of the host_cache hook, as far as the panel
+// shows it
+type CachedHost struct {
+	SubnetId  int    `json:"subnet-id4"`
+	IpAddress string `json:"ip-address"`
+	HwAddress string `json:"hw-address"`
+	ClientId  string `json:"client-id"`
+	Hostname  string `json:"hostname"`
+}
+
+// Returns the identifier of a cached host
+func (h *CachedHost) Identifier() string {
+	if h.HwAddress != "" {
+		return h.HwAddress
+	}
+	return h.ClientId
+}
+
+// Returned by the host cache commands of servers without the hook
+var ErrNoHostCache = errors.New("the host_cache hook is not loaded")
+
+// Sends a command of the host_cache hook and decodes its arguments
+// into v, if given. A server without the hook returns ErrNoHostCache.
+func (c *Client) hostCache(ctx context.Context, req Request, v any) error {
+	resp, err := c.dhcp4(ctx, req)
+	if err != nil {
+		return err
+	}
+	if resp.Result == resultUnsupported {
+		return ErrNoHostCache
+	}
+	if err = resp.Err(); err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	return resp.Decode(v, false)
+}
+
+// Returns the number of hosts in the cache of the host_cache hook
+func (c *Client) CacheSize(ctx context.Context) (int, error) {
+	var size struct {
+		Size int `json:"size"`
+	}
+	err := c.hostCache(ctx, CacheSizeRequest{}, &size)
+	return size.Size, err
+}
+
+// Returns the hosts in the cache of the host_cache hook
+func (c *Client) CacheGet(ctx context.Context) ([]CachedHost, error) {
+	var hosts []CachedHost
+	err := c.hostCache(ctx, CacheGetRequest{}, &hosts)
+	return hosts, err
+}
+
+// Empties the cache of the host_cache hook
+func (c *Client) CacheClear(ctx context.Context) error {
+	return c.hostCache(ctx, CacheClearRequest{}, nil)
+}
+
+// Removes the oldest count hosts from the cache of the host_cache hook
+func (c *Client) CacheFlush(ctx context.Context, count int) error {
+	return c.hostCache(ctx, CacheFlushRequest{count}, nil)
+}
+
+// Shows the hosts the host_cache hook of the selected server caches,
+// which it answers with instead of the host database. c clears the
+// cache and f flushes its oldest entries, for when cached
+// reservations no longer match the database.
+func (u *ui) showHostCache() {
+	server, _ := u.current()
+	if server == nil {
+		u.statusline.SetText("Select a server first")
+		return
+	}
+	reqctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+	size, err := server.client.CacheSize(reqctx)
+	var hosts []CachedHost
+	if err == nil {
+		hosts, err = server.client.CacheGet(reqctx)
+	}
+	cancel()
+	if err != nil {
+		u.statusline.SetText(server.name + ": " + err.Error())
+		return
+	}
+	table := tview.NewTable().
+		SetSeparator(tview.Borders.Vertical).
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	for i, name := range []string{"Subnet ID", "IP", "Identifier", "Hostname"} {
+		table.SetCell(0, i, tview.NewTableCell(name).
+			SetTextColor(tcell.ColorYellow).
+			SetSelectable(false))
+	}
+	for i := range hosts {
+		h := &hosts[i]
+		for col, text := range []string{strconv.Itoa(h.SubnetId), h.IpAddress, h.Identifier(), h.Hostname} {
+			table.SetCell(i+1, col, tview.NewTableCell(text))
+		}
+	}
+	// Sends a cache command, or queues it, and shows the cache again
+	// once it ran
+	run := func(what string, f func(ctx context.Context) error) {
+		items := []bulkItem{{label: server.name, run: f}}
+		u.prev = u.table
+		u.submit(what, items, 0, dryRun, u.showHostCache)
+	}
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'c':
+			u.pages.RemovePage("popup")
+			u.confirm(fmt.Sprintf("Clear the host cache of %s?", server.name), func() {
+				run("Clearing the host cache", server.client.CacheClear)
+			})
+		case 'f':
+			u.pages.RemovePage("popup")
+			u.ask("Flush how many of the oldest cached hosts?", append([]string{"Cancel"}, cacheFlushCounts...), func(label string) {
+				if count, err := strconv.Atoi(label); err == nil {
+					run("Flushing "+label+" cached hosts", func(ctx context.Context) error {
+						return server.client.CacheFlush(ctx, count)
+					})
+				}
+			})
+		default:
+			return event
+		}
+		return nil
+	})
+	u.prev = u.table
+	u.showTable(fmt.Sprintf("Host cache of %s: %d hosts, c clears, f flushes", server.name, size), table)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Depth down to which JSON trees start expanded
+const jsonExpandDepth = 2
+
+// Returns a tree of a JSON document. Objects and arrays are nodes that
+// Enter collapses and expands, with a child per member, scalars are
+// leaves showing their value.
+func NewJSONTree(data []byte) (*tview.TreeView, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	root := jsonNode("", v, 0)
+	tree := tview.NewTreeView().SetRoot(root).SetCurrentNode(root)
+	tree.SetSelectedFunc(func(node *tview.TreeNode) {
+		node.SetExpanded(!node.IsExpanded())
+	})
+	return tree, nil
+}
+
+func jsonNode(label string, v any, depth int) *tview.TreeNode {
+	if label != "" {
+		label += ": "
+	}
+	var node *tview.TreeNode
+	switch v := v.(type) {
+	case map[string]any:
+		node = tview.NewTreeNode(fmt.Sprintf("%s{%d}", label, len(v))).
+			SetColor(tcell.ColorYellow)
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			node.AddChild(jsonNode(k, v[k], depth+1))
+		}
+	case []any:
+		node = tview.NewTreeNode(fmt.Sprintf("%s[%d]", label, len(v))).
+			SetColor(tcell.ColorYellow)
+		for i, e := range v {
+			node.AddChild(jsonNode(fmt.Sprint(i), e, depth+1))
+		}
+	default:
+		data, _ := json.Marshal(v)
+		return tview.NewTreeNode(label + string(data))
+	}
+	return node.SetExpanded(depth < jsonExpandDepth)
+}
+
+// Returns a table cell showing JSON data on one line, which showJSON
+// can expand
+func jsonCell(data []byte) *tview.TableCell {
+	var b bytes.Buffer
+	if json.Compact(&b, data) != nil {
+		b.Reset()
+		b.Write(data)
+	}
+	return tview.NewTableCell(b.String()).
+		SetMaxWidth(60).
+		SetReference(json.RawMessage(data))
+}
+
+// Reports whether a value is a JSON object or array, which are worth
+// showing as a tree
+func isJSONTree(value string) bool {
+	data := bytes.TrimSpace([]byte(value))
+	return len(data) > 0 && (data[0] == '{' || data[0] == '[') && json.Valid(data)
+}
+
+// Shows JSON data as a tree in a popup over any other. Escape closes
+// it and returns to what had focus.
+func (u *ui) showJSON(title string, data []byte) {
+	tree, err := NewJSONTree(data)
+	if err != nil {
+		u.statusline.SetText(err.Error())
+		return
+	}
+	prev := u.app.GetFocus()
+	tree.SetBorder(true)
+	tree.SetTitle(title)
+	tree.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			u.pages.RemovePage("json")
+			u.app.SetFocus(prev)
+		}
+	})
+	u.pages.AddPage("json", tree, true, true)
+	u.app.SetFocus(tree)
+}
+
+// Shows the configuration of a service of the selected server as a
+// tree, like :config d2 for the DDNS domains, keys and DNS servers of
+// kea-dhcp-ddns. The service is dhcp4 if not given.
+func (u *ui) showConfig(args string) {
+	service := strings.TrimSpace(args)
+	if service == "" {
+		service = "dhcp4"
+	}
+	server, _ := u.current()
+	if server == nil {
+		u.statusline.SetText("Select a server first")
+		return
+	}
+	reqctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+	config, err := server.client.RawConfig(reqctx, service)
+	cancel()
+	if err != nil {
+		u.statusline.SetText(err.Error())
+		return
+	}
+	u.showJSON(configSections[service]+" of "+server.name, config)
+}
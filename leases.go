@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of leases requested per lease4-get-page call
+const leasePageSize = 1000
+
+// LeaseIterator walks the leases of the server one page at a time,
+// each page a request of its own bounded by the client's timeout.
+// The leases of given subnets, and those of servers without
+// lease4-get-page, by version or because the hook does not provide
+// it, are read with a single lease4-get-all instead.
+type LeaseIterator struct {
+	client  *Client
+	ctx     context.Context
+	subnets map[int]bool
+	from    string
+	page    []Lease4
+	lease   Lease4
+	done    bool
+	err     error
+}
+
+// Returns an iterator over the leases of the given subnets, or over
+// all leases when no subnet is given
+func (c *Client) Leases(ctx context.Context, subnets ...int) *LeaseIterator {
+	it := &LeaseIterator{client: c, ctx: ctx, from: "start"}
+	if len(subnets) > 0 {
+		it.subnets = make(map[int]bool)
+		for _, id := range subnets {
+			it.subnets[id] = true
+		}
+	}
+	return it
+}
+
+// Advances to the next lease, fetching a new page when the current
+// one is exhausted. Returns false at the end or on error.
+func (it *LeaseIterator) Next() bool {
+	for {
+		for len(it.page) > 0 {
+			it.lease = it.page[0]
+			it.page = it.page[1:]
+			if it.subnets == nil || it.subnets[it.lease.SubnetId] {
+				return true
+			}
+		}
+		if it.done || it.err != nil {
+			return false
+		}
+		it.fetch()
+	}
+}
+
+func (it *LeaseIterator) fetch() {
+	if it.subnets != nil || !it.client.Compat.Has(FeatureLeasePaging) {
+		it.fetchAll()
+		return
+	}
+	resp, err := it.client.dhcp4(it.ctx, Lease4GetPageRequest{From: it.from, Limit: leasePageSize})
+	if err != nil {
+		it.err = err
+		return
+	}
+	switch resp.Result {
+	case resultEmpty:
+		it.done = true
+		return
+	case resultUnsupported:
+		it.fetchAll()
+		return
+	}
+	if it.err = resp.Err(); it.err != nil {
+		return
+	}
+	var page Lease4GetPageResponse
+	if it.err = resp.Decode(&page, it.client.Strict); it.err != nil {
+		return
+	}
+	if it.err = checkStrict(page.Leases, it.client.Strict); it.err != nil {
+		return
+	}
+	it.page = page.Leases
+	if len(page.Leases) < leasePageSize {
+		it.done = true
+	} else {
+		it.from = page.Leases[len(page.Leases)-1].IpAddress
+	}
+}
+
+func (it *LeaseIterator) fetchAll() {
+	it.done = true
+	req := Lease4GetAllRequest{}
+	for id := range it.subnets {
+		req.Subnets = append(req.Subnets, id)
+	}
+	resp, err := it.client.dhcp4(it.ctx, req)
+	if err != nil {
+		it.err = err
+		return
+	}
+	if it.err = resp.Err(); it.err != nil {
+		return
+	}
+	var all Lease4GetAllResponse
+	if it.err = resp.Decode(&all, it.client.Strict); it.err == nil {
+		it.err = checkStrict(all.Leases, it.client.Strict)
+	}
+	it.page = all.Leases
+}
+
+// Returns the current lease
+func (it *LeaseIterator) Lease() *Lease4 {
+	return &it.lease
+}
+
+// Returns the error that stopped the iteration, if any
+func (it *LeaseIterator) Err() error {
+	return it.err
+}
+
+// Drains the iterator into a slice
+func (it *LeaseIterator) All() ([]Lease4, error) {
+	var leases []Lease4
+	for it.Next() {
+		leases = append(leases, it.lease)
+	}
+	return leases, it.err
+}
+
+// Decodes a lease, keeping the fields that Lease4 does not model in
+// Extra
+func (l *Lease4) UnmarshalJSON(data []byte) error {
+	type plain Lease4
+	if err := json.Unmarshal(data, (*plain)(l)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, name := range jsonFields(reflect.TypeOf(plain{})) {
+		delete(fields, name)
+	}
+	l.Extra = nil
+	if len(fields) > 0 {
+		l.Extra = fields
+	}
+	l.ddnsStatus = parseDdnsStatus(l)
+	return nil
+}
+
+// Returns an error naming the fields that Lease4 does not model, in
+// strict mode. Decoding keeps them in Extra, past the decoder's check.
+func checkStrict(leases []Lease4, strict bool) error {
+	if !strict {
+		return nil
+	}
+	for i := range leases {
+		if len(leases[i].Extra) == 0 {
+			continue
+		}
+		var names []string
+		for name := range leases[i].Extra {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("lease %s: unknown fields %s", leases[i].IpAddress, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// Encodes a lease together with the fields kept in Extra
+func (l Lease4) MarshalJSON() ([]byte, error) {
+	type plain Lease4
+	data, err := json.Marshal(plain(l))
+	if err != nil || len(l.Extra) == 0 {
+		return data, err
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range l.Extra {
+		if _, ok := fields[name]; !ok {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+var hexPairs = regexp.MustCompile(`^[0-9a-fA-F]{2}([:-][0-9a-fA-F]{2})+$`)
+
+// Returns the lease query for an identifier: an IP address, a MAC
+// address, a longer hex string taken as client-id, or a hostname
+func LeaseQuery(id string) Request {
+	switch {
+	case net.ParseIP(id) != nil:
+		return Lease4GetRequest{IpAddress: id}
+	case hexPairs.MatchString(id) && len(id) == 17:
+		return Lease4GetByHwAddressRequest{HwAddress: id}
+	case hexPairs.MatchString(id):
+		return Lease4GetByClientIdRequest{ClientId: id}
+	}
+	return Lease4GetByHostnameRequest{Hostname: id}
+}
+
+// Looks up the leases matching an identifier, see LeaseQuery
+func (c *Client) FindLeases(ctx context.Context, id string) ([]Lease4, error) {
+	req := LeaseQuery(id)
+	if _, byIp := req.(Lease4GetRequest); !byIp && !c.Compat.Has(FeatureLeaseLookup) {
+		return nil, fmt.Errorf("%s needs Kea 1.7 or later", req.Command())
+	}
+	resp, err := c.dhcp4(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err = resp.Err(); err != nil || resp.Result == resultEmpty {
+		return nil, err
+	}
+	if _, byIp := req.(Lease4GetRequest); byIp {
+		var lease Lease4
+		if err = resp.Decode(&lease, c.Strict); err == nil {
+			err = checkStrict([]Lease4{lease}, c.Strict)
+		}
+		return []Lease4{lease}, err
+	}
+	var leases Lease4GetAllResponse
+	if err = resp.Decode(&leases, c.Strict); err == nil {
+		err = checkStrict(leases.Leases, c.Strict)
+	}
+	return leases.Leases, err
+}
+
+// Returns what a client ID in Kea's colon separated hex decodes to:
+// the MAC address of the common type 1 form, or else the ID as ASCII
+// with dots for unprintable bytes, as some clients send their name
+func ClientIdFields(id string) [][]string {
+	data, err := hex.DecodeString(strings.ReplaceAll(id, ":", ""))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	if data[0] == 1 && len(data) == 7 {
+		return [][]string{{"Client ID MAC", net.HardwareAddr(data[1:]).String()}}
+	}
+	text := make([]byte, len(data))
+	for i, b := range data {
+		text[i] = '.'
+		if isPrintable([]byte{b}) {
+			text[i] = b
+		}
+	}
+	return [][]string{{"Client ID ASCII", string(text)}}
+}
+
+// Returns the fields of a lease as name and value pairs for
+// detailed display
+func LeaseFields(l *Lease4) [][]string {
+	state, _ := LeaseState(l.State)
+	cltt := time.Unix(l.Cltt, 0)
+	expires := cltt.Add(time.Duration(l.ValidLft) * time.Second)
+	fields := [][]string{
+		{"IP address", l.IpAddress},
+		{"MAC address", l.HwAddress},
+	}
+	if vendor := macVendor(l.HwAddress); vendor != "" {
+		fields = append(fields, []string{"Vendor", vendor})
+	}
+	fields = append(fields, []string{"Client ID", l.ClientId})
+	fields = append(fields, ClientIdFields(l.ClientId)...)
+	fields = append(fields, [][]string{
+		{"Hostname", l.Hostname},
+		{"State", state},
+		{"Subnet ID", strconv.Itoa(l.SubnetId)},
+		{"Valid lifetime", (time.Duration(l.ValidLft) * time.Second).String()},
+		{"Last transaction", cltt.Format(timeFormat)},
+		{"Expires", expires.Format(timeFormat)},
+		{"DDNS", DdnsFlags(l)},
+	}...)
+	if ddnsStatusKey != "" {
+		fields = append(fields, []string{"DNS status", DdnsStatus(l)})
+	}
+	if l.PoolId != 0 {
+		fields = append(fields, []string{"Pool ID", strconv.Itoa(l.PoolId)})
+	}
+	fields = append(fields, ExtendedInfoFields(l)...)
+	if len(l.UserContext) > 0 {
+		fields = append(fields, []string{"User context", string(l.UserContext)})
+	}
+	var extra []string
+	for name := range l.Extra {
+		extra = append(extra, name)
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		fields = append(fields, []string{name, string(l.Extra[name])})
+	}
+	return fields
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Largest count typed before a motion
+const maxMotionCount = 9999
+
+// Translates vim motions for whichever list or table has focus, the
+// main ones or a popup, before the widget and its own keys see them.
+// Lists move with j and k. In tables, a count typed before j, k, h,
+// l, their arrow keys, G, H, L, Ctrl-F and Ctrl-B repeats them, 0 and
+// $ go to the first and last column, H, M and L to the top, middle
+// and bottom row on screen, and Ctrl-F and Ctrl-B page. Without a
+// count, j, k, h, l and G are left to the table.
+func (u *ui) motionKeys(event *tcell.EventKey) *tcell.EventKey {
+	table, ok := u.app.GetFocus().(*tview.Table)
+	if !ok {
+		u.count = 0
+		if _, isList := u.app.GetFocus().(*tview.List); isList {
+			return listMotion(event)
+		}
+		return event
+	}
+	count := u.count
+	u.count = 0
+	if r := event.Rune(); event.Key() == tcell.KeyRune && (r >= '1' && r <= '9' || r == '0' && count > 0) {
+		u.count = count*10 + int(r-'0')
+		if u.count > maxMotionCount {
+			u.count = maxMotionCount
+		}
+		return nil
+	}
+	if !TableMotion(table, count, event) {
+		return event
+	}
+	return nil
+}
+
+// Translates j and k to the arrow keys lists move with
+func listMotion(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Rune() {
+	case 'j':
+		return tcell.NewEventKey(tcell.KeyDown, 258, tcell.ModNone)
+	case 'k':
+		return tcell.NewEventKey(tcell.KeyUp, 257, tcell.ModNone)
+	}
+	return event
+}
+
+// Moves in a table for a motion key with a count, 0 for none, and
+// reports whether the key was one
+func TableMotion(table *tview.Table, count int, event *tcell.EventKey) bool {
+	n := count
+	if n == 0 {
+		n = 1
+	}
+	row, col := tableCursor(table)
+	// tview draws the rows after the fixed ones from the offset on
+	fixed := fixedRows(table)
+	offset, _ := table.GetOffset()
+	_, _, _, height := table.GetInnerRect()
+	page := height - 1
+	if page < 1 {
+		page = 1
+	}
+	switch event.Key() {
+	case tcell.KeyCtrlF:
+		moveRow(table, row+n*page, 1)
+		return true
+	case tcell.KeyCtrlB:
+		moveRow(table, row-n*page, -1)
+		return true
+	case tcell.KeyDown:
+		return count > 0 && moveRow(table, row+n, 1)
+	case tcell.KeyUp:
+		return count > 0 && moveRow(table, row-n, -1)
+	case tcell.KeyRight:
+		return count > 0 && moveColumn(table, col+n)
+	case tcell.KeyLeft:
+		return count > 0 && moveColumn(table, col-n)
+	case tcell.KeyRune:
+	default:
+		return false
+	}
+	switch event.Rune() {
+	case '0':
+		return moveColumn(table, 0)
+	case '$':
+		return moveColumn(table, table.GetColumnCount()-1)
+	case 'H':
+		return moveRow(table, fixed+offset+n-1, 1)
+	case 'M':
+		return moveRow(table, fixed+offset+(height-fixed-1)/2, 1)
+	case 'L':
+		return moveRow(table, offset+height-n, -1)
+	case 'j':
+		return count > 0 && moveRow(table, row+n, 1)
+	case 'k':
+		return count > 0 && moveRow(table, row-n, -1)
+	case 'l':
+		return count > 0 && moveColumn(table, col+n)
+	case 'h':
+		return count > 0 && moveColumn(table, col-n)
+	case 'G':
+		return count > 0 && moveRow(table, count-1, 1)
+	}
+	return false
+}
+
+// Returns the selected row and column of a table, or the top left
+// cell shown when it selects neither
+func tableCursor(table *tview.Table) (int, int) {
+	row, col := table.GetOffset()
+	rows, cols := table.GetSelectable()
+	selRow, selCol := table.GetSelection()
+	if rows {
+		row = selRow
+	}
+	if cols {
+		col = selCol
+	}
+	return row, col
+}
+
+// Selects a row, or scrolls to it when the table does not select
+// rows. Rows that cannot be selected are skipped in direction dir,
+// and back the other way at the ends of the table. Reports whether
+// the table has rows.
+func moveRow(table *tview.Table, row, dir int) bool {
+	last := table.GetRowCount() - 1
+	if last < 0 {
+		return false
+	}
+	row = clampInt(row, 0, last)
+	rows, _ := table.GetSelectable()
+	_, col := tableCursor(table)
+	if !rows {
+		_, offset := table.GetOffset()
+		table.SetOffset(row, offset)
+		return true
+	}
+	for _, d := range []int{dir, -dir} {
+		for r := row; r >= 0 && r <= last; r += d {
+			if cell := table.GetCell(r, col); cell == nil || !cell.NotSelectable {
+				table.Select(r, col)
+				return true
+			}
+		}
+	}
+	return true
+}
+
+// Selects a column in the selected row, or scrolls to it when the
+// table does not select columns. Reports whether the table has
+// columns.
+func moveColumn(table *tview.Table, col int) bool {
+	last := table.GetColumnCount() - 1
+	if last < 0 {
+		return false
+	}
+	col = clampInt(col, 0, last)
+	if _, cols := table.GetSelectable(); cols {
+		row, _ := table.GetSelection()
+		table.Select(row, col)
+		return true
+	}
+	offset, _ := table.GetOffset()
+	table.SetOffset(offset, col)
+	return true
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Pushes hosts into the IPAM of a NetBox instance
+type NetBox struct {
+	URL   string
+	Token string
+}
+
+var netbox NetBox
+
+// Client of the NetBox API. Each request gets at most the timeout,
+// and the context it is made with can cancel it earlier.
+var netboxClient = &http.Client{Timeout: 10 * time.Second}
+
+type netboxAddress struct {
+	Id          int    `json:"id,omitempty"`
+	Address     string `json:"address"`
+	DnsName     string `json:"dns_name"`
+	Description string `json:"description"`
+	Status      string `json:"status"`
+}
+
+type netboxAddressList struct {
+	Count   int             `json:"count"`
+	Results []netboxAddress `json:"results"`
+}
+
+func (nb *NetBox) do(ctx context.Context, method string, path string, body any, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewBuffer(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(nb.URL, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+nb.Token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := netboxClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", resp.Status, respBody)
+	}
+	if result != nil {
+		return json.Unmarshal(respBody, result)
+	}
+	return nil
+}
+
+// Creates or updates one NetBox IP address per host, mapping the
+// hostname to dns_name and the MAC into the description. Writes one
+// result line per host, and returns an error naming the hosts that
+// failed, if any. Cancelling ctx stops the push before the next host.
+func (nb *NetBox) Push(ctx context.Context, w io.Writer, subnet *Subnet4, hosts []Reservation) error {
+	if nb.URL == "" {
+		return fmt.Errorf("NetBox URL not set, use -netbox-url or NETBOX_URL")
+	}
+	_, prefixlen, _ := strings.Cut(subnet.Subnet, "/")
+	var failed []string
+	for _, h := range hosts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		addr := netboxAddress{
+			Address:     h.IpAddress + "/" + prefixlen,
+			DnsName:     h.Hostname,
+			Description: "MAC " + h.HwAddress,
+			Status:      "dhcp",
+		}
+		var existing netboxAddressList
+		err := nb.do(ctx, "GET", "/api/ipam/ip-addresses/?address="+url.QueryEscape(h.IpAddress), nil, &existing)
+		if err == nil && existing.Count > 0 {
+			err = nb.do(ctx, "PATCH", fmt.Sprintf("/api/ipam/ip-addresses/%d/", existing.Results[0].Id), addr, nil)
+			if err == nil {
+				fmt.Fprintf(w, "updated %s\n", addr.Address)
+				continue
+			}
+		} else if err == nil {
+			err = nb.do(ctx, "POST", "/api/ipam/ip-addresses/", addr, nil)
+			if err == nil {
+				fmt.Fprintf(w, "created %s\n", addr.Address)
+				continue
+			}
+		}
+		fmt.Fprintf(w, "failed  %s: %s\n", addr.Address, err)
+		failed = append(failed, fmt.Sprintf("%s: %s", addr.Address, err))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("NetBox: %d of %d hosts failed: %s", len(failed), len(hosts), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Asks for confirmation and pushes the hosts of a subnet of a server
+// to NetBox in the background, showing the result lines when done.
+// NetBox is written to, so this is refused with -dry-run and for
+// read-only servers.
+func (u *ui) pushNetBox(server *serverView, subnet *Subnet4, hosts []Reservation) {
+	switch {
+	case netbox.URL == "":
+		u.statusline.SetText("NetBox URL not set, use -netbox-url or NETBOX_URL")
+		return
+	case dryRun:
+		u.statusline.SetText("Not pushing to NetBox in a dry run")
+		return
+	case server.client.ReadOnly:
+		u.statusline.SetText("Not pushing to NetBox: " + ErrReadOnly.Error())
+		return
+	}
+	u.confirm(fmt.Sprintf("Push %d hosts to NetBox at %s?", len(hosts), netbox.URL), func() {
+		u.statusline.SetText(fmt.Sprintf("Pushing %d hosts to NetBox…", len(hosts)))
+		go func() {
+			var out strings.Builder
+			err := netbox.Push(u.ctx, &out, subnet, hosts)
+			u.app.QueueUpdateDraw(func() {
+				// What was written before failing shows how far it got
+				if out.Len() > 0 {
+					u.showText("NetBox", out.String())
+				}
+				if err != nil {
+					u.statusline.SetText(err.Error())
+					return
+				}
+				u.statusline.SetText(fmt.Sprintf("Pushed %d hosts to NetBox", len(hosts)))
+			})
+		}()
+	})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Returns the shared network selected in the sidebar, if one is
+func (u *ui) currentNetwork() string {
+	i := u.sidebar.GetCurrentItem()
+	if i < 0 || i >= len(u.entries) {
+		return ""
+	}
+	return u.entries[i].network
+}
+
+// Returns the subnets of a server in a shared network
+func (s *serverView) networkSubnets(network string) []*Subnet4 {
+	var subnets []*Subnet4
+	for i := range s.subnets {
+		if s.subnets[i].SharedNetwork == network {
+			subnets = append(subnets, &s.subnets[i])
+		}
+	}
+	return subnets
+}
+
+// Fetches the leases of all subnets of a shared network of a server
+func (s *serverView) networkLeases(ctx context.Context, network string) ([]Lease4, error) {
+	var ids []int
+	for _, subnet := range s.networkSubnets(network) {
+		ids = append(ids, subnet.Id)
+	}
+	return s.client.Leases(ctx, ids...).All()
+}
+
+// Shows the leases of all subnets of a shared network in one table,
+// with the subnet in the first column, as clients of the network can
+// get addresses from any of them. Column 0 sorts by subnet, the others
+// like the lease table. Returns the title of the table, which sums up
+// the leases and pool addresses of the network.
+func SharedNetworkTable(server *serverView, network string, leases []Lease4, table *tview.Table, sortorder *[]SortData, selected map[string]bool) string {
+	table.Clear()
+	resort := func(col int) func() bool {
+		return func() bool {
+			(*sortorder)[0].Column = col
+			(*sortorder)[0].Asc = !(*sortorder)[0].Asc
+			SharedNetworkTable(server, network, leases, table, sortorder, selected)
+			return false
+		}
+	}
+	table.SetCell(0, 0, tview.NewTableCell("Subnet").
+		SetTextColor(tcell.ColorYellow).
+		SetClickedFunc(resort(0)))
+	for i, field := range shownLeaseFields() {
+		table.SetCell(0, i+1, tview.NewTableCell(leaseHeader[field]).
+			SetTextColor(tcell.ColorYellow).
+			SetClickedFunc(resort(field+1)))
+	}
+	subnets := server.networkSubnets(network)
+	names := map[int]string{}
+	var poolSize uint64
+	for _, subnet := range subnets {
+		names[subnet.Id] = subnet.Subnet
+		if m, err := NewSubnetMath(subnet.Subnet, subnet.Pools); err == nil {
+			poolSize += m.PoolSize
+		}
+	}
+	column := (*sortorder)[0].Column
+	sort.SliceStable(leases, func(i, j int) bool {
+		c := 0
+		if column == 0 {
+			c = cmp(leases[i].SubnetId, leases[j].SubnetId)
+		} else {
+			c = leases[i].Compare(&leases[j], column-1)
+		}
+		if (*sortorder)[0].Asc {
+			return c < 0
+		}
+		return c > 0
+	})
+	reserved := map[string]bool{}
+	for _, subnet := range subnets {
+		for _, r := range subnet.Reservations {
+			reserved[r.IpAddress] = true
+		}
+	}
+	active := 0
+	for i := range leases {
+		l := &leases[i]
+		if l.State == stateDefault {
+			active++
+		}
+		table.SetCell(i+1, 0, tview.NewTableCell(names[l.SubnetId]).SetReference(serverLease{server, *l}))
+		SetLeaseCells(table, i+1, 1, l, reserved[l.IpAddress])
+	}
+	markSelected(table, selected, nil)
+	table.ScrollToBeginning()
+	title := fmt.Sprintf("Shared network %s (%d subnets): %d active leases", network, len(subnets), active)
+	if poolSize > 0 {
+		title += fmt.Sprintf(", %.1f%% of %d pool addresses used", float64(active)*100/float64(poolSize), poolSize)
+	}
+	return title
+}
+
+// Shows the leases of a shared network fetched by networkLeases. When
+// fetching failed, the table has none and the status line the error.
+func (u *ui) showNetwork(server *serverView, network string, leases []Lease4, err error) {
+	title := SharedNetworkTable(server, network, leases, u.table, &u.networksort, server.view.selected)
+	if err != nil {
+		title = fmt.Sprintf("Shared network %s (%d subnets)", network, len(server.networkSubnets(network)))
+		u.statusline.SetText(err.Error())
+	}
+	u.table.SetTitle(title)
+}
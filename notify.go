@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rings the terminal bell or sends a desktop notification for events
+// that alert: subnets running out or declining, DNS updates failing,
+// HA servers going into a state in which they do not serve normally,
+// and new leases matching the notify-leases filter
+func (u *ui) notify(e *Event) {
+	if u.config.Notify == "" || !u.notifies(e) {
+		return
+	}
+	var seq string
+	switch u.config.Notify {
+	case "bell":
+		seq = "\a"
+	case "osc9":
+		// Control characters would end the sequence early
+		text := strings.Map(func(r rune) rune {
+			if r < ' ' || r == 0x7f {
+				return ' '
+			}
+			return r
+		}, "ybyra: "+e.Text)
+		seq = fmt.Sprintf("\x1b]9;%s\x07", text)
+	default:
+		return
+	}
+	if err := writeTerminal(seq); err != nil {
+		u.statusline.SetText("notify: " + err.Error())
+	}
+}
+
+// Reports whether an event alerts
+func (u *ui) notifies(e *Event) bool {
+	switch e.Type {
+	case eventPoolExhausted, eventDeclinesRising, eventDdnsFailing:
+		return true
+	case eventHAState:
+		return haUnhealthy[e.State]
+	case eventNewLease:
+		if u.config.NotifyLeases == "" {
+			return false
+		}
+		filter, err := ParseLeaseFilter(u.config.NotifyLeases)
+		if err != nil {
+			u.statusline.SetText("notify-leases: " + err.Error())
+			return false
+		}
+		return filter.Match(e.Lease)
+	}
+	return false
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// A change to the option data of a subnet or one of its pools: old is
+// replaced by new, new is added when old is nil, and old is deleted
+// when new is nil
+type OptionEdit struct {
+	// Index of the pool in the subnet, -1 for the subnet itself
+	Pool int
+	Old  *OptionData
+	New  *OptionData
+}
+
+// Reports whether the option in a configured option-data entry is
+// the one o is. Options are named by name or code, in the dhcp4 space
+// unless they say otherwise.
+func sameOption(entry map[string]any, o *OptionData) bool {
+	space, _ := entry["space"].(string)
+	if space == "" {
+		space = "dhcp4"
+	}
+	other := o.Space
+	if other == "" {
+		other = "dhcp4"
+	}
+	if space != other {
+		return false
+	}
+	name, _ := entry["name"].(string)
+	code, _ := entry["code"].(float64)
+	if name != "" && o.Name != "" {
+		return name == o.Name
+	}
+	return code != 0 && int(code) == o.Code
+}
+
+// Sets the fields of an option-data entry from o. Fields ybyra does
+// not model are kept.
+func setOption(entry map[string]any, o *OptionData) {
+	delete(entry, "name")
+	delete(entry, "code")
+	if o.Name != "" {
+		entry["name"] = o.Name
+	}
+	if o.Code != 0 {
+		entry["code"] = o.Code
+	}
+	entry["space"] = o.Space
+	if o.Space == "" {
+		entry["space"] = "dhcp4"
+	}
+	entry["data"] = o.Data
+	entry["csv-format"] = o.CsvFormat
+	entry["always-send"] = o.AlwaysSend
+}
+
+// Applies an edit to the option-data list of a subnet or pool as
+// configured
+func (e OptionEdit) apply(list []any) ([]any, error) {
+	if e.Old == nil {
+		entry := map[string]any{}
+		setOption(entry, e.New)
+		return append(list, entry), nil
+	}
+	for i, item := range list {
+		entry, ok := item.(map[string]any)
+		if !ok || !sameOption(entry, e.Old) {
+			continue
+		}
+		if e.New == nil {
+			return append(list[:i:i], list[i+1:]...), nil
+		}
+		setOption(entry, e.New)
+		return list, nil
+	}
+	return nil, fmt.Errorf("option %s is no longer configured", e.Old.DisplayName())
+}
+
+// Changes the option data of a subnet or pool through subnet_cmds
+func (c *Client) EditOption(ctx context.Context, subnet int, e OptionEdit) error {
+	return c.updateSubnet(ctx, subnet, func(config map[string]any) error {
+		scope, err := subnetScope(config, e.Pool)
+		if err != nil {
+			return err
+		}
+		list, _ := scope["option-data"].([]any)
+		scope["option-data"], err = e.apply(list)
+		return err
+	})
+}
+
+// Returns the configuration of a pool of a subnet as subnet4-get
+// returns it, or that of the subnet itself for pool -1
+func subnetScope(config map[string]any, pool int) (map[string]any, error) {
+	if pool < 0 {
+		return config, nil
+	}
+	pools, _ := config["pools"].([]any)
+	if pool >= len(pools) {
+		return nil, fmt.Errorf("the subnet has no pool %d", pool+1)
+	}
+	scope, _ := pools[pool].(map[string]any)
+	if scope == nil {
+		return nil, fmt.Errorf("pool %d is not an object", pool+1)
+	}
+	return scope, nil
+}
+
+// Changes the configuration of a subnet through subnet_cmds. The
+// subnet is read again with subnet4-get first, so that changes made
+// since it was loaded are kept, changed by edit and sent back whole
+// with subnet4-update.
+func (c *Client) updateSubnet(ctx context.Context, subnet int, edit func(config map[string]any) error) error {
+	resp, err := c.dhcp4(ctx, Subnet4GetRequest{subnet})
+	if err != nil {
+		return err
+	}
+	if err = resp.Err(); err != nil {
+		return err
+	}
+	var got struct {
+		Subnets []map[string]any `json:"subnet4"`
+	}
+	if err = resp.Decode(&got, false); err != nil {
+		return err
+	}
+	if len(got.Subnets) != 1 {
+		return fmt.Errorf("subnet4-get returned %d subnets", len(got.Subnets))
+	}
+	if err = edit(got.Subnets[0]); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(got.Subnets[0])
+	if err != nil {
+		return err
+	}
+	resp, err = c.dhcp4(ctx, Subnet4UpdateRequest{[]json.RawMessage{raw}})
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
+// Applies an edit to the option data of a subnet as loaded, once the
+// server took it
+func (s *Subnet4) applyOptionEdit(e OptionEdit) {
+	options := &s.OptionData
+	if e.Pool >= 0 && e.Pool < len(s.Pools) {
+		options = &s.Pools[e.Pool].OptionData
+	}
+	if e.Old == nil {
+		*options = append(*options, *e.New)
+		return
+	}
+	for i := range *options {
+		o := &(*options)[i]
+		entry := map[string]any{"name": o.Name, "code": float64(o.Code), "space": o.Space}
+		if !sameOption(entry, e.Old) {
+			continue
+		}
+		if e.New == nil {
+			*options = append((*options)[:i:i], (*options)[i+1:]...)
+		} else {
+			*o = *e.New
+		}
+		return
+	}
+}
+
+// An option in the option list of :options, with where it is set
+type optionRow struct {
+	pool   int
+	option *OptionData
+}
+
+// Lists the option data of the subnet shown and of its pools. Enter
+// edits an option, a adds one where the selected one is set, d
+// deletes one. The changes are sent with subnet4-update.
+func (u *ui) showOptions() {
+	server, subnet := u.current()
+	if subnet == nil {
+		u.statusline.SetText("Select a subnet to edit its options")
+		return
+	}
+	table := tview.NewTable().
+		SetSeparator(tview.Borders.Vertical).
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	for i, name := range []string{"Where", "Name", "Code", "Space", "Data"} {
+		table.SetCell(0, i, tview.NewTableCell(name).
+			SetTextColor(tcell.ColorYellow).
+			SetSelectable(false))
+	}
+	var rows []optionRow
+	add := func(pool int, where string, options []OptionData) {
+		for i := range options {
+			o := &options[i]
+			row := len(rows) + 1
+			rows = append(rows, optionRow{pool, o})
+			code := ""
+			if o.Code != 0 {
+				code = strconv.Itoa(o.Code)
+			}
+			for col, text := range []string{where, o.DisplayName(), code, o.Space, o.Data} {
+				table.SetCell(row, col, tview.NewTableCell(text))
+			}
+		}
+	}
+	add(-1, "subnet", subnet.OptionData)
+	for i := range subnet.Pools {
+		add(i, "pool "+subnet.Pools[i].Pool, subnet.Pools[i].OptionData)
+	}
+	selected := func() optionRow {
+		row, _ := table.GetSelection()
+		if row < 1 || row > len(rows) {
+			return optionRow{pool: -1}
+		}
+		return rows[row-1]
+	}
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		r := selected()
+		switch {
+		case event.Key() == tcell.KeyEnter && r.option != nil:
+			u.editOption(server, subnet, r.pool, r.option)
+		case event.Rune() == 'a':
+			u.editOption(server, subnet, r.pool, nil)
+		case event.Rune() == 'd' && r.option != nil:
+			u.pages.RemovePage("popup")
+			u.prev = u.table
+			u.confirm(fmt.Sprintf("Delete option %s?", r.option.DisplayName()), func() {
+				u.submitOptionEdit(server, subnet, OptionEdit{r.pool, r.option, nil})
+			})
+		default:
+			return event
+		}
+		return nil
+	})
+	u.prev = u.app.GetFocus()
+	u.showTable(fmt.Sprintf("Options of %s, Enter edits, a adds, d deletes", subnet.Subnet), table)
+}
+
+// Opens a form editing an option of a subnet or pool, or adding one
+// when old is nil
+func (u *ui) editOption(server *serverView, subnet *Subnet4, pool int, old *OptionData) {
+	o := OptionData{Space: "dhcp4", CsvFormat: true}
+	title := "Add option to " + subnet.Subnet
+	if pool >= 0 {
+		title = "Add option to pool " + subnet.Pools[pool].Pool
+	}
+	if old != nil {
+		o, title = *old, "Option "+old.DisplayName()
+	}
+	code := ""
+	if o.Code != 0 {
+		code = strconv.Itoa(o.Code)
+	}
+	form := tview.NewForm().
+		AddInputField("Name", o.Name, 30, nil, nil).
+		AddInputField("Code", code, 5, func(text string, r rune) bool { return r >= '0' && r <= '9' }, nil).
+		AddInputField("Space", o.Space, 20, nil, nil).
+		AddInputField("Data", o.Data, 50, nil, nil).
+		AddCheckbox("CSV format", o.CsvFormat, nil).
+		AddCheckbox("Always send", o.AlwaysSend, nil)
+	text := func(label string) string {
+		return strings.TrimSpace(form.GetFormItemByLabel(label).(*tview.InputField).GetText())
+	}
+	closeForm := func() {
+		u.pages.RemovePage("form")
+		u.app.SetFocus(u.prev)
+	}
+	form.AddButton("Save", func() {
+		edited := &OptionData{
+			Name:       text("Name"),
+			Space:      text("Space"),
+			Data:       text("Data"),
+			CsvFormat:  form.GetFormItemByLabel("CSV format").(*tview.Checkbox).IsChecked(),
+			AlwaysSend: form.GetFormItemByLabel("Always send").(*tview.Checkbox).IsChecked(),
+		}
+		edited.Code, _ = strconv.Atoi(text("Code"))
+		if edited.Name == "" && edited.Code == 0 {
+			u.statusline.SetText("An option needs a name or a code")
+			return
+		}
+		u.pages.RemovePage("form")
+		u.pages.RemovePage("popup")
+		u.app.SetFocus(u.table)
+		u.submitOptionEdit(server, subnet, OptionEdit{pool, old, edited})
+	})
+	form.AddButton("Cancel", closeForm)
+	form.SetCancelFunc(closeForm)
+	form.SetBorder(true)
+	form.SetTitle(title)
+	u.prev = u.app.GetFocus()
+	u.pages.AddPage("form", centered(form, 70, 17), true, true)
+	u.app.SetFocus(form)
+}
+
+// Sends an option edit, or queues it
+func (u *ui) submitOptionEdit(server *serverView, subnet *Subnet4, e OptionEdit) {
+	// The option as it is now, which later edits do not change
+	if e.Old != nil {
+		old := *e.Old
+		e.Old = &old
+	}
+	o := e.New
+	verb := "Setting"
+	if o == nil {
+		o, verb = e.Old, "Deleting"
+	}
+	label := fmt.Sprintf("%s option %s of %s", verb, o.DisplayName(), subnet.Subnet)
+	items := []bulkItem{{
+		label: label,
+		run: func(ctx context.Context) error {
+			return server.client.EditOption(ctx, subnet.Id, e)
+		},
+		after: func() { subnet.applyOptionEdit(e) },
+	}}
+	u.submit(label, items, 0, dryRun, u.updateTable)
+}
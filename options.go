@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Formats of the values of well-known DHCPv4 options
+type optionFormat uint8
+
+const (
+	optionIPs optionFormat = iota + 1
+	optionText
+	optionSeconds
+	// Vendor-specific sub-options, one code, length and value each
+	optionTLV
+)
+
+// Standard options whose values are decoded for display, with the
+// names Kea knows them by
+var knownOptions = map[int]struct {
+	name   string
+	format optionFormat
+}{
+	1:   {"subnet-mask", optionIPs},
+	3:   {"routers", optionIPs},
+	4:   {"time-servers", optionIPs},
+	6:   {"domain-name-servers", optionIPs},
+	12:  {"host-name", optionText},
+	15:  {"domain-name", optionText},
+	17:  {"root-path", optionText},
+	28:  {"broadcast-address", optionIPs},
+	42:  {"ntp-servers", optionIPs},
+	43:  {"vendor-encapsulated-options", optionTLV},
+	44:  {"netbios-name-servers", optionIPs},
+	51:  {"dhcp-lease-time", optionSeconds},
+	54:  {"dhcp-server-identifier", optionIPs},
+	58:  {"dhcp-renewal-time", optionSeconds},
+	59:  {"dhcp-rebinding-time", optionSeconds},
+	60:  {"vendor-class-identifier", optionText},
+	66:  {"tftp-server-name", optionText},
+	67:  {"boot-file-name", optionText},
+	150: {"tftp-server-address", optionIPs},
+}
+
+// Returns the name of an option, from the standard names if the
+// configuration gives only its code
+func (o *OptionData) DisplayName() string {
+	if o.Name != "" {
+		return o.Name
+	}
+	if known, ok := knownOptions[o.Code]; ok {
+		return known.name
+	}
+	return fmt.Sprintf("option %d", o.Code)
+}
+
+// Returns the value of an option in readable form. Options given in
+// hex are decoded according to their format when it is known, values
+// that do not fit it are returned as they are.
+func (o *OptionData) Value() string {
+	known, ok := knownOptions[o.Code]
+	if !ok && o.Name != "" {
+		for _, k := range knownOptions {
+			if k.name == o.Name {
+				known, ok = k, true
+			}
+		}
+	}
+	if o.CsvFormat || !ok {
+		return o.Data
+	}
+	data, err := hex.DecodeString(strings.NewReplacer("0x", "", " ", "", ":", "").
+		Replace(strings.ToLower(o.Data)))
+	if err != nil || len(data) == 0 {
+		return o.Data
+	}
+	switch known.format {
+	case optionIPs:
+		if len(data)%net.IPv4len != 0 {
+			break
+		}
+		var ips []string
+		for i := 0; i < len(data); i += net.IPv4len {
+			ips = append(ips, net.IP(data[i:i+net.IPv4len]).String())
+		}
+		return strings.Join(ips, ", ")
+	case optionText:
+		if isPrintable(data) {
+			return string(data)
+		}
+	case optionSeconds:
+		if len(data) == 4 {
+			return (time.Duration(binary.BigEndian.Uint32(data)) * time.Second).String()
+		}
+	case optionTLV:
+		var subs []string
+		for len(data) >= 2 && len(data) >= 2+int(data[1]) {
+			subs = append(subs, fmt.Sprintf("%d: %s", data[0], relayText(data[2:2+int(data[1])])))
+			data = data[2+int(data[1]):]
+		}
+		if len(data) == 0 {
+			return strings.Join(subs, "; ")
+		}
+	}
+	return o.Data
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Files the OUI database is loaded from when the configuration names
+// none: the IEEE registry of ieee-data and the manuf file of Wireshark
+var ouiPaths = []string{
+	"/usr/share/ieee-data/oui.txt",
+	"/usr/share/wireshark/manuf",
+	"/usr/share/misc/oui.txt",
+}
+
+// Vendors of the hardware seen most often on lab and virtualization
+// networks, known without a database
+var ouiBuiltin = map[string]string{
+	"B827EB": "Raspberry Pi Foundation",
+	"DCA632": "Raspberry Pi Trading",
+	"E45F01": "Raspberry Pi Trading",
+	"28CDC1": "Raspberry Pi Trading",
+	"D83ADD": "Raspberry Pi Trading",
+	"2CCF67": "Raspberry Pi Trading",
+	"005056": "VMware",
+	"000C29": "VMware",
+	"000569": "VMware",
+	"525400": "QEMU/KVM",
+	"080027": "Oracle VirtualBox",
+	"00163E": "Xen",
+	"00155D": "Microsoft Hyper-V",
+}
+
+// OUIDatabase maps the first three bytes of MAC addresses, as
+// upper-case hex without separators, to the vendor they are assigned
+// to
+type OUIDatabase map[string]string
+
+// OUI database file from the oui-file setting, see LoadOUI
+var ouiFile string
+
+// Vendor lookup of the lease tables and details, loaded from ouiFile
+// by loadVendors
+var (
+	ouiDB   OUIDatabase
+	ouiErr  error
+	ouiOnce sync.Once
+)
+
+// Loads the OUI database unless it is loaded already, and returns the
+// error loading it, if any. Failures leave the built-in vendors.
+func loadVendors() error {
+	ouiOnce.Do(func() {
+		if ouiDB, ouiErr = LoadOUI(ouiFile); ouiErr != nil {
+			ouiErr = fmt.Errorf("oui: %w", ouiErr)
+		}
+	})
+	return ouiErr
+}
+
+// Returns the vendor of a MAC address, loading the OUI database the
+// first time, so that only what shows vendors reads it
+func macVendor(mac string) string {
+	loadVendors()
+	return ouiDB.Vendor(mac)
+}
+
+// Returns the vendor of a MAC address, or nothing if it is unknown
+func (db OUIDatabase) Vendor(mac string) string {
+	hex := strings.ToUpper(strings.NewReplacer(":", "", "-", "", ".", "").Replace(mac))
+	if len(hex) < 6 {
+		return ""
+	}
+	return db[hex[:6]]
+}
+
+// Loads an OUI database from the IEEE oui.txt format, whose lines are
+// like "B8-27-EB   (hex)		Raspberry Pi Foundation", or from the
+// manuf file of Wireshark, whose lines are like
+// "B8:27:EB	Raspberry	Raspberry Pi Foundation". An empty path
+// loads the first of ouiPaths that exists. The built-in vendors are
+// kept for prefixes the file lacks.
+func LoadOUI(path string) (OUIDatabase, error) {
+	db := OUIDatabase{}
+	for prefix, vendor := range ouiBuiltin {
+		db[prefix] = vendor
+	}
+	paths := []string{path}
+	if path == "" {
+		paths = ouiPaths
+	}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if errors.Is(err, fs.ErrNotExist) && path == "" {
+			continue
+		}
+		if err != nil {
+			return db, err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			prefix, vendor := parseOUILine(scanner.Text())
+			if prefix != "" && vendor != "" {
+				db[prefix] = vendor
+			}
+		}
+		return db, scanner.Err()
+	}
+	return db, nil
+}
+
+// Returns the prefix and vendor of a line of oui.txt or manuf, or
+// nothing for other lines. Wireshark entries for blocks smaller than a
+// whole OUI, like 00:1B:C5:00:00:00/36, are skipped.
+func parseOUILine(line string) (string, string) {
+	if prefix, vendor, ok := strings.Cut(line, "(hex)"); ok {
+		return strings.ReplaceAll(strings.TrimSpace(prefix), "-", ""), strings.TrimSpace(vendor)
+	}
+	if strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+	fields := strings.Split(line, "\t")
+	if len(fields) < 2 || len(fields[0]) != len("00:00:00") {
+		return "", ""
+	}
+	vendor := strings.TrimSpace(fields[len(fields)-1])
+	return strings.ToUpper(strings.ReplaceAll(fields[0], ":", "")), vendor
+}
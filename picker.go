@@ -0,0 +1,115 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Reports whether the runes of pattern appear in text in order,
+// ignoring case, and scores the match. Runes at the start of a word
+// and runs of consecutive runes score higher.
+func fuzzyMatch(pattern, text string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	if len(p) == 0 {
+		return 0, true
+	}
+	score, j := 0, 0
+	prev, last := ' ', -2
+	for i, r := range []rune(strings.ToLower(text)) {
+		if j < len(p) && r == p[j] {
+			score++
+			if last == i-1 {
+				score += 2
+			}
+			if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+				score += 3
+			}
+			last = i
+			j++
+		}
+		prev = r
+	}
+	return score, j == len(p)
+}
+
+// Returns a primitive that shows p centered with the given size
+func centered(p tview.Primitive, width, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+}
+
+// Opens a popup that narrows items down to those fuzzy matching what
+// is typed, best matches first, and calls done with the item chosen
+// with Enter
+func (u *ui) fuzzyPicker(title string, items []string, done func(item string)) {
+	input := tview.NewInputField()
+	list := tview.NewList().ShowSecondaryText(false)
+	var shown []string
+	filter := func(pattern string) {
+		type match struct {
+			item  string
+			score int
+		}
+		var matches []match
+		for _, item := range items {
+			if score, ok := fuzzyMatch(pattern, item); ok {
+				matches = append(matches, match{item, score})
+			}
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].score > matches[j].score
+		})
+		list.Clear()
+		shown = shown[:0]
+		for _, m := range matches {
+			shown = append(shown, m.item)
+			list.AddItem(m.item, "", 0, nil)
+		}
+	}
+	filter("")
+	closePicker := func() {
+		u.pages.RemovePage("picker")
+		u.app.SetFocus(u.prev)
+	}
+	input.SetChangedFunc(filter)
+	input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyDown:
+			if n := list.GetItemCount(); n > 0 {
+				list.SetCurrentItem((list.GetCurrentItem() + 1) % n)
+			}
+			return nil
+		case tcell.KeyUp:
+			list.SetCurrentItem(list.GetCurrentItem() - 1)
+			return nil
+		case tcell.KeyEnter:
+			i := list.GetCurrentItem()
+			closePicker()
+			if i >= 0 && i < len(shown) {
+				done(shown[i])
+			}
+			return nil
+		case tcell.KeyEscape:
+			closePicker()
+			return nil
+		}
+		return event
+	})
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(input, 1, 0, true).
+		AddItem(list, 0, 1, false)
+	flex.SetBorder(true)
+	flex.SetTitle(title)
+	u.prev = u.app.GetFocus()
+	u.pages.AddPage("picker", centered(flex, 50, 15), true, true)
+	u.app.SetFocus(input)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Ports the TCP fallback of Ping connects to, of services that hosts
+// commonly run or refuse
+var pingPorts = []string{"22", "80", "443", "445", "3389"}
+
+// Round-trip time in the output of ping, like time=0.42 ms
+var pingTime = regexp.MustCompile(`time[=<]([0-9.]+) ?ms`)
+
+// Reports whether a host answers and how fast. It runs the system
+// ping, which can send ICMP without privileges, and connects to
+// pingPorts when ping is missing or gets no answer, as hosts often
+// drop ICMP. A refused connection counts as an answer.
+func Ping(ctx context.Context, ip string) (bool, time.Duration, string) {
+	if path, err := exec.LookPath("ping"); err == nil {
+		// Linux ping waits a second for the reply with -W 1, the BSD
+		// ping takes -W in milliseconds and gives up after a second
+		// with -t 1
+		timeout := []string{"-W", "1"}
+		if runtime.GOOS != "linux" {
+			timeout = []string{"-t", "1"}
+		}
+		args := append([]string{"-c", "1"}, append(timeout, ip)...)
+		out, err := exec.CommandContext(ctx, path, args...).Output()
+		if err == nil {
+			var rtt time.Duration
+			if m := pingTime.FindSubmatch(out); m != nil {
+				ms, _ := strconv.ParseFloat(string(m[1]), 64)
+				rtt = time.Duration(ms * float64(time.Millisecond))
+			}
+			return true, rtt, "ICMP"
+		}
+	}
+	type answer struct {
+		port string
+		rtt  time.Duration
+	}
+	answers := make(chan answer, len(pingPorts))
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	var dialer net.Dialer
+	for _, port := range pingPorts {
+		go func(port string) {
+			start := time.Now()
+			conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, port))
+			if err == nil {
+				conn.Close()
+			}
+			if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+				answers <- answer{port, time.Since(start)}
+				return
+			}
+			answers <- answer{}
+		}(port)
+	}
+	for range pingPorts {
+		if a := <-answers; a.port != "" {
+			return true, a.rtt, "TCP port " + a.port
+		}
+	}
+	return false, 0, ""
+}
+
+// Pings the lease in a row in the background and reports the result
+// in the status line
+func (u *ui) pingRow(row int) {
+	_, lease := u.rowLease(row)
+	if lease == nil {
+		return
+	}
+	ip := lease.IpAddress
+	u.statusline.SetText("Pinging " + ip + "…")
+	go func() {
+		up, rtt, how := Ping(u.ctx, ip)
+		text := ip + " is down"
+		if up {
+			text = fmt.Sprintf("%s is up (%s, %s)", ip, how, rtt.Round(10*time.Microsecond))
+		}
+		u.app.QueueUpdateDraw(func() { u.statusline.SetText(text) })
+	}()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Reverse lookups the PTR column makes at most per second, so that
+// showing a large subnet does not flood the DNS server
+const ptrRate = 20
+
+// How long the result of a reverse lookup is used for
+const ptrTTL = 10 * time.Minute
+
+// Whether the lease tables show the PTR column, which p toggles
+var showPTR bool
+
+type ptrResult struct {
+	name string
+	// Time of the lookup, zero while it is pending
+	at time.Time
+}
+
+// PTRResolver looks up the names of addresses in the background, one
+// at a time and at most ptrRate a second, and caches them
+type PTRResolver struct {
+	mu      sync.Mutex
+	results map[string]ptrResult
+	queue   chan string
+	start   sync.Once
+	// Called after each lookup, from the goroutine of the resolver
+	resolved func()
+}
+
+var reverseDNS = &PTRResolver{
+	results: map[string]ptrResult{},
+	queue:   make(chan string, 4096),
+}
+
+// Returns the name of an address and whether it is known yet. Unknown
+// and outdated addresses are queued for lookup.
+func (r *PTRResolver) Lookup(ip string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result, ok := r.results[ip]
+	if ok && (result.at.IsZero() || time.Since(result.at) < ptrTTL) {
+		return result.name, !result.at.IsZero()
+	}
+	r.start.Do(func() { go r.run() })
+	select {
+	case r.queue <- ip:
+		r.results[ip] = ptrResult{name: result.name}
+	default:
+		// Queued next time the address is shown
+	}
+	return result.name, ok
+}
+
+func (r *PTRResolver) run() {
+	ticker := time.NewTicker(time.Second / ptrRate)
+	defer ticker.Stop()
+	for ip := range r.queue {
+		<-ticker.C
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		names, err := net.DefaultResolver.LookupAddr(ctx, ip)
+		cancel()
+		name := ""
+		if err == nil && len(names) > 0 {
+			name = strings.TrimSuffix(names[0], ".")
+		}
+		r.mu.Lock()
+		r.results[ip] = ptrResult{name, time.Now()}
+		resolved := r.resolved
+		r.mu.Unlock()
+		if resolved != nil {
+			resolved()
+		}
+	}
+}
+
+// Returns the cell of the PTR column for a lease, red when the name
+// in DNS differs from the hostname the client gave
+func ptrCell(l *Lease4) *tview.TableCell {
+	name, ok := reverseDNS.Lookup(l.IpAddress)
+	switch {
+	case !ok:
+		return tview.NewTableCell("…").SetTextColor(tcell.ColorGray)
+	case name == "":
+		return tview.NewTableCell("-").SetTextColor(tcell.ColorGray)
+	case l.Hostname != "" && !sameHost(name, l.Hostname):
+		return tview.NewTableCell(name).SetTextColor(tcell.ColorRed)
+	}
+	return tview.NewTableCell(name)
+}
+
+// Reports whether two names are of the same host, comparing only the
+// first label when one of them is not qualified
+func sameHost(a, b string) bool {
+	a, b = strings.TrimSuffix(a, "."), strings.TrimSuffix(b, ".")
+	if !strings.Contains(a, ".") || !strings.Contains(b, ".") {
+		a, _, _ = strings.Cut(a, ".")
+		b, _, _ = strings.Cut(b, ".")
+	}
+	return strings.EqualFold(a, b)
+}
+
+// Fills in the PTR cells of the lease table shown with the names
+// looked up since it was drawn
+func (u *ui) updatePTR() {
+	column := -1
+	for i, field := range shownLeaseFields() {
+		if field == ptrField {
+			column = i
+		}
+	}
+	if column < 0 {
+		return
+	}
+	for row := 1; row < u.table.GetRowCount(); row++ {
+		var lease *Lease4
+		col := column
+		switch ref := u.table.GetCell(row, 0).GetReference().(type) {
+		case Lease4:
+			lease = &ref
+		case serverLease:
+			lease, col = &ref.Lease4, column+1
+		default:
+			continue
+		}
+		cell := ptrCell(lease)
+		cell.SetBackgroundColor(u.table.GetCell(row, 0).BackgroundColor)
+		u.table.SetCell(row, col, cell)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// A change waiting in the queue, with the bulk operation it was part
+// of and how long that waits between its items
+type queuedChange struct {
+	batch    string
+	item     bulkItem
+	interval time.Duration
+}
+
+// Runs the items of a change to the servers, or adds them to the queue
+// for review when changes are queued. done is called either way, once
+// the items ran or were queued. Dry runs are never queued, as they
+// change nothing.
+func (u *ui) submit(title string, items []bulkItem, interval time.Duration, dry bool, done func()) {
+	if !u.queueing || dry {
+		u.runBulk(title, items, interval, dry, done)
+		return
+	}
+	for _, item := range items {
+		u.queue = append(u.queue, queuedChange{title, item, interval})
+	}
+	if done != nil {
+		done()
+	}
+	u.statusline.SetText(fmt.Sprintf("%d changes queued, Q reviews them", len(u.queue)))
+}
+
+// Switches between running changes at once and queueing them
+func (u *ui) toggleQueueing() {
+	u.queueing = !u.queueing
+	if u.queueing {
+		u.statusline.SetText("Queueing changes, Q reviews them")
+	} else if len(u.queue) > 0 {
+		u.statusline.SetText(fmt.Sprintf("Running changes at once, %d still queued", len(u.queue)))
+	} else {
+		u.statusline.SetText("Running changes at once")
+	}
+}
+
+// Shows the queued changes in a popup, where d removes one, J and K
+// move it down and up, and c commits them all in their order
+func (u *ui) showQueue() {
+	if len(u.queue) == 0 {
+		u.statusline.SetText("No changes queued")
+		return
+	}
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true)
+	fill := func(current int) {
+		list.Clear()
+		for _, c := range u.queue {
+			list.AddItem(tview.Escape(c.batch+": "+c.item.label), "", 0, nil)
+		}
+		list.SetCurrentItem(current)
+		list.SetTitle(fmt.Sprintf("%d queued changes (d remove, J/K move, c commit)", len(u.queue)))
+	}
+	fill(0)
+	hide := func() {
+		u.pages.RemovePage("queue")
+		u.app.SetFocus(u.prev)
+	}
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		i := list.GetCurrentItem()
+		switch {
+		case event.Key() == tcell.KeyEscape:
+			hide()
+		case event.Rune() == 'd' || event.Key() == tcell.KeyDelete:
+			u.queue = append(u.queue[:i], u.queue[i+1:]...)
+			if len(u.queue) == 0 {
+				hide()
+				u.statusline.SetText("No changes queued")
+				return nil
+			}
+			if i == len(u.queue) {
+				i--
+			}
+			fill(i)
+		case event.Rune() == 'K' && i > 0:
+			u.queue[i-1], u.queue[i] = u.queue[i], u.queue[i-1]
+			fill(i - 1)
+		case event.Rune() == 'J' && i < len(u.queue)-1:
+			u.queue[i], u.queue[i+1] = u.queue[i+1], u.queue[i]
+			fill(i + 1)
+		case event.Rune() == 'c':
+			hide()
+			u.commitQueue()
+		default:
+			return event
+		}
+		return nil
+	})
+	u.prev = u.app.GetFocus()
+	u.pages.AddPage("queue", list, true, true)
+	u.app.SetFocus(list)
+}
+
+// Runs the queued changes after confirmation, waiting between all of
+// them as long as the most patient of their operations does
+func (u *ui) commitQueue() {
+	var items []bulkItem
+	var interval time.Duration
+	for _, c := range u.queue {
+		items = append(items, c.item)
+		if c.interval > interval {
+			interval = c.interval
+		}
+	}
+	u.confirmBulk(fmt.Sprintf("Run %d queued changes?", len(items)), func(dry bool) {
+		if !dry {
+			u.queue = nil
+		}
+		u.runBulk("Running queued changes", items, interval, dry, u.updateTable)
+	})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Interval at which the table shown is fetched again, 0 disables it
+var refreshInterval time.Duration
+
+// Fetches the table shown again every refreshInterval until the
+// context ends. The leases are fetched here rather than on the UI
+// goroutine, which only draws them, so a slow server does not hold up
+// the keyboard.
+func (u *ui) autoRefresh() {
+	if refreshInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-u.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// What is shown is only known on the UI goroutine
+		fetches := make(chan func() func(), 1)
+		u.app.QueueUpdate(func() { fetches <- u.reloadFetch() })
+		var fetch func() func()
+		select {
+		case <-u.ctx.Done():
+			return
+		case fetch = <-fetches:
+		}
+		if fetch == nil {
+			continue
+		}
+		show := fetch()
+		u.app.QueueUpdateDraw(func() { u.reloadTable(show) })
+	}
+}
+
+// Returns the fetch of the leases of the table shown, nil when it
+// shows none. The fetch runs off the UI goroutine and returns what
+// draws its leases, which does nothing when the sidebar moved on in
+// the meantime.
+func (u *ui) reloadFetch() func() func() {
+	if front, _ := u.pages.GetFrontPage(); front != "main" || u.visual > 0 {
+		return nil
+	}
+	server, subnet := u.current()
+	network := u.currentNetwork()
+	shown := func() bool {
+		s, sub := u.current()
+		return s == server && sub == subnet && u.currentNetwork() == network
+	}
+	switch {
+	case server == nil:
+		return func() func() {
+			leases, err := AllLeases(u.ctx, u.servers)
+			return func() {
+				if shown() {
+					u.showAllLeases(leases, err)
+				}
+			}
+		}
+	case network != "":
+		return func() func() {
+			leases, err := server.networkLeases(u.ctx, network)
+			return func() {
+				if shown() {
+					u.showNetwork(server, network, leases, err)
+				}
+			}
+		}
+	case subnet != nil && server.view.dispmode == displayLeases:
+		return func() func() {
+			leases, err := server.client.Leases(u.ctx, subnet.Id).All()
+			return func() {
+				view := &server.view
+				if !shown() || view.subnet != subnet || view.dispmode != displayLeases {
+					return
+				}
+				if err != nil {
+					u.statusline.SetText(err.Error())
+					return
+				}
+				previous := view.leases
+				view.setLeases(leases)
+				u.refreshTable(server)
+				u.leasesFetched(server, previous)
+			}
+		}
+	}
+	return nil
+}
+
+// Shows the leases fetched again with show, keeping the row under the
+// cursor by its key rather than its index, so that it stays on the
+// same lease when others come and go. Nothing is shown while a popup
+// or visual mode is open, as they work on the rows shown.
+func (u *ui) reloadTable(show func()) {
+	if front, _ := u.pages.GetFrontPage(); front != "main" || u.visual > 0 {
+		return
+	}
+	row, col := u.table.GetSelection()
+	offset, coloffset := u.table.GetOffset()
+	key := rowKey(u.table, row)
+	show()
+	// Follow mode moved to the newest lease already
+	if server, _ := u.current(); server != nil && server.view.follow {
+		return
+	}
+	if key == "" {
+		u.table.SetOffset(offset, coloffset)
+		return
+	}
+	for r := 1; r < u.table.GetRowCount(); r++ {
+		if rowKey(u.table, r) == key {
+			// Keeps the row where it was on the screen, as far as
+			// the rows that came or went above it allow
+			u.table.SetOffset(offset+r-row, coloffset)
+			u.table.Select(r, col)
+			return
+		}
+	}
+	u.table.SetOffset(offset, coloffset)
+}
+
+// Backgrounds of leases that appeared in the last fetches, the first
+// for those of the latest, fading to the default
+var freshColors = []tcell.Color{
+	tcell.ColorGreen,
+	tcell.ColorDarkGreen,
+	tcell.NewRGBColor(0, 64, 0),
+}
+
+// Updates which leases are new after a fetch of leases. Leases are new
+// for as many fetches as there are colors to fade through. The first
+// fetch of a subnet has nothing new, as there is nothing to compare it
+// with.
+func (v *viewState) markFresh(leases []Lease4) {
+	if v.fresh == nil {
+		v.fresh = map[string]int{}
+	}
+	for ip, age := range v.fresh {
+		if age+1 < len(freshColors) {
+			v.fresh[ip] = age + 1
+		} else {
+			delete(v.fresh, ip)
+		}
+	}
+	known := map[string]bool{}
+	v.appeared = nil
+	for _, l := range leases {
+		known[l.IpAddress] = true
+		if v.known != nil && !v.known[l.IpAddress] {
+			v.fresh[l.IpAddress] = 0
+			v.appeared = append(v.appeared, l)
+		}
+	}
+	v.known = known
+}
+
+// Returns the new leases to highlight in the display mode of the view,
+// none unless it shows leases
+func (v *viewState) freshLeases() map[string]int {
+	if v.dispmode != displayLeases {
+		return nil
+	}
+	return v.fresh
+}
+
+// Selects the lease with the latest transaction in the table and
+// scrolls to it, for follow mode
+func (u *ui) selectNewest() {
+	newest, cltt := 0, int64(0)
+	for row := 1; row < u.table.GetRowCount(); row++ {
+		if l, ok := u.table.GetCell(row, 0).GetReference().(Lease4); ok && l.Cltt >= cltt {
+			newest, cltt = row, l.Cltt
+		}
+	}
+	if newest == 0 {
+		return
+	}
+	u.table.SetSelectable(true, false)
+	u.table.Select(newest, 0)
+}
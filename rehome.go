@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Returns the leases moved into a target subnet: a lease gets the
+// address reserved there for its MAC address, or else the lowest pool
+// address that is neither leased nor reserved. taken are the leases
+// of the target subnet.
+func RehomeLeases(target *Subnet4, leases []Lease4, taken []Lease4) ([]Lease4, error) {
+	used := map[string]bool{}
+	for _, l := range taken {
+		used[l.IpAddress] = true
+	}
+	reserved := map[string]string{}
+	for _, r := range target.Reservations {
+		used[r.IpAddress] = true
+		if r.HwAddress != "" {
+			reserved[strings.ToLower(r.HwAddress)] = r.IpAddress
+		}
+	}
+	// Walks the pools for free addresses as they are needed
+	pool, next, last := 0, uint32(0), uint32(0)
+	free := func() string {
+		for {
+			for next <= last && next != 0 {
+				ip := make(net.IP, net.IPv4len)
+				binary.BigEndian.PutUint32(ip, next)
+				next++
+				if !used[ip.String()] {
+					return ip.String()
+				}
+			}
+			if pool >= len(target.Pools) {
+				return ""
+			}
+			first, end := target.Pools[pool].Range()
+			pool++
+			if first != nil {
+				next, last = binary.BigEndian.Uint32(first), binary.BigEndian.Uint32(end)
+			}
+		}
+	}
+	var moved []Lease4
+	for _, l := range leases {
+		ip, ok := reserved[strings.ToLower(l.HwAddress)]
+		if !ok {
+			if ip = free(); ip == "" {
+				return nil, fmt.Errorf("%s: no free addresses left", target.Subnet)
+			}
+		}
+		used[ip] = true
+		m := l
+		m.IpAddress, m.SubnetId = ip, target.Id
+		moved = append(moved, m)
+	}
+	return moved, nil
+}
+
+// Moves the leases in the selected rows to another subnet of their
+// server, chosen from a picker. Each lease is added in the target
+// subnet before it is deleted in its own, so a lease that cannot be
+// added stays where it is.
+func (u *ui) rehome() {
+	server, subnet := u.current()
+	if server == nil || subnet == nil || server.view.dispmode != displayLeases {
+		u.statusline.SetText("Select leases of a subnet to move")
+		return
+	}
+	var leases []Lease4
+	for _, row := range u.selectedRows() {
+		if _, l := u.rowLease(row); l != nil {
+			leases = append(leases, *l)
+		}
+	}
+	if len(leases) == 0 {
+		u.statusline.SetText("No leases selected")
+		return
+	}
+	var names []string
+	targets := map[string]*Subnet4{}
+	for i := range server.subnets {
+		s := &server.subnets[i]
+		if s.Id != subnet.Id {
+			name := fmt.Sprintf("%s (ID %d)", s.Subnet, s.Id)
+			names = append(names, name)
+			targets[name] = s
+		}
+	}
+	u.fuzzyPicker("Move to subnet", names, func(name string) {
+		target := targets[name]
+		taken, err := server.client.Leases(u.ctx, target.Id).All()
+		var moved []Lease4
+		if err == nil {
+			moved, err = RehomeLeases(target, leases, taken)
+		}
+		if err != nil {
+			u.statusline.SetText(err.Error())
+			return
+		}
+		var lines []string
+		var items []bulkItem
+		for i := range moved {
+			from, to := leases[i], moved[i]
+			line := from.IpAddress + " → " + to.IpAddress
+			lines = append(lines, line)
+			items = append(items, bulkItem{label: line, run: func(ctx context.Context) error {
+				if err := server.client.AddLease(ctx, &to); err != nil {
+					return err
+				}
+				result, text, err := server.client.DelLease(ctx, from.IpAddress)
+				if err == nil && result != resultSuccess {
+					err = errors.New("added, but not deleted: " + text)
+				}
+				return err
+			}})
+		}
+		text := fmt.Sprintf("Move %d leases to %s?", len(moved), target.Subnet)
+		u.confirmBulk(previewText(text, lines), func(dry bool) {
+			u.submit("Moving leases", items, 0, dry, func() {
+				selected := u.selection()
+				for key := range selected {
+					delete(selected, key)
+				}
+				u.updateTable()
+			})
+		})
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Names of the sub-options of the relay agent information option (82)
+var relaySubOptions = map[int]string{
+	1:   "Circuit ID",
+	2:   "Remote ID",
+	5:   "Link selection",
+	6:   "Subscriber ID",
+	9:   "Vendor-specific information",
+	11:  "Server ID override",
+	12:  "Relay ID",
+	151: "VSS information",
+}
+
+// Returns the sub-options of the relay agent information Kea stored
+// in the user context of a lease with store-extended-info, by code.
+// Kea stores it either as hex or as an object holding the hex in
+// sub-options.
+func RelayAgentInfo(l *Lease4) map[int][]byte {
+	var context struct {
+		ISC struct {
+			RelayAgentInfo json.RawMessage `json:"relay-agent-info"`
+		} `json:"ISC"`
+	}
+	if len(l.UserContext) == 0 || json.Unmarshal(l.UserContext, &context) != nil {
+		return nil
+	}
+	var options string
+	if json.Unmarshal(context.ISC.RelayAgentInfo, &options) != nil {
+		var info struct {
+			SubOptions string `json:"sub-options"`
+		}
+		if json.Unmarshal(context.ISC.RelayAgentInfo, &info) != nil {
+			return nil
+		}
+		options = info.SubOptions
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(options), "0x"))
+	if err != nil {
+		return nil
+	}
+	subOptions := make(map[int][]byte)
+	for len(data) >= 2 && len(data) >= 2+int(data[1]) {
+		subOptions[int(data[0])] = data[2 : 2+int(data[1])]
+		data = data[2+int(data[1]):]
+	}
+	return subOptions
+}
+
+// Returns a relay sub-option as text when it is printable, as switches
+// often send port names, and as colon separated hex otherwise
+func relayText(data []byte) string {
+	if isPrintable(data) {
+		return string(data)
+	}
+	return colonHex(data)
+}
+
+// Reports whether data is non-empty printable ASCII
+func isPrintable(data []byte) bool {
+	for _, b := range data {
+		if b >= unicode.MaxASCII || !unicode.IsPrint(rune(b)) {
+			return false
+		}
+	}
+	return len(data) > 0
+}
+
+func colonHex(data []byte) string {
+	parts := make([]string, len(data))
+	for i, b := range data {
+		parts[i] = hex.EncodeToString([]byte{b})
+	}
+	return strings.Join(parts, ":")
+}
+
+// Returns the relay agent information and the rest of the extended
+// info Kea stored with a lease, which tell how the client reached the
+// server when it last got the lease. Kea keeps only the latest
+// exchange, not a history.
+func ExtendedInfoFields(l *Lease4) [][]string {
+	var fields [][]string
+	relay := RelayAgentInfo(l)
+	codes := make([]int, 0, len(relay))
+	for code := range relay {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		name, ok := relaySubOptions[code]
+		if !ok {
+			name = fmt.Sprintf("Relay sub-option %d", code)
+		}
+		value := relayText(relay[code])
+		if code == 5 && len(relay[code]) == net.IPv4len {
+			value = net.IP(relay[code]).String()
+		}
+		fields = append(fields, []string{name, value})
+	}
+	var context struct {
+		ISC map[string]any `json:"ISC"`
+	}
+	if len(l.UserContext) == 0 || json.Unmarshal(l.UserContext, &context) != nil {
+		return fields
+	}
+	var flatten func(path string, v any)
+	flatten = func(path string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				flatten(joinPath(path, k), v[k])
+			}
+		default:
+			fields = append(fields, []string{"Extended info " + path, configValue(v)})
+		}
+	}
+	// Decoded above
+	delete(context.ISC, "relay-agent-info")
+	flatten("", context.ISC)
+	return fields
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Returns the reservations whose hostnames the pattern changes, with
+// the pattern replaced by replacement in their hostnames, which can
+// refer to submatches as $1
+func RenameReservations(reservations []Reservation, pattern *regexp.Regexp, replacement string) []Reservation {
+	var renamed []Reservation
+	for _, r := range reservations {
+		name := pattern.ReplaceAllString(r.Hostname, replacement)
+		if name != r.Hostname {
+			r.Hostname = name
+			renamed = append(renamed, r)
+		}
+	}
+	return renamed
+}
+
+// Renames the selected reservations of a subnet after a preview of
+// the old and new hostnames, like :rename ^lab- prod-
+func (u *ui) renameReservations(args string) {
+	from, to, ok := strings.Cut(strings.TrimSpace(args), " ")
+	if !ok || from == "" {
+		u.statusline.SetText("Usage: rename <regexp> <replacement>")
+		return
+	}
+	pattern, err := regexp.Compile(from)
+	if err != nil {
+		u.statusline.SetText(err.Error())
+		return
+	}
+	server, subnet := u.current()
+	if server == nil || subnet == nil || server.view.dispmode != displayReserv {
+		u.statusline.SetText("Select reservations of a subnet to rename")
+		return
+	}
+	var reservations []Reservation
+	for _, row := range u.selectedRows() {
+		if r, ok := u.table.GetCell(row, 0).GetReference().(Reservation); ok {
+			reservations = append(reservations, r)
+		}
+	}
+	if len(reservations) == 0 {
+		u.statusline.SetText("No reservations selected")
+		return
+	}
+	renamed := RenameReservations(reservations, pattern, strings.TrimSpace(to))
+	if len(renamed) == 0 {
+		u.statusline.SetText("No hostnames match " + from)
+		return
+	}
+	var lines []string
+	var items []bulkItem
+	for _, r := range renamed {
+		r := r
+		var old string
+		for _, o := range reservations {
+			if o.IpAddress == r.IpAddress {
+				old = o.Hostname
+			}
+		}
+		line := old + " → " + r.Hostname
+		lines = append(lines, line)
+		items = append(items, bulkItem{
+			label: line,
+			run: func(ctx context.Context) error {
+				return server.client.UpdateReservation(ctx, subnet.Id, r)
+			},
+			// Keeps the configuration read at startup in step, which
+			// the reservations view shows
+			after: func() {
+				for i := range subnet.Reservations {
+					if subnet.Reservations[i].IpAddress == r.IpAddress {
+						subnet.Reservations[i].Hostname = r.Hostname
+					}
+				}
+			},
+		})
+	}
+	text := fmt.Sprintf("Rename %d reservations?", len(renamed))
+	if len(renamed) < len(reservations) {
+		text = fmt.Sprintf("Rename %d of %d reservations?", len(renamed), len(reservations))
+	}
+	u.prev = u.table
+	u.confirmBulk(previewText(text, lines), func(dry bool) {
+		u.submit("Renaming reservations", items, 0, dry, func() {
+			selected := u.selection()
+			for key := range selected {
+				delete(selected, key)
+			}
+			u.updateTable()
+		})
+	})
+}
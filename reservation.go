@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rivo/tview"
+)
+
+// Returns the names of the client classes of a reservation
+func (r *Reservation) Classes() []string {
+	var classes []string
+	for _, raw := range r.ClientClasses {
+		var class string
+		if json.Unmarshal(raw, &class) != nil {
+			class = string(raw)
+		}
+		classes = append(classes, class)
+	}
+	return classes
+}
+
+// Sets the client classes of a reservation by name
+func (r *Reservation) SetClasses(classes []string) {
+	r.ClientClasses = nil
+	for _, class := range classes {
+		raw, _ := json.Marshal(class)
+		r.ClientClasses = append(r.ClientClasses, raw)
+	}
+}
+
+// Returns the names of the client classes the server defines, in the
+// order of its configuration
+func (c *Client) ClientClasses(ctx context.Context) ([]string, error) {
+	config, err := c.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list, _ := config["client-classes"].([]any)
+	var classes []string
+	for _, class := range list {
+		if m, ok := class.(map[string]any); ok {
+			if name, ok := m["name"].(string); ok {
+				classes = append(classes, name)
+			}
+		}
+	}
+	return classes, nil
+}
+
+// Opens a form editing the hostname and the client classes of the
+// reservation in a row. The classes are those the server defines and
+// those the reservation already has, checked when it has them.
+func (u *ui) editReservation(row int) {
+	r, ok := u.table.GetCell(row, 0).GetReference().(Reservation)
+	server, subnet := u.current()
+	if !ok || subnet == nil {
+		u.statusline.SetText("Select a reservation to edit")
+		return
+	}
+	reqctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+	defined, err := server.client.ClientClasses(reqctx)
+	cancel()
+	if err != nil {
+		u.statusline.SetText(err.Error())
+		return
+	}
+	has := map[string]bool{}
+	for _, class := range r.Classes() {
+		has[class] = true
+	}
+	classes := append([]string{}, defined...)
+	for _, class := range r.Classes() {
+		if !containsString(defined, class) {
+			classes = append(classes, class)
+		}
+	}
+	form := tview.NewForm()
+	form.AddInputField("Hostname", r.Hostname, 40, nil, nil)
+	for _, class := range classes {
+		form.AddCheckbox(class, has[class], nil)
+	}
+	closeForm := func() {
+		u.pages.RemovePage("form")
+		u.app.SetFocus(u.table)
+	}
+	form.AddButton("Save", func() {
+		edited := r
+		edited.Hostname = form.GetFormItemByLabel("Hostname").(*tview.InputField).GetText()
+		var checked []string
+		for _, class := range classes {
+			if form.GetFormItemByLabel(class).(*tview.Checkbox).IsChecked() {
+				checked = append(checked, class)
+			}
+		}
+		edited.SetClasses(checked)
+		closeForm()
+		items := []bulkItem{{
+			label: edited.IpAddress,
+			run: func(ctx context.Context) error {
+				return server.client.UpdateReservation(ctx, subnet.Id, edited)
+			},
+			// Keeps the configuration read at startup in step, which
+			// the reservations view shows
+			after: func() {
+				for i := range subnet.Reservations {
+					if subnet.Reservations[i].IpAddress == edited.IpAddress {
+						subnet.Reservations[i] = edited
+					}
+				}
+			},
+		}}
+		u.prev = u.table
+		u.submit("Updating reservation "+edited.IpAddress, items, 0, dryRun, u.updateTable)
+	})
+	form.AddButton("Cancel", closeForm)
+	form.SetCancelFunc(closeForm)
+	form.SetBorder(true)
+	form.SetTitle("Reservation " + r.IpAddress)
+	u.prev = u.table
+	u.pages.AddPage("form", centered(form, 60, len(classes)+7), true, true)
+	u.app.SetFocus(form)
+	if len(defined) == 0 {
+		u.statusline.SetText(fmt.Sprintf("%s defines no client classes", server.name))
+	}
+}
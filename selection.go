@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Background of selected rows
+const selectedColor = tcell.ColorNavy
+
+// Returns the key a table row is selected by, which stays the same
+// when the table is sorted or filtered: the IP address of leases,
+// along with the server for leases of the aggregated view, and of
+// reservations marked as such, so that switching between the lease
+// and reservation views of a subnet does not carry the selection
+// over. Rows that cannot be selected have none.
+func rowKey(table *tview.Table, row int) string {
+	switch ref := table.GetCell(row, 0).GetReference().(type) {
+	case Lease4:
+		return ref.IpAddress
+	case serverLease:
+		return ref.server.name + " " + ref.IpAddress
+	case Reservation:
+		return "reservation " + ref.IpAddress
+	}
+	return ""
+}
+
+// Highlights the rows of a table whose keys are selected, and those
+// of leases that appeared lately, which fresh has the ages of
+func markSelected(table *tview.Table, selected map[string]bool, fresh map[string]int) {
+	for row := 1; row < table.GetRowCount(); row++ {
+		color := tcell.ColorDefault
+		key := rowKey(table, row)
+		if age, ok := fresh[key]; ok && key != "" {
+			color = freshColors[age]
+		}
+		if key != "" && selected[key] {
+			color = selectedColor
+		}
+		for col := 0; col < table.GetColumnCount(); col++ {
+			if cell := table.GetCell(row, col); cell != nil {
+				cell.SetBackgroundColor(color)
+			}
+		}
+	}
+}
+
+// Highlights the rows of the table shown, see markSelected
+func (u *ui) markSelected() {
+	if server, _ := u.current(); server != nil {
+		markSelected(u.table, server.view.selected, server.view.freshLeases())
+		return
+	}
+	markSelected(u.table, u.allselected, nil)
+}
+
+// Returns the selection of the table shown, which every server keeps
+// for its subnet, and the aggregated view for itself
+func (u *ui) selection() map[string]bool {
+	if server, _ := u.current(); server != nil {
+		return server.view.selected
+	}
+	return u.allselected
+}
+
+// Returns the rows of the table that are selected, in table order
+func (u *ui) selectedRows() []int {
+	selected := u.selection()
+	var rows []int
+	for row := 1; row < u.table.GetRowCount(); row++ {
+		if key := rowKey(u.table, row); key != "" && selected[key] {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// Selects a row or unselects it if it is selected
+func (u *ui) toggleRow(row int) {
+	key := rowKey(u.table, row)
+	if key == "" {
+		return
+	}
+	selected := u.selection()
+	if selected[key] {
+		delete(selected, key)
+	} else {
+		selected[key] = true
+	}
+	u.markSelected()
+}
+
+// Starts visual mode at the current row, in which moving selects the
+// rows between it and the current one, or ends it keeping the rows
+// selected
+func (u *ui) toggleVisual() {
+	if u.visual > 0 {
+		u.visual = 0
+		u.statusline.SetText("")
+		return
+	}
+	u.table.SetSelectable(true, false)
+	row, _ := u.table.GetSelection()
+	if row < 1 {
+		row = 1
+		u.table.Select(row, 0)
+	}
+	u.visual = row
+	u.visualBase = map[string]bool{}
+	for key := range u.selection() {
+		u.visualBase[key] = true
+	}
+	u.extendVisual(row)
+	u.statusline.SetText("-- VISUAL --")
+}
+
+// Selects the rows between the start of visual mode and row, on top
+// of those selected before it started
+func (u *ui) extendVisual(row int) {
+	selected := u.selection()
+	for key := range selected {
+		delete(selected, key)
+	}
+	for key := range u.visualBase {
+		selected[key] = true
+	}
+	from, to := u.visual, row
+	if from > to {
+		from, to = to, from
+	}
+	for r := from; r <= to; r++ {
+		if key := rowKey(u.table, r); key != "" {
+			selected[key] = true
+		}
+	}
+	u.markSelected()
+}
+
+// Selects every row shown, which are those passing the filter, or
+// clears the selection if they all are selected already
+func (u *ui) selectAll() {
+	selected := u.selection()
+	var keys []string
+	all := true
+	for row := 1; row < u.table.GetRowCount(); row++ {
+		if key := rowKey(u.table, row); key != "" {
+			keys = append(keys, key)
+			all = all && selected[key]
+		}
+	}
+	if all {
+		for key := range selected {
+			delete(selected, key)
+		}
+		u.statusline.SetText("Selection cleared")
+	} else {
+		for _, key := range keys {
+			selected[key] = true
+		}
+		u.statusline.SetText(fmt.Sprintf("%d rows selected", len(keys)))
+	}
+	u.markSelected()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Database and hook parameters that are not shown, along with those
+// ending in -password or -secret
+var secretParams = map[string]bool{"password": true, "secret": true}
+
+// Reports whether a parameter holds a password or secret
+func isSecretParam(name string) bool {
+	return secretParams[name[strings.LastIndex(name, "-")+1:]]
+}
+
+// Server-wide settings of the Dhcp4 configuration shown in the server
+// view
+type ServerSettings struct {
+	LeaseDatabase  Database
+	HostsDatabases []Database
+	HooksLibraries []HookLibrary
+
+	MultiThreading   *MultiThreading
+	DhcpQueueControl *DhcpQueueControl
+}
+
+// The multi-threading settings. Thread and queue sizes of 0 let Kea
+// choose them.
+type MultiThreading struct {
+	Enable          *bool `json:"enable-multi-threading"`
+	ThreadPoolSize  int   `json:"thread-pool-size"`
+	PacketQueueSize int   `json:"packet-queue-size"`
+}
+
+// The settings of the packet queue of single-threaded servers
+type DhcpQueueControl struct {
+	EnableQueue bool   `json:"enable-queue"`
+	QueueType   string `json:"queue-type"`
+	Capacity    int    `json:"capacity"`
+}
+
+// A lease-database or hosts-database entry of the configuration.
+// Params holds every parameter but the type.
+type Database struct {
+	Type   string
+	Params map[string]any
+}
+
+// Parses a lease-database or hosts-database entry
+func parseDatabase(raw json.RawMessage) Database {
+	var db Database
+	json.Unmarshal(raw, &db.Params)
+	db.Type, _ = db.Params["type"].(string)
+	delete(db.Params, "type")
+	return db
+}
+
+// Picks the server-wide settings from a Dhcp4 configuration. Kea
+// keeps leases in memfile unless configured otherwise, and has no
+// host backend but the configuration then.
+func ParseServerSettings(config map[string]json.RawMessage) *ServerSettings {
+	s := &ServerSettings{LeaseDatabase: Database{Type: "memfile"}}
+	if raw, ok := config["lease-database"]; ok {
+		s.LeaseDatabase = parseDatabase(raw)
+	}
+	if raw, ok := config["hosts-database"]; ok {
+		s.HostsDatabases = append(s.HostsDatabases, parseDatabase(raw))
+	}
+	var list []json.RawMessage
+	if raw, ok := config["hosts-databases"]; ok && json.Unmarshal(raw, &list) == nil {
+		for _, raw := range list {
+			s.HostsDatabases = append(s.HostsDatabases, parseDatabase(raw))
+		}
+	}
+	if raw, ok := config["hooks-libraries"]; ok {
+		json.Unmarshal(raw, &s.HooksLibraries)
+	}
+	if raw, ok := config["multi-threading"]; ok {
+		s.MultiThreading = &MultiThreading{}
+		json.Unmarshal(raw, s.MultiThreading)
+	}
+	if raw, ok := config["dhcp-queue-control"]; ok {
+		s.DhcpQueueControl = &DhcpQueueControl{}
+		json.Unmarshal(raw, s.DhcpQueueControl)
+	}
+	return s
+}
+
+// Returns the name of the backend, as Kea's documentation spells it
+func (db *Database) Backend() string {
+	switch db.Type {
+	case "memfile", "":
+		return "memfile"
+	case "mysql":
+		return "MySQL"
+	case "postgresql":
+		return "PostgreSQL"
+	}
+	return db.Type
+}
+
+// Returns the connection parameters of a database sorted by name,
+// without passwords
+func (db *Database) Connection() string {
+	var params []string
+	for name, value := range db.Params {
+		if name == "lfc-interval" {
+			continue
+		}
+		if isSecretParam(name) {
+			params = append(params, name+" set")
+			continue
+		}
+		params = append(params, fmt.Sprintf("%s=%v", name, value))
+	}
+	sort.Strings(params)
+	return strings.Join(params, " ")
+}
+
+// Returns how often memfile cleans up the lease file
+func (db *Database) LfcInterval() string {
+	seconds, ok := db.Params["lfc-interval"].(float64)
+	switch {
+	case !ok:
+		return "not configured"
+	case seconds == 0:
+		return "disabled"
+	}
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// Returns the settings as rows of names and values
+func (s *ServerSettings) Fields() [][]string {
+	db := &s.LeaseDatabase
+	fields := [][]string{{"Lease backend", db.Backend()}}
+	if params := db.Connection(); params != "" {
+		fields = append(fields, []string{"", params})
+	}
+	if db.Backend() == "memfile" {
+		fields = append(fields, []string{"LFC interval", db.LfcInterval()})
+	}
+	if len(s.HostsDatabases) == 0 {
+		fields = append(fields, []string{"Host backend", "configuration only"})
+	}
+	for i := range s.HostsDatabases {
+		db := &s.HostsDatabases[i]
+		fields = append(fields, []string{"Host backend", db.Backend()})
+		if params := db.Connection(); params != "" {
+			fields = append(fields, []string{"", params})
+		}
+	}
+	var hooks []string
+	for i := range s.HooksLibraries {
+		hooks = append(hooks, s.HooksLibraries[i].Name())
+	}
+	if len(hooks) == 0 {
+		hooks = append(hooks, "none")
+	}
+	fields = append(fields, []string{"Hooks", strings.Join(hooks, ", ") + " (:hooks)"})
+	fields = append(fields, []string{"Multi-threading", s.MultiThreading.String()})
+	fields = append(fields, []string{"Queue control", s.DhcpQueueControl.String()})
+	return fields
+}
+
+// Returns a size, or auto for 0
+func sizeOrAuto(size int) string {
+	if size == 0 {
+		return "auto"
+	}
+	return strconv.Itoa(size)
+}
+
+// Describes the multi-threading settings. Whether multi-threading is
+// on by default depends on the Kea version, which status-get tells.
+func (mt *MultiThreading) String() string {
+	switch {
+	case mt == nil:
+		return "not configured"
+	case mt.Enable != nil && !*mt.Enable:
+		return "disabled"
+	}
+	text := fmt.Sprintf("%s threads, packet queue %s", sizeOrAuto(mt.ThreadPoolSize), sizeOrAuto(mt.PacketQueueSize))
+	if mt.Enable == nil {
+		return "default, " + text
+	}
+	return "enabled, " + text
+}
+
+// Describes the packet queue settings
+func (q *DhcpQueueControl) String() string {
+	switch {
+	case q == nil:
+		return "not configured"
+	case !q.EnableQueue:
+		return "disabled"
+	}
+	return fmt.Sprintf("%s, capacity %d", q.QueueType, q.Capacity)
+}
+
+// Describes the multi-threading of a running server as status-get
+// reports it
+func (s *KeaStatus) ThreadingText() string {
+	if !s.MultiThreadingEnabled {
+		return "off"
+	}
+	text := fmt.Sprintf("on, %d threads, packet queue %d", s.ThreadPoolSize, s.PacketQueueSize)
+	if len(s.PacketQueueStatistics) == 3 {
+		text += fmt.Sprintf(", %.1f/%.1f/%.1f queued on average over 10/100/1000 packets",
+			s.PacketQueueStatistics[0], s.PacketQueueStatistics[1], s.PacketQueueStatistics[2])
+	}
+	return text
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const shellHelp = `Type a Kea command followed by its arguments as JSON, for example
+  lease4-get {"ip-address": "192.0.2.10"}
+Tab completes command names, the arrow keys recall earlier lines and
+exit or Ctrl-D quits.`
+
+// Reads Kea commands with JSON arguments and prints the responses. On
+// a terminal lines are edited with history and tab completion of the
+// commands the server supports, otherwise one command is read per
+// line of standard input.
+func cliShell(ctx context.Context, client *Client, flags *flag.FlagSet, args []string) (*cliResult, error) {
+	services := flags.String("service", "dhcp4", "comma separated `services` the commands are sent to")
+	if _, err := parseArgs(client, flags, args, 0); err != nil {
+		return nil, err
+	}
+	targets := strings.Split(*services, ",")
+	var out io.Writer = os.Stdout
+	var readLine func() (string, error)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		commands, _ := client.ListCommands(ctx, targets[0])
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return nil, err
+		}
+		defer term.Restore(fd, state)
+		t := term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}, "kea> ")
+		if width, height, err := term.GetSize(fd); err == nil {
+			t.SetSize(width, height)
+		}
+		t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+			if key != '\t' {
+				return "", 0, false
+			}
+			return completeCommand(t, commands, line, pos)
+		}
+		out = t
+		readLine = t.ReadLine
+	} else {
+		scanner := bufio.NewScanner(os.Stdin)
+		readLine = func() (string, error) {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return "", err
+				}
+				return "", io.EOF
+			}
+			return scanner.Text(), nil
+		}
+	}
+	for ctx.Err() == nil {
+		line, err := readLine()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch {
+		case name == "" || strings.HasPrefix(name, "#"):
+			continue
+		case name == "exit" || name == "quit":
+			return nil, nil
+		case name == "help":
+			fmt.Fprintln(out, shellHelp)
+			continue
+		}
+		resps, err := client.Raw(ctx, targets, name, strings.TrimSpace(args))
+		if err != nil {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		fmt.Fprint(out, resps.String())
+	}
+	return nil, nil
+}
+
+// Completes the command name before the cursor. A single match is
+// inserted whole; otherwise the common prefix of the matches is
+// inserted, and the matches are listed when there is nothing more to
+// insert.
+func completeCommand(w io.Writer, commands []string, line string, pos int) (string, int, bool) {
+	word := line[:pos]
+	if strings.Contains(word, " ") {
+		return "", 0, false
+	}
+	var matches []string
+	for _, c := range commands {
+		if strings.HasPrefix(c, word) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	prefix := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	switch {
+	case len(matches) == 1:
+		prefix += " "
+	case prefix == word:
+		fmt.Fprintln(w, strings.Join(matches, "  "))
+	}
+	return prefix + line[pos:], len(prefix), true
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Layout of the timestamps in the names of snapshot files, which sort
+// in time order
+const snapshotTimeFormat = "20060102T150405"
+
+// Writes the leases of all subnets or of one to a timestamped file in
+// a directory, every interval until interrupted or once if the
+// interval is 0. The files are written in the -output format, JSON
+// unless set, one per subnet with -per-subnet.
+func cliSnapshot(ctx context.Context, client *Client, flags *flag.FlagSet, args []string) (*cliResult, error) {
+	dir := flags.String("dir", ".", "`directory` the snapshots are written to")
+	interval := flags.Duration("interval", 0, "take a snapshot every `interval` instead of once")
+	subnetArg := flags.String("subnet", "", "only snapshot this `subnet`, by prefix or ID")
+	perSubnet := flags.Bool("per-subnet", false, "write a file per subnet")
+	output := flags.Lookup("output")
+	output.DefValue = "json"
+	output.Value.Set("json")
+	if _, err := parseArgs(client, flags, args, 0); err != nil {
+		return nil, err
+	}
+	if *interval > 0 && inBatch(ctx) {
+		return nil, errors.New("snapshot -interval cannot run in a batch")
+	}
+	format := output.Value.String()
+	var subnets []int
+	if *subnetArg != "" {
+		subnet, err := findSubnet(ctx, client, *subnetArg)
+		if err != nil {
+			return nil, err
+		}
+		subnets = append(subnets, subnet.Id)
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return nil, err
+	}
+	var ticker *time.Ticker
+	if *interval > 0 {
+		ticker = time.NewTicker(*interval)
+		defer ticker.Stop()
+	}
+	for {
+		leases, err := client.Leases(ctx, subnets...).All()
+		if err == nil {
+			err = writeSnapshot(*dir, format, time.Now(), leases, *perSubnet)
+		}
+		switch {
+		case ctx.Err() != nil:
+			return nil, nil
+		case ticker == nil:
+			return nil, err
+		case err != nil:
+			fmt.Fprintln(os.Stderr, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Writes leases to files named after the time, and the subnet if
+// perSubnet is set, like leases-20240102T150405.json
+func writeSnapshot(dir, format string, t time.Time, leases []Lease4, perSubnet bool) error {
+	ext := map[string]string{"json": ".json", "csv": ".csv", "markdown": ".md"}[format]
+	if ext == "" {
+		ext = ".txt"
+	}
+	stamp := t.Format(snapshotTimeFormat)
+	if !perSubnet {
+		return writeSnapshotFile(filepath.Join(dir, "leases-"+stamp+ext), format, leases)
+	}
+	bySubnet := map[int][]Lease4{}
+	var ids []int
+	for _, l := range leases {
+		if _, ok := bySubnet[l.SubnetId]; !ok {
+			ids = append(ids, l.SubnetId)
+		}
+		bySubnet[l.SubnetId] = append(bySubnet[l.SubnetId], l)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		name := fmt.Sprintf("leases-%d-%s%s", id, stamp, ext)
+		if err := writeSnapshotFile(filepath.Join(dir, name), format, bySubnet[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes a snapshot file under a temporary name first, so that no
+// half-written snapshot is ever found under its final name
+func writeSnapshotFile(path, format string, leases []Lease4) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	err = leasesResult(leases).write(f, format)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Interval between reads of the ARP and MAC tables of the configured
+// devices
+const snmpInterval = 5 * time.Minute
+
+// Tables read over SNMP, by numeric OID
+const (
+	// ipNetToMediaPhysAddress, indexed by interface and IP address
+	oidARP = ".1.3.6.1.2.1.4.22.1.2"
+	// dot1qTpFdbPort, indexed by VLAN and MAC address
+	oidFdbVLAN = ".1.3.6.1.2.1.17.7.1.2.2.1.2"
+	// dot1dTpFdbPort, indexed by MAC address, for switches without
+	// VLAN support
+	oidFdb = ".1.3.6.1.2.1.17.4.3.1.2"
+	// dot1dBasePortIfIndex, the interface of each bridge port
+	oidBasePort = ".1.3.6.1.2.1.17.1.4.1.2"
+	// ifName
+	oidIfName = ".1.3.6.1.2.1.31.1.1.1.1"
+)
+
+// SNMPDevice is a router or switch whose ARP and MAC tables annotate
+// leases. They are read with snmpwalk of Net-SNMP.
+type SNMPDevice struct {
+	Host string `json:"host"`
+	// Community of SNMP v2c, public if empty
+	Community string `json:"community,omitempty"`
+}
+
+// Where a MAC address was seen on a switch
+type SwitchPort struct {
+	Device string
+	Port   string
+	// 0 if the switch does not tell
+	VLAN int
+}
+
+func (p SwitchPort) String() string {
+	if p.VLAN == 0 {
+		return p.Device + " " + p.Port
+	}
+	return fmt.Sprintf("%s %s (VLAN %d)", p.Device, p.Port, p.VLAN)
+}
+
+// SNMPTables are the ARP and MAC tables of the configured devices
+type SNMPTables struct {
+	// MAC addresses by IP address
+	ARP map[string]string
+	// Ports by MAC address
+	Ports map[string]SwitchPort
+	Time  time.Time
+}
+
+var snmp struct {
+	mu     sync.Mutex
+	tables *SNMPTables
+}
+
+// Returns the tables last read, nil before the first read
+func snmpTables() *SNMPTables {
+	snmp.mu.Lock()
+	defer snmp.mu.Unlock()
+	return snmp.tables
+}
+
+// Walks a table of a device and returns its values by the index that
+// follows the OID of the table. Octet strings are returned in hex if
+// hex is set.
+func snmpWalk(ctx context.Context, d SNMPDevice, oid string, hex bool) (map[string]string, error) {
+	community := d.Community
+	if community == "" {
+		community = "public"
+	}
+	format := "-Onq"
+	if hex {
+		format += "x"
+	}
+	out, err := exec.CommandContext(ctx, "snmpwalk", "-v2c", "-c", community, format, d.Host, oid).Output()
+	if err != nil {
+		return nil, fmt.Errorf("snmpwalk %s %s: %w", d.Host, oid, err)
+	}
+	values := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		name, value, _ := strings.Cut(scanner.Text(), " ")
+		if index := strings.TrimPrefix(name, oid+"."); index != name {
+			values[index] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return values, scanner.Err()
+}
+
+// Returns a MAC address in the notation of Kea from the hex bytes of
+// snmpwalk or the decimal bytes of an OID index, or nothing
+func snmpMAC(s string, sep string, base int) string {
+	var parts []string
+	for _, b := range strings.FieldsFunc(s, func(r rune) bool { return strings.ContainsRune(sep, r) }) {
+		n, err := strconv.ParseUint(b, base, 8)
+		if err != nil {
+			return ""
+		}
+		parts = append(parts, fmt.Sprintf("%02x", n))
+	}
+	if len(parts) != 6 {
+		return ""
+	}
+	return strings.Join(parts, ":")
+}
+
+// Reads the ARP table and the MAC table of a device into tables.
+// Devices without one of them, like routers without a bridge, only
+// fill the other.
+func readSNMP(ctx context.Context, d SNMPDevice, tables *SNMPTables) error {
+	arp, err := snmpWalk(ctx, d, oidARP, true)
+	if err != nil {
+		return err
+	}
+	for index, value := range arp {
+		// ifIndex.a.b.c.d
+		parts := strings.SplitN(index, ".", 2)
+		if mac := snmpMAC(value, " :", 16); len(parts) == 2 && mac != "" {
+			tables.ARP[parts[1]] = mac
+		}
+	}
+	ports, _ := snmpWalk(ctx, d, oidBasePort, false)
+	names, _ := snmpWalk(ctx, d, oidIfName, false)
+	portName := func(port string) string {
+		if name, ok := names[ports[port]]; ok {
+			return name
+		}
+		return "port " + port
+	}
+	fdb, _ := snmpWalk(ctx, d, oidFdbVLAN, false)
+	for index, port := range fdb {
+		// vlan.m1.m2.m3.m4.m5.m6
+		vlan, mac, _ := strings.Cut(index, ".")
+		n, _ := strconv.Atoi(vlan)
+		if mac = snmpMAC(mac, ".", 10); mac != "" {
+			tables.Ports[mac] = SwitchPort{d.Host, portName(port), n}
+		}
+	}
+	if len(fdb) == 0 {
+		fdb, _ = snmpWalk(ctx, d, oidFdb, false)
+		for index, port := range fdb {
+			if mac := snmpMAC(index, ".", 10); mac != "" {
+				tables.Ports[mac] = SwitchPort{d.Host, portName(port), 0}
+			}
+		}
+	}
+	return nil
+}
+
+// Reads the tables of the configured devices every snmpInterval until
+// the context ends, and redraws the table shown with them
+func (u *ui) pollSNMP() {
+	if len(u.config.SNMP) == 0 {
+		return
+	}
+	ticker := time.NewTicker(snmpInterval)
+	defer ticker.Stop()
+	for {
+		tables := &SNMPTables{ARP: map[string]string{}, Ports: map[string]SwitchPort{}, Time: time.Now()}
+		var errs []string
+		for _, d := range u.config.SNMP {
+			ctx, cancel := context.WithTimeout(u.ctx, 3*requestTimeout)
+			if err := readSNMP(ctx, d, tables); err != nil {
+				errs = append(errs, err.Error())
+			}
+			cancel()
+		}
+		if u.ctx.Err() != nil {
+			return
+		}
+		snmp.mu.Lock()
+		snmp.tables = tables
+		snmp.mu.Unlock()
+		u.app.QueueUpdateDraw(func() {
+			if len(errs) > 0 {
+				u.statusline.SetText(strings.Join(errs, "; "))
+			}
+			if server, subnet := u.current(); subnet != nil && server.view.dispmode == displayLeases {
+				u.refreshTable(server)
+			}
+		})
+		select {
+		case <-u.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Returns the MAC address the IP address of a lease answers ARP from
+// when it differs from that of the lease
+func arpMismatch(l *Lease4) string {
+	tables := snmpTables()
+	if tables == nil || l.HwAddress == "" {
+		return ""
+	}
+	if mac, ok := tables.ARP[l.IpAddress]; ok && !strings.EqualFold(mac, l.HwAddress) {
+		return mac
+	}
+	return ""
+}
+
+// Returns the details of a lease from the SNMP tables
+func SNMPFields(l *Lease4) [][]string {
+	tables := snmpTables()
+	if tables == nil {
+		return nil
+	}
+	var fields [][]string
+	if port, ok := tables.Ports[strings.ToLower(l.HwAddress)]; ok {
+		fields = append(fields, []string{"Switch port", port.String()})
+	}
+	if mac, ok := tables.ARP[l.IpAddress]; ok {
+		if !strings.EqualFold(mac, l.HwAddress) {
+			mac += " (differs from the lease)"
+		}
+		fields = append(fields, []string{"ARP MAC", mac})
+	}
+	return fields
+}
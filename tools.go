@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Tool is an external command launched on a lease, like ssh, with
+// the placeholders of FormatLease in its arguments
+type Tool struct {
+	Name    string   `json:"name"`
+	Command []string `json:"command"`
+	// Wait for Enter after the command exits, so that its output can
+	// be read before the TUI returns
+	Wait bool `json:"wait,omitempty"`
+}
+
+// Offers the configured tools for the lease in a row and runs the one
+// chosen in the terminal, with the TUI suspended until it exits
+func (u *ui) launchTool(row int) {
+	_, lease := u.rowLease(row)
+	if lease == nil {
+		return
+	}
+	if len(u.config.Tools) == 0 {
+		u.statusline.SetText("No tools configured")
+		return
+	}
+	var names []string
+	tools := map[string]Tool{}
+	for _, t := range u.config.Tools {
+		if len(t.Command) > 0 {
+			names = append(names, t.Name)
+			tools[t.Name] = t
+		}
+	}
+	l := *lease
+	u.fuzzyPicker("Run on "+l.IpAddress, names, func(name string) {
+		var args []string
+		for _, arg := range tools[name].Command {
+			args = append(args, FormatLease(arg, &l))
+		}
+		// The terminal, as standard output is a pipe under -pick
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			u.statusline.SetText(fmt.Sprintf("%s: %s", name, err))
+			return
+		}
+		defer tty.Close()
+		u.app.Suspend(func() {
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+			err = cmd.Run()
+			if tools[name].Wait {
+				fmt.Fprint(tty, "\nPress Enter to return to ybyra")
+				bufio.NewReader(tty).ReadString('\n')
+			}
+		})
+		if err != nil {
+			u.statusline.SetText(fmt.Sprintf("%s: %s", name, err))
+			return
+		}
+		u.statusline.SetText(name + " finished")
+	})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Transport carries a request to Kea and returns one response per
+// addressed service
+type Transport interface {
+	Do(ctx context.Context, req *KeaRequest) ([]KeaResponse, error)
+}
+
+// Returns the transport for a server address. Absolute paths and
+// unix: addresses are daemon control sockets, http:// and https://
+// addresses are used as they are, and anything else is taken as the
+// host of a Control Agent listening on its default port.
+func NewTransport(addr string) Transport {
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		return &UnixTransport{Path: strings.TrimPrefix(addr, "unix:")}
+	case strings.HasPrefix(addr, "/"):
+		return &UnixTransport{Path: addr}
+	case strings.HasPrefix(addr, "http://"), strings.HasPrefix(addr, "https://"):
+		return &HTTPTransport{URL: addr, Client: http.DefaultClient}
+	}
+	return &HTTPTransport{URL: "http://" + addr + ":8000", Client: http.DefaultClient}
+}
+
+// Returns the transport for a server profile, with its credentials
+// and TLS settings applied to HTTP transports
+func NewProfileTransport(p ServerProfile) (Transport, error) {
+	t := NewTransport(p.Address)
+	ht, ok := t.(*HTTPTransport)
+	if !ok {
+		return t, nil
+	}
+	ht.User, ht.Password = p.User, p.Password
+	if p.CAFile == "" && p.CertFile == "" && !p.Insecure {
+		return t, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: p.Insecure}
+	if p.CAFile != "" {
+		pem, err := os.ReadFile(p.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", p.CAFile)
+		}
+	}
+	if p.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(p.CertFile, p.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	ht.Client = &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	return t, nil
+}
+
+// Returned by HTTP transports when the Control Agent rejects the
+// credentials
+var ErrUnauthorized = errors.New("unauthorized")
+
+// HTTPTransport posts requests to a Kea Control Agent
+type HTTPTransport struct {
+	URL    string
+	Client *http.Client
+	// Basic authentication, if User is set
+	User     string
+	Password string
+}
+
+func (t *HTTPTransport) Do(ctx context.Context, req *KeaRequest) ([]KeaResponse, error) {
+	reqBody, err := json.MarshalIndent(req, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	wireSent(ctx, reqBody)
+	httpreq, err := http.NewRequestWithContext(ctx, "POST", t.URL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	httpreq.Header.Set("Content-Type", "application/json")
+	if t.User != "" {
+		httpreq.SetBasicAuth(t.User, t.Password)
+	}
+	resp, err := t.Client.Do(httpreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	wireReceived(ctx, body)
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("%s: %w", resp.Status, ErrUnauthorized)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var grades []KeaResponse
+	err = json.Unmarshal(body, &grades)
+	if err != nil {
+		return nil, err
+	}
+	return grades, nil
+}
+
+// UnixTransport talks to the control socket of a single Kea daemon.
+// The daemon ignores the service list and answers with a bare object,
+// which is returned as the only response.
+type UnixTransport struct {
+	Path string
+}
+
+func (t *UnixTransport) Do(ctx context.Context, req *KeaRequest) ([]KeaResponse, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", t.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	sockreq := *req
+	sockreq.Service = nil
+	reqBody, err := json.Marshal(&sockreq)
+	if err != nil {
+		return nil, err
+	}
+	wireSent(ctx, reqBody)
+	if _, err = conn.Write(reqBody); err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	var grade KeaResponse
+	err = json.NewDecoder(io.TeeReader(conn, &body)).Decode(&grade)
+	wireReceived(ctx, body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return []KeaResponse{grade}, nil
+}
+
+// FakeTransport answers requests from canned responses and records
+// every request it receives, so client and UI code can run without a
+// Kea server
+type FakeTransport struct {
+	Responses map[command][]KeaResponse
+	Requests  []KeaRequest
+	mu        sync.Mutex
+}
+
+func (t *FakeTransport) Do(ctx context.Context, req *KeaRequest) ([]KeaResponse, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Requests = append(t.Requests, *req)
+	grades, ok := t.Responses[req.Command]
+	if !ok {
+		return []KeaResponse{{
+			Result: resultUnsupported,
+			Text:   fmt.Sprintf("'%s' command not supported.", req.Command),
+		}}, nil
+	}
+	// Copied, as the client sets the service of the responses
+	return append([]KeaResponse(nil), grades...), nil
+}
@@ -0,0 +1,1276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// A Kea server shown in the TUI with the subnets it serves
+type serverView struct {
+	name    string
+	group   string
+	client  *Client
+	subnets []Subnet4
+	// Error from loading the subnets
+	err error
+	// Last result of the health poll, and the uptime and status it
+	// saw
+	health     health
+	healthText string
+	uptime     int
+	status     *KeaStatus
+	// Address counts by subnet ID from the last poll, nil unless
+	// alerts are configured, and the declined addresses of the polls
+	// within the window of the decline alert
+	stats    map[int]SubnetStats
+	declines map[int][]countSample
+	// Failed DNS updates of kea-dhcp-ddns at the polls within the
+	// window of the DDNS alert, nil unless it is configured
+	d2Failures []countSample
+	view       viewState
+
+	// Server-wide settings from the configuration, nil when it could
+	// not be read
+	settings *ServerSettings
+}
+
+// What was last shown of a server, kept while other servers are
+// looked at
+type viewState struct {
+	dispmode  displayMode
+	sortorder []SortData
+	// Subnet shown last, nil before one was shown
+	subnet *Subnet4
+	// Leases of the subnet as last fetched, nil to fetch them again,
+	// and the filter narrowing down those shown
+	leases []Lease4
+	filter string
+	// Show expired-reclaimed leases
+	reclaimed bool
+	reserved  reservedFilter
+	// Keys of the selected rows of the subnet, see rowKey
+	selected map[string]bool
+	// Addresses of the leases of the last fetch, nil before the
+	// first, and of those that were not in the fetch before it, with
+	// the number of fetches since
+	known map[string]bool
+	fresh map[string]int
+	// Leases that were new in the last fetch, for the hooks
+	appeared []Lease4
+	// Changes between the last two fetches, nil before there were two
+	changes []LeaseChange
+	// Move to the newest lease on every fetch
+	follow bool
+	// MAC addresses the leases of the server had, by IP address
+	macs map[string][]macSeen
+	// Problems the last DNS check found with the records of the
+	// leases of the subnet, by IP address
+	dnsIssues map[string]string
+}
+
+// An item of the sidebar: a group, a server, a shared network or a
+// subnet of that server, or none of them for the aggregated view of
+// all servers
+type sidebarEntry struct {
+	group   string
+	server  *serverView
+	network string
+	subnet  *Subnet4
+}
+
+// The TUI. With several servers the sidebar lists each server as a
+// group followed by its subnets; with one server only the subnets are
+// listed.
+type ui struct {
+	ctx         context.Context
+	app         *tview.Application
+	pages       *tview.Pages
+	grid        *tview.Grid
+	sidebar     *tview.List
+	table       *tview.Table
+	statusline  *tview.TextView
+	statusinput *tview.InputField
+	cmdinput    *tview.InputField
+	filterinput *tview.InputField
+	statuspage  *tview.Pages
+	// Alerts above the grid, the keys of the alerts raised and of
+	// those the banner no longer shows, see alert
+	banner    *tview.TextView
+	layout    *tview.Flex
+	alerting  map[string]bool
+	dismissed map[string]bool
+	// Where focus returns to after the status line or a popup
+	prev      tview.Primitive
+	servers   []*serverView
+	entries   []sidebarEntry
+	collapsed map[string]bool
+	// Sort order and selection of the aggregated lease view
+	allsort     []SortData
+	allselected map[string]bool
+	// Sort order of shared network views, whose column 0 is the subnet
+	networksort []SortData
+	// Row visual mode started at, 0 outside of it, and the rows
+	// selected before
+	visual     int
+	visualBase map[string]bool
+	commands   map[string]func(args string)
+	// Count typed before a motion key, see motionKeys
+	count int
+	// Whether the mouse clicks and scrolls instead of selecting text
+	// in the terminal
+	mouse bool
+	// Whether changes wait in the queue for review, and the changes
+	// that do
+	queueing bool
+	queue    []queuedChange
+	// Server profiles that can be opened while running
+	config *Config
+	strict bool
+	// Pick mode prints the lease chosen with Enter on exit
+	pick       bool
+	pickFormat string
+	picked     string
+}
+
+// Returns a server for a profile name or an address
+func newServerView(config *Config, name string, strict bool) (*serverView, error) {
+	profile := config.Profile(name)
+	client, err := NewProfileClient(profile)
+	if err != nil {
+		return nil, err
+	}
+	client.Strict = strict
+	client.Transport = &DebugTransport{client.Transport, name, &debugLog}
+	return &serverView{
+		name:   name,
+		group:  profile.Group,
+		client: client,
+		view: viewState{
+			sortorder: []SortData{
+				SortData{4, true},
+				SortData{1, true},
+			},
+			reclaimed: config.ShowReclaimed,
+			selected:  map[string]bool{},
+		},
+	}, nil
+}
+
+// Detects the version and loads the subnets of every server in
+// parallel. Servers that fail keep the error for display.
+func loadServers(ctx context.Context, servers []*serverView) {
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s *serverView) {
+			defer wg.Done()
+			reqctx, cancel := context.WithTimeout(ctx, requestTimeout)
+			defer cancel()
+			s.client.DetectVersion(reqctx)
+			var config map[string]json.RawMessage
+			if config, s.err = s.client.dhcp4Config(reqctx); s.err == nil {
+				s.subnets, s.err = s.client.subnetsOf(config)
+				s.settings = ParseServerSettings(config)
+			}
+			// Sorts the subnets by IP, those of shared networks after
+			// the others and grouped by network, as the sidebar lists
+			// them below their network
+			sort.Slice(s.subnets, func(i, j int) bool {
+				a, b := s.subnets[i].SharedNetwork, s.subnets[j].SharedNetwork
+				if a != b {
+					return a < b
+				}
+				return bytes.Compare(
+					net.ParseIP(strings.Split(s.subnets[i].Subnet, "/")[0]),
+					net.ParseIP(strings.Split(s.subnets[j].Subnet, "/")[0])) < 0
+			})
+		}(s)
+	}
+	wg.Wait()
+}
+
+// Describes a server for the status line
+func (s *serverView) String() string {
+	if s.err != nil {
+		return s.name + ": " + s.err.Error()
+	}
+	text := s.name + " (" + s.client.Compat.String()
+	if s.client.ReadOnly {
+		text += ", read-only"
+	}
+	text += ")"
+	if s.healthText != "" {
+		text += ": " + s.healthText
+	}
+	return text
+}
+
+func newUI(ctx context.Context, servers []*serverView) *ui {
+	u := &ui{
+		ctx:       ctx,
+		servers:   servers,
+		collapsed: map[string]bool{},
+		allsort: []SortData{
+			SortData{5, true},
+		},
+		allselected: map[string]bool{},
+		networksort: []SortData{
+			SortData{0, true},
+		},
+	}
+	u.table = tview.NewTable().
+		SetSeparator(tview.Borders.Vertical).
+		SetBorders(false).
+		SetSelectable(false, false)
+	u.table.SetBorder(true)
+	u.table.SetTitle("Leases")
+	u.table.SetSelectionChangedFunc(func(row, col int) {
+		if u.visual > 0 {
+			u.extendVisual(row)
+		}
+	})
+	u.app = tview.NewApplication()
+	u.setMouse(true)
+	// Lookups finish one by one, so their redraws are coalesced
+	var ptrQueued int32
+	reverseDNS.resolved = func() {
+		if !atomic.CompareAndSwapInt32(&ptrQueued, 0, 1) {
+			return
+		}
+		u.app.QueueUpdateDraw(func() {
+			atomic.StoreInt32(&ptrQueued, 0)
+			u.updatePTR()
+		})
+	}
+	u.pages = tview.NewPages()
+	u.statusline = tview.NewTextView().SetText(servers[0].String())
+	u.statusinput = tview.NewInputField()
+	u.cmdinput = tview.NewInputField().SetLabel(":")
+	u.filterinput = tview.NewInputField().SetLabel("filter: ")
+	u.statuspage = tview.NewPages().
+		AddPage("line", u.statusline, true, true).
+		AddPage("input", u.statusinput, true, false).
+		AddPage("command", u.cmdinput, true, false).
+		AddPage("filter", u.filterinput, true, false)
+	u.sidebar = tview.NewList().
+		ShowSecondaryText(false)
+	u.sidebar.SetBorder(true)
+	u.sidebar.SetTitle("Subnets")
+	u.prev = u.sidebar
+	u.fillSidebar()
+	u.sidebar.SetSelectedFunc(func(index int, text string, stext string, r rune) {
+		if g := u.entries[index].group; g != "" {
+			u.toggleGroup(g)
+			return
+		}
+		u.updateTable()
+	})
+	u.sidebar.SetChangedFunc(func(index int, text string, stext string, r rune) {
+		e := u.entries[index]
+		switch {
+		case e.group != "":
+			u.statusline.SetText("Group " + e.group)
+		case e.server == nil:
+			u.statusline.SetText("Leases of all servers")
+		case len(u.servers) > 1:
+			u.statusline.SetText(e.server.String())
+		}
+	})
+	u.statusinput.SetFinishedFunc(func(key tcell.Key) {
+		u.statuspage.SwitchToPage("line")
+		u.app.SetFocus(u.prev)
+		switch u.prev {
+		case u.sidebar:
+			SearchForwardList(u.statusinput, u.sidebar, u.statusline)
+		case u.table:
+			SearchForwardTable(u.statusinput, u.table, u.statusline)
+		}
+	})
+
+	u.grid = tview.NewGrid().
+		SetColumns(0, -5).
+		SetRows(0, 1).
+		SetBorders(false).
+		AddItem(u.sidebar, 0, 0, 1, 1, 0, 0, true).
+		AddItem(u.table, 0, 1, 1, 1, 0, 0, false).
+		AddItem(u.statuspage, 1, 0, 1, 2, 0, 0, false)
+	u.banner = tview.NewTextView()
+	u.banner.SetBackgroundColor(alertColor)
+	u.layout = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(u.banner, 0, 0, false).
+		AddItem(u.grid, 0, 1, true)
+	u.dismissed = map[string]bool{}
+	u.pages.AddPage("main", u.layout, true, true)
+
+	u.sidebar.SetInputCapture(u.sidebarKeys)
+	u.table.SetInputCapture(u.tableKeys)
+	u.table.SetMouseCapture(u.tableMouse)
+	u.grid.SetInputCapture(u.globalKeys)
+	u.app.SetInputCapture(u.motionKeys)
+	u.statusinput.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			u.statuspage.SwitchToPage("line")
+			u.app.SetFocus(u.prev)
+			return nil
+		}
+		return event
+	})
+
+	// The filter applies while typing, Enter keeps it and Escape
+	// clears it
+	u.filterinput.SetChangedFunc(func(text string) {
+		if server, subnet := u.current(); subnet != nil {
+			server.view.filter = text
+			u.refreshTable(server)
+			u.filterStatus(server)
+		}
+	})
+	u.filterinput.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			u.filterinput.SetText("")
+		}
+		u.statuspage.SwitchToPage("line")
+		u.app.SetFocus(u.prev)
+		if server, subnet := u.current(); subnet != nil {
+			u.filterStatus(server)
+		}
+	})
+
+	u.commands = map[string]func(args string){
+		"raw": func(args string) {
+			name, args, _ := strings.Cut(strings.TrimSpace(args), " ")
+			if name == "" {
+				u.statusline.SetText("Usage: raw <command> [json arguments]")
+				return
+			}
+			server, _ := u.current()
+			if server == nil {
+				u.statusline.SetText("Select a server first")
+				return
+			}
+			reqctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+			resps, err := server.client.Raw(reqctx, []string{"dhcp4"}, name, strings.TrimSpace(args))
+			cancel()
+			if err != nil {
+				u.statusline.SetText(err.Error())
+				return
+			}
+			u.showText(name, resps.String())
+		},
+	}
+	u.commands["broadcast"] = u.broadcastCommand
+	u.commands["resend-ddns"] = func(args string) {
+		switch strings.TrimSpace(args) {
+		case "":
+			u.bulkResendDdns()
+		case "mismatched":
+			if server, subnet := u.current(); subnet != nil {
+				u.resendMismatched(server)
+				return
+			}
+			u.statusline.SetText("Select a subnet first")
+		default:
+			u.statusline.SetText("Usage: resend-ddns [mismatched]")
+		}
+	}
+	u.commands["rehome"] = func(string) { u.rehome() }
+	u.commands["rename"] = u.renameReservations
+	u.commands["queue"] = func(string) { u.toggleQueueing() }
+	u.commands["mouse"] = func(string) {
+		u.setMouse(!u.mouse)
+		if u.mouse {
+			u.statusline.SetText("Mouse on, right-click a lease for its actions")
+		} else {
+			u.statusline.SetText("Mouse off, the terminal selects text by dragging")
+		}
+	}
+	u.commands["conflicts"] = func(string) { u.showConflicts() }
+	u.commands["d2"] = func(string) { u.showD2() }
+	u.commands["config"] = u.showConfig
+	u.commands["options"] = func(string) { u.showOptions() }
+	u.commands["cache"] = func(string) { u.showHostCache() }
+	u.commands["hooks"] = func(string) { u.showHooks() }
+	u.commands["user-context"] = func(string) { u.editUserContext() }
+	u.commands["dns-check"] = func(string) { u.checkDNS() }
+	u.commands["sanitize"] = u.sanitizePreview
+	u.commands["save-filter"] = func(args string) {
+		name := strings.TrimSpace(args)
+		server, subnet := u.current()
+		switch {
+		case name == "":
+			u.statusline.SetText("Usage: save-filter <name>")
+		case subnet == nil || server.view.filter == "":
+			u.statusline.SetText("No filter to save")
+		default:
+			if err := u.config.SaveFilter(name, server.view.filter); err != nil {
+				u.statusline.SetText(err.Error())
+				return
+			}
+			u.statusline.SetText("Saved filter \"" + name + "\"")
+		}
+	}
+	u.commands["diff"] = func(args string) {
+		names := strings.Fields(args)
+		if server, _ := u.current(); len(names) == 1 && server != nil {
+			names = []string{server.name, names[0]}
+		}
+		if len(names) == 2 {
+			u.diffServers(names[0], names[1])
+			return
+		}
+		var open []string
+		for _, s := range u.servers {
+			open = append(open, s.name)
+		}
+		u.fuzzyPicker("Compare", open, func(a string) {
+			u.fuzzyPicker("Compare "+a+" with", open, func(b string) {
+				u.diffServers(a, b)
+			})
+		})
+	}
+	u.cmdinput.SetDoneFunc(func(key tcell.Key) {
+		u.statuspage.SwitchToPage("line")
+		u.app.SetFocus(u.prev)
+		line := u.cmdinput.GetText()
+		u.cmdinput.SetText("")
+		if key != tcell.KeyEnter || strings.TrimSpace(line) == "" {
+			return
+		}
+		name, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if run, ok := u.commands[name]; ok {
+			run(args)
+		} else {
+			u.statusline.SetText("Unknown command \"" + name + "\"")
+		}
+	})
+	return u
+}
+
+func (u *ui) run() error {
+	for _, s := range u.servers {
+		go u.pollServer(s)
+	}
+	go u.autoRefresh()
+	go u.pollSNMP()
+	return u.app.SetRoot(u.pages, true).SetFocus(u.grid).Run()
+}
+
+// Lists the servers and their subnets in the sidebar, grouped when
+// servers belong to groups. Collapsed groups hide their servers.
+func (u *ui) fillSidebar() {
+	u.sidebar.Clear()
+	u.entries = nil
+	add := func(e sidebarEntry, label string) {
+		u.entries = append(u.entries, e)
+		u.sidebar.AddItem(label, "", 0, nil)
+	}
+	// Subnets of shared networks are listed below their network
+	addSubnets := func(s *serverView, indent string) {
+		network := ""
+		for i := range s.subnets {
+			subnet := &s.subnets[i]
+			label := indent + subnet.Subnet
+			if subnet.SharedNetwork != "" {
+				if subnet.SharedNetwork != network {
+					add(sidebarEntry{server: s, network: subnet.SharedNetwork}, indent+"⋈ "+subnet.SharedNetwork)
+				}
+				label = indent + "  " + subnet.Subnet
+			}
+			if subnet.Is4o6() {
+				label += " (4o6)"
+			}
+			network = subnet.SharedNetwork
+			add(sidebarEntry{server: s, subnet: subnet}, label)
+		}
+	}
+	if len(u.servers) == 1 {
+		addSubnets(u.servers[0], "")
+		return
+	}
+	add(sidebarEntry{}, "All servers")
+	var groups []string
+	members := map[string][]*serverView{}
+	for _, s := range u.servers {
+		if _, ok := members[s.group]; !ok && s.group != "" {
+			groups = append(groups, s.group)
+		}
+		members[s.group] = append(members[s.group], s)
+	}
+	sort.Strings(groups)
+	addServer := func(s *serverView) {
+		add(sidebarEntry{server: s}, u.serverLabel(s))
+		addSubnets(s, serverIndent(s)+"  ")
+	}
+	for _, g := range groups {
+		arrow := "▾"
+		if u.collapsed[g] {
+			arrow = "▸"
+		}
+		add(sidebarEntry{group: g}, arrow+" "+g)
+		if !u.collapsed[g] {
+			for _, s := range members[g] {
+				addServer(s)
+			}
+		}
+	}
+	for _, s := range members[""] {
+		addServer(s)
+	}
+}
+
+// Collapses or expands a group in the sidebar
+func (u *ui) toggleGroup(group string) {
+	u.collapsed[group] = !u.collapsed[group]
+	u.fillSidebar()
+	for i, e := range u.entries {
+		if e.group == group {
+			u.sidebar.SetCurrentItem(i)
+		}
+	}
+}
+
+// Servers in groups are indented below their group
+func serverIndent(s *serverView) string {
+	if s.group != "" {
+		return "  "
+	}
+	return ""
+}
+
+// Opens the server picker. Choosing a server that is not open yet
+// connects to it first.
+func (u *ui) pickServer() {
+	var names []string
+	open := map[string]bool{}
+	for _, s := range u.servers {
+		names = append(names, s.name)
+		open[s.name] = true
+	}
+	for _, name := range u.config.ServerNames() {
+		if !open[name] {
+			names = append(names, name)
+		}
+	}
+	u.fuzzyPicker("Servers", names, func(name string) {
+		for _, s := range u.servers {
+			if s.name == name {
+				u.selectServer(s)
+				return
+			}
+		}
+		s, err := newServerView(u.config, name, u.strict)
+		if err != nil {
+			u.statusline.SetText(err.Error())
+			return
+		}
+		u.statusline.SetText("Connecting to " + name + "...")
+		go func() {
+			loadServers(u.ctx, []*serverView{s})
+			u.app.QueueUpdateDraw(func() {
+				u.servers = append(u.servers, s)
+				go u.pollServer(s)
+				u.fillSidebar()
+				u.updateHealth()
+				u.selectServer(s)
+			})
+		}()
+	})
+}
+
+// Moves the sidebar to a server, or to the subnet of it shown last,
+// and shows it
+func (u *ui) selectServer(s *serverView) {
+	if u.collapsed[s.group] {
+		u.toggleGroup(s.group)
+	}
+	for i, e := range u.entries {
+		if e.server == s && e.subnet == s.view.subnet {
+			u.sidebar.SetCurrentItem(i)
+			break
+		}
+	}
+	u.statusline.SetText(s.String())
+	u.updateTable()
+	u.prev = u.sidebar
+	u.app.SetFocus(u.sidebar)
+}
+
+// Returns the sidebar label of a server, with its health dot
+func (u *ui) serverLabel(s *serverView) string {
+	label := strings.TrimSpace(s.health.dot() + " " + s.name)
+	switch {
+	case s.err != nil:
+		label += " (unreachable)"
+	case s.health == healthDown:
+		label += " (not responding)"
+	}
+	return serverIndent(s) + label
+}
+
+// Returns the server and the subnet selected in the sidebar. The
+// subnet is nil when a server itself is selected, and both are nil
+// for the aggregated view.
+func (u *ui) current() (*serverView, *Subnet4) {
+	i := u.sidebar.GetCurrentItem()
+	if i < 0 || i >= len(u.entries) {
+		return u.servers[0], nil
+	}
+	return u.entries[i].server, u.entries[i].subnet
+}
+
+// Shows the selected subnet in the display mode of its server, or an
+// overview of the selected server
+func (u *ui) updateTable() {
+	u.visual = 0
+	server, subnet := u.current()
+	if server == nil {
+		leases, err := AllLeases(u.ctx, u.servers)
+		u.showAllLeases(leases, err)
+		return
+	}
+	if network := u.currentNetwork(); network != "" {
+		leases, err := server.networkLeases(u.ctx, network)
+		u.showNetwork(server, network, leases, err)
+		return
+	}
+	if subnet == nil {
+		u.table.SetTitle("Server")
+		ServerTable(server, u.table)
+		return
+	}
+	view := &server.view
+	previous := view.leases
+	if view.subnet != subnet {
+		for key := range view.selected {
+			delete(view.selected, key)
+		}
+		view.known, view.fresh = nil, nil
+		view.changes, previous = nil, nil
+		view.dnsIssues = nil
+	}
+	view.subnet = subnet
+	view.leases = nil
+	view.appeared = nil
+	u.refreshTable(server)
+	u.leasesFetched(server, previous)
+}
+
+// Reports what changed in the leases of the subnet of a server since
+// they were fetched before, previous for none, follows the newest
+// lease and fires the events of the leases that appeared
+func (u *ui) leasesFetched(server *serverView, previous []Lease4) {
+	view := &server.view
+	if previous != nil && view.leases != nil {
+		view.changes = nil
+		for _, c := range DiffLeases(previous, view.leases) {
+			if c.Kind != LeaseRenewed {
+				view.changes = append(view.changes, c)
+			}
+		}
+		if len(view.changes) > 0 {
+			u.statusline.SetText(fmt.Sprintf("%d leases changed, C shows them", len(view.changes)))
+		}
+	}
+	if view.follow {
+		u.selectNewest()
+	}
+	for i := range view.appeared {
+		u.fireEvent(Event{
+			Type:   eventNewLease,
+			Server: server.name,
+			Subnet: view.subnet.Subnet,
+			Lease:  &view.appeared[i],
+			Text:   "new lease " + view.appeared[i].IpAddress,
+		})
+	}
+}
+
+// Shows the subnet of a server again without fetching its leases,
+// for changes of the filter
+func (u *ui) refreshTable(server *serverView) {
+	u.visual = 0
+	view := &server.view
+	switch view.dispmode {
+	case displayLeases:
+		title := "Leases"
+		if view.reserved != reservedAll {
+			title = "Leases (" + view.reserved.String() + ")"
+		}
+		if view.filter != "" {
+			title += " (filter: " + view.filter + ")"
+		}
+		if view.follow {
+			title += " (following)"
+		}
+		u.table.SetTitle(title)
+	case displayReserv:
+		title := "Reservations"
+		if view.filter != "" {
+			title += " (filter: " + view.filter + ")"
+		}
+		u.table.SetTitle(title)
+	case displayInfo:
+		u.table.SetTitle("Subnet Information")
+	}
+	UpdateTable(u.ctx, server.client, view, u.table)
+}
+
+// Shows the table again for a change of the lease columns, without
+// fetching leases. Subnets are redrawn from the leases of their view.
+// The tables of all leases and of shared networks keep none, so they
+// get the columns when they are loaded again from the sidebar.
+func (u *ui) redrawColumns() {
+	server, subnet := u.current()
+	switch {
+	case server == nil || u.currentNetwork() != "":
+		u.statusline.SetText("The columns change when the table is loaded again, Enter in the sidebar loads it")
+	case subnet != nil:
+		u.refreshTable(server)
+	}
+}
+
+// Applies a filter saved in the configuration, chosen from a picker
+func (u *ui) pickFilter() {
+	server, subnet := u.current()
+	if subnet == nil || server.view.dispmode == displayInfo {
+		u.statusline.SetText("Filters apply to the leases and reservations of a subnet")
+		return
+	}
+	names := u.config.FilterNames()
+	if len(names) == 0 {
+		u.statusline.SetText("No saved filters, :save-filter <name> saves the current one")
+		return
+	}
+	u.fuzzyPicker("Filters", names, func(name string) {
+		server.view.filter = u.config.Filters[name]
+		u.refreshTable(server)
+		u.filterStatus(server)
+	})
+}
+
+// Shows how many of the leases or reservations of a server's subnet
+// pass its filter
+func (u *ui) filterStatus(server *serverView) {
+	view := &server.view
+	total, what := len(view.leases), "leases"
+	switch {
+	case view.dispmode == displayReserv:
+		total, what = len(view.subnet.Reservations), "reservations"
+	case view.dispmode != displayLeases || view.leases == nil:
+		return
+	}
+	if _, err := ParseLeaseFilter(view.filter); err != nil {
+		u.statusline.SetText("Filter: " + err.Error())
+		return
+	}
+	u.statusline.SetText(fmt.Sprintf("%d/%d %s", u.table.GetRowCount()-1, total, what))
+}
+
+// Shows a server's address, version and subnets
+func ServerTable(server *serverView, table *tview.Table) {
+	table.Clear()
+	row := func(i int, name, value string) {
+		table.SetCell(i, 0, tview.NewTableCell(name).SetTextColor(tcell.ColorYellow))
+		table.SetCell(i, 1, tview.NewTableCell(value))
+	}
+	row(0, "Server", server.name)
+	row(1, "Group", server.group)
+	row(2, "Version", server.client.Compat.String())
+	row(3, "Read-only", strconv.FormatBool(server.client.ReadOnly))
+	if server.err != nil {
+		row(4, "Error", server.err.Error())
+		table.GetCell(4, 1).SetTextColor(tcell.ColorRed)
+		return
+	}
+	row(4, "Subnets", strconv.Itoa(len(server.subnets)))
+	i := 5
+	if server.settings != nil {
+		for _, field := range server.settings.Fields() {
+			row(i, field[0], field[1])
+			i++
+		}
+	}
+	if server.status != nil {
+		row(i, "Threads running", server.status.ThreadingText())
+		i++
+	}
+	if server.status != nil && len(server.status.HighAvailability) > 0 {
+		data, _ := json.Marshal(server.status.HighAvailability)
+		row(i, "HA", "")
+		table.SetCell(i, 1, jsonCell(data))
+	}
+	table.ScrollToBeginning()
+}
+
+// Returns the lease shown in a table row and the server it is from,
+// or nil if the row is not a lease
+func (u *ui) rowLease(row int) (*serverView, *Lease4) {
+	switch ref := u.table.GetCell(row, 0).GetReference().(type) {
+	case Lease4:
+		server, _ := u.current()
+		return server, &ref
+	case serverLease:
+		return ref.server, &ref.Lease4
+	}
+	return nil, nil
+}
+
+// Shows the differences between the configurations of two open
+// servers
+func (u *ui) diffServers(a, b string) {
+	var configs []map[string]any
+	for _, name := range []string{a, b} {
+		var server *serverView
+		for _, s := range u.servers {
+			if s.name == name {
+				server = s
+			}
+		}
+		if server == nil {
+			u.statusline.SetText("No server \"" + name + "\" open")
+			return
+		}
+		reqctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+		config, err := server.client.GetConfig(reqctx)
+		cancel()
+		if err != nil {
+			u.statusline.SetText(name + ": " + err.Error())
+			return
+		}
+		configs = append(configs, config)
+	}
+	diffs := DiffConfigs(configs[0], configs[1])
+	if len(diffs) == 0 {
+		u.statusline.SetText("The configurations of " + a + " and " + b + " are the same")
+		return
+	}
+	table := tview.NewTable().
+		SetSeparator(tview.Borders.Vertical).
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	for i, name := range []string{"Setting", a, b} {
+		table.SetCell(0, i, tview.NewTableCell(name).
+			SetTextColor(tcell.ColorYellow).
+			SetSelectable(false))
+	}
+	for i, d := range diffs {
+		color := tcell.ColorYellow
+		switch {
+		case d.New == "":
+			color = tcell.ColorRed
+		case d.Old == "":
+			color = tcell.ColorGreen
+		}
+		table.SetCell(i+1, 0, tview.NewTableCell(d.Path).SetTextColor(color))
+		table.SetCell(i+1, 1, tview.NewTableCell(d.Old).SetMaxWidth(40))
+		table.SetCell(i+1, 2, tview.NewTableCell(d.New).SetMaxWidth(40))
+	}
+	u.showTable(fmt.Sprintf("%d differences", len(diffs)), table)
+}
+
+// Asks for confirmation and runs yes if given
+func (u *ui) confirm(text string, yes func()) {
+	u.ask(text, []string{"Cancel", "OK"}, func(label string) {
+		if label == "OK" {
+			yes()
+		}
+	})
+}
+
+// Shows a question with buttons and passes the label of the button
+// pressed to answer, which is empty when the question was dismissed
+func (u *ui) ask(text string, buttons []string, answer func(label string)) {
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons(buttons).
+		SetDoneFunc(func(index int, label string) {
+			u.pages.RemovePage("confirm")
+			u.app.SetFocus(u.prev)
+			answer(label)
+		})
+	u.pages.AddPage("confirm", modal, true, true)
+	u.app.SetFocus(modal)
+}
+
+// Shows a table in a popup that Escape closes
+func (u *ui) showTable(title string, table *tview.Table) {
+	table.SetBorder(true)
+	table.SetTitle(title)
+	table.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			u.pages.RemovePage("popup")
+			u.app.SetFocus(u.prev)
+		}
+	})
+	u.pages.AddPage("popup", table, true, true)
+	u.app.SetFocus(table)
+}
+
+func (u *ui) showText(title string, text string) {
+	view := tview.NewTextView().SetText(text)
+	view.SetBorder(true)
+	view.SetTitle(title)
+	view.SetDoneFunc(func(key tcell.Key) {
+		u.pages.RemovePage("popup")
+		u.app.SetFocus(u.prev)
+	})
+	u.pages.AddPage("popup", view, true, true)
+	u.app.SetFocus(view)
+}
+
+func (u *ui) sidebarKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyTab {
+		u.app.SetFocus(u.table)
+		return nil
+	}
+	if event.Rune() == 'l' {
+		u.app.SetFocus(u.table)
+		return nil
+	}
+	if event.Key() == tcell.KeyRight {
+		u.app.SetFocus(u.table)
+		return nil
+	}
+	if event.Rune() == 'n' {
+		SearchForwardList(u.statusinput, u.sidebar, u.statusline)
+		return event
+	}
+	if event.Rune() == 'N' {
+		indexes := u.sidebar.FindItems(u.statusinput.GetText(), "", false, false)
+		curr := u.sidebar.GetCurrentItem()
+		for j, i := range indexes {
+			if i >= curr && j > 0 {
+				u.statusline.SetText("?" + u.statusinput.GetText())
+				u.sidebar.SetCurrentItem(indexes[j-1])
+				if indexes[j-1] == curr {
+					u.statusline.SetText("Pattern not found \"" + u.statusinput.GetText() + "\"")
+				}
+				return event
+			}
+		}
+		u.statusline.SetText("Pattern not found \"" + u.statusinput.GetText() + "\"")
+		return event
+	}
+	if event.Rune() == '/' {
+		u.statuspage.SwitchToPage("input")
+		u.prev = u.sidebar
+		u.app.SetFocus(u.statuspage)
+		return nil
+	}
+	return event
+}
+
+func (u *ui) tableKeys(event *tcell.EventKey) *tcell.EventKey {
+	table := u.table
+	if event.Key() == tcell.KeyTab {
+		u.app.SetFocus(u.sidebar)
+		return nil
+	}
+	_, col := table.GetOffset()
+	if _, cols := table.GetSelectable(); cols {
+		_, col = table.GetSelection()
+	}
+	if col < 1 {
+		if event.Rune() == 'h' {
+			u.app.SetFocus(u.sidebar)
+			return nil
+		}
+		if event.Key() == tcell.KeyLeft {
+			u.app.SetFocus(u.sidebar)
+			return nil
+		}
+	}
+	if event.Rune() == 'n' {
+		SearchForwardTable(u.statusinput, table, u.statusline)
+		return event
+	}
+	if event.Rune() == 'N' {
+		curr, _ := table.GetSelection()
+		for i := curr - 1; i > 0; i-- {
+			for j := 0; j < table.GetColumnCount(); j++ {
+				if strings.Contains(table.GetCell(i, j).Text, u.statusinput.GetText()) {
+					table.SetSelectable(true, true)
+					table.Select(i, 0)
+					u.statusline.SetText("?" + u.statusinput.GetText())
+					return event
+				}
+			}
+		}
+		u.statusline.SetText("Pattern not found \"" + u.statusinput.GetText() + "\"")
+		return event
+	}
+	server, subnet := u.current()
+	if event.Rune() == 'd' && len(u.selectedRows()) > 0 {
+		u.bulkDelete()
+		return nil
+	}
+	if selectable, _ := table.GetSelectable(); event.Rune() == 'd' && selectable {
+		row, _ := table.GetSelection()
+		server, lease := u.rowLease(row)
+		if lease == nil {
+			return nil
+		}
+		if u.queueing || dryRun {
+			u.submit("Deleting leases", u.bulkLeases([]int{row}, deleteLease), 0, dryRun, nil)
+			return nil
+		}
+		reqctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+		_, text, err := server.client.DelLease(reqctx, lease.IpAddress)
+		cancel()
+		if err != nil {
+			text = err.Error()
+		}
+		u.statusline.SetText(text)
+		return nil
+	}
+	if selectable, _ := table.GetSelectable(); event.Rune() == '!' && selectable {
+		row, _ := table.GetSelection()
+		u.launchTool(row)
+		return nil
+	}
+	if selectable, _ := table.GetSelectable(); event.Rune() == 'P' && selectable {
+		row, _ := table.GetSelection()
+		u.pingRow(row)
+		return nil
+	}
+	if selectable, _ := table.GetSelectable(); event.Rune() == 'i' && selectable {
+		row, _ := table.GetSelection()
+		u.showDetails(row)
+		return nil
+	}
+	if selectable, _ := table.GetSelectable(); selectable && (event.Rune() == 'y' || event.Rune() == 'Y') {
+		row, col := table.GetSelection()
+		text := table.GetCell(row, col).Text
+		if event.Rune() == 'Y' {
+			cells := make([]string, table.GetColumnCount())
+			for j := range cells {
+				cells[j] = table.GetCell(row, j).Text
+			}
+			text = strings.Join(cells, "\t")
+		}
+		if err := CopyToClipboard(text); err != nil {
+			u.statusline.SetText(err.Error())
+			return nil
+		}
+		u.statusline.SetText("Copied \"" + text + "\"")
+		return nil
+	}
+	if event.Rune() == 'e' {
+		hosts := TableHosts(table)
+		selection := RowHosts(table, u.selectedRows())
+		if subnet == nil || len(hosts) == 0 {
+			u.statusline.SetText("Nothing to export")
+			return nil
+		}
+		formats := tview.NewList().ShowSecondaryText(false)
+		formats.SetBorder(true)
+		formats.SetTitle("Export")
+		// With rows selected, the first item switches between exporting
+		// them only and exporting as without a selection
+		selectionOnly := len(selection) > 0
+		if selectionOnly {
+			label := func() string {
+				mark := " "
+				if selectionOnly {
+					mark = "x"
+				}
+				return tview.Escape(fmt.Sprintf("[%s] Selection only (%d rows)", mark, len(selection)))
+			}
+			formats.AddItem(label(), "", 0, func() {
+				selectionOnly = !selectionOnly
+				formats.SetItemText(0, label(), "")
+			})
+		}
+		for _, e := range exporters {
+			e := e
+			formats.AddItem(e.Name, "", 0, func() {
+				u.pages.RemovePage("export")
+				hosts := hosts
+				if selectionOnly {
+					hosts = selection
+				}
+				var out strings.Builder
+				err := e.Write(&out, subnet, hosts)
+				if err != nil && out.Len() == 0 {
+					u.statusline.SetText(err.Error())
+					u.app.SetFocus(table)
+					return
+				}
+				// What was written before failing shows how far it got
+				u.showText(e.Name, out.String())
+				if err != nil {
+					u.statusline.SetText(err.Error())
+				}
+			})
+		}
+		// NetBox is written to rather than exported to, in the background
+		formats.AddItem("NetBox", "", 0, func() {
+			u.pages.RemovePage("export")
+			hosts := hosts
+			if selectionOnly {
+				hosts = selection
+			}
+			u.app.SetFocus(table)
+			u.pushNetBox(server, subnet, hosts)
+		})
+		formats.SetDoneFunc(func() {
+			u.pages.RemovePage("export")
+			u.app.SetFocus(table)
+		})
+		u.prev = table
+		u.pages.AddPage("export", formats, true, true)
+		u.app.SetFocus(formats)
+		return nil
+	}
+	if event.Rune() == 'A' {
+		u.selectAll()
+		return nil
+	}
+	if server, subnet := u.current(); event.Rune() == 'E' && subnet != nil && server.view.dispmode == displayReserv {
+		row, _ := table.GetSelection()
+		u.editReservation(row)
+		return nil
+	}
+	if event.Rune() == 'V' {
+		u.toggleVisual()
+		return nil
+	}
+	if selectable, _ := table.GetSelectable(); event.Rune() == ' ' && selectable {
+		row, _ := table.GetSelection()
+		u.toggleRow(row)
+		return nil
+	}
+	if event.Key() == tcell.KeyEnter {
+		row, _ := table.GetSelectable()
+		if u.pick && row {
+			selected, _ := table.GetSelection()
+			if _, l := u.rowLease(selected); l != nil {
+				u.picked = FormatLease(u.pickFormat, l)
+				u.app.Stop()
+				return nil
+			}
+		}
+		table.SetSelectable(!row, !row)
+	}
+	if event.Rune() == '/' {
+		u.statuspage.SwitchToPage("input")
+		u.prev = table
+		u.app.SetFocus(u.statuspage)
+		return nil
+	}
+	return event
+}
+
+func (u *ui) globalKeys(event *tcell.EventKey) *tcell.EventKey {
+	if (event.Rune() == 'q' || event.Key() == tcell.KeyEscape) && !u.statuspage.HasFocus() {
+		u.app.Stop()
+		return nil
+	}
+	if u.statuspage.HasFocus() {
+		return event
+	}
+	if event.Rune() == ':' {
+		u.prev = u.app.GetFocus()
+		u.statuspage.SwitchToPage("command")
+		u.app.SetFocus(u.statuspage)
+		return nil
+	}
+	if server, subnet := u.current(); event.Rune() == 'x' && subnet != nil {
+		server.view.reclaimed = !server.view.reclaimed
+		u.refreshTable(server)
+		if server.view.reclaimed {
+			u.statusline.SetText("Showing expired-reclaimed leases")
+		} else {
+			u.statusline.SetText("Hiding expired-reclaimed leases")
+		}
+		return nil
+	}
+	if server, subnet := u.current(); event.Rune() == 'R' && subnet != nil {
+		server.view.reserved = (server.view.reserved + 1) % 3
+		u.refreshTable(server)
+		u.statusline.SetText("Showing " + server.view.reserved.String())
+		return nil
+	}
+	if event.Rune() == 'F' {
+		u.pickFilter()
+		return nil
+	}
+	if event.Rune() == 'f' {
+		server, subnet := u.current()
+		if subnet == nil || server.view.dispmode == displayInfo {
+			u.statusline.SetText("Filters apply to the leases and reservations of a subnet")
+			return nil
+		}
+		u.prev = u.app.GetFocus()
+		u.filterinput.SetText(server.view.filter)
+		u.statuspage.SwitchToPage("filter")
+		u.app.SetFocus(u.statuspage)
+		return nil
+	}
+	if event.Rune() == 'Q' {
+		u.showQueue()
+		return nil
+	}
+	if event.Rune() == 'D' {
+		u.dismissAlert()
+		return nil
+	}
+	if event.Rune() == 'C' {
+		u.showChanges()
+		return nil
+	}
+	if event.Rune() == 'a' {
+		u.showFree()
+		return nil
+	}
+	if event.Rune() == 'o' {
+		showVendor = !showVendor
+		u.redrawColumns()
+		if showVendor {
+			if err := loadVendors(); err != nil {
+				u.statusline.SetText(err.Error())
+			}
+		}
+		return nil
+	}
+	if event.Rune() == 'p' {
+		showPTR = !showPTR
+		u.redrawColumns()
+		return nil
+	}
+	if server, subnet := u.current(); event.Rune() == 't' && subnet != nil {
+		server.view.follow = !server.view.follow
+		u.refreshTable(server)
+		if server.view.follow {
+			u.selectNewest()
+			u.statusline.SetText("Following the newest lease")
+		} else {
+			u.statusline.SetText("Stopped following")
+		}
+		return nil
+	}
+	if event.Key() == tcell.KeyF12 {
+		u.toggleDebug()
+		return nil
+	}
+	if event.Rune() == 's' {
+		u.pickServer()
+		return nil
+	}
+	if server, subnet := u.current(); event.Rune() == 'm' && subnet != nil {
+		server.view.dispmode = (server.view.dispmode + 1) % 3
+		u.updateTable()
+	}
+	return event
+}
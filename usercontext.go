@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// Parses a user context as typed in, which must be a JSON object.
+// Nothing but blanks removes the context, for which nil is returned.
+func ParseUserContext(text string) (json.RawMessage, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+	var object map[string]any
+	if err := json.Unmarshal([]byte(text), &object); err != nil {
+		return nil, errors.New("user context: " + err.Error())
+	}
+	if object == nil {
+		return nil, errors.New("user context: must be a JSON object")
+	}
+	var b bytes.Buffer
+	json.Compact(&b, []byte(text))
+	return b.Bytes(), nil
+}
+
+// Sets the user context of a subnet, or of one of its pools, through
+// subnet_cmds. A nil context removes it.
+func (c *Client) SetUserContext(ctx context.Context, subnet, pool int, userContext json.RawMessage) error {
+	return c.updateSubnet(ctx, subnet, func(config map[string]any) error {
+		scope, err := subnetScope(config, pool)
+		if err != nil {
+			return err
+		}
+		if userContext == nil {
+			delete(scope, "user-context")
+		} else {
+			scope["user-context"] = userContext
+		}
+		return nil
+	})
+}
+
+// Edits the user context of the reservation selected in the
+// reservations view, or else of the subnet shown or one of its pools,
+// picked from a list
+func (u *ui) editUserContext() {
+	server, subnet := u.current()
+	if subnet == nil {
+		u.statusline.SetText("Select a subnet to edit user contexts")
+		return
+	}
+	row, _ := u.table.GetSelection()
+	if r, ok := u.table.GetCell(row, 0).GetReference().(Reservation); ok && server.view.dispmode == displayReserv {
+		u.userContextForm("User context of "+r.IpAddress, r.UserContext, func(userContext json.RawMessage) {
+			edited := r
+			edited.UserContext = userContext
+			items := []bulkItem{{
+				label: edited.IpAddress,
+				run: func(ctx context.Context) error {
+					return server.client.UpdateReservation(ctx, subnet.Id, edited)
+				},
+				after: func() {
+					for i := range subnet.Reservations {
+						if subnet.Reservations[i].IpAddress == edited.IpAddress {
+							subnet.Reservations[i].UserContext = userContext
+						}
+					}
+				},
+			}}
+			u.submit("Updating reservation "+edited.IpAddress, items, 0, dryRun, u.updateTable)
+		})
+		return
+	}
+	scopes := []string{"subnet " + subnet.Subnet}
+	for _, pool := range subnet.Pools {
+		scopes = append(scopes, "pool "+pool.Pool)
+	}
+	u.fuzzyPicker("User context of", scopes, func(scope string) {
+		pool := -1
+		for i := range subnet.Pools {
+			if scope == "pool "+subnet.Pools[i].Pool {
+				pool = i
+			}
+		}
+		current := &subnet.UserContext
+		if pool >= 0 {
+			current = &subnet.Pools[pool].UserContext
+		}
+		u.userContextForm("User context of "+scope, *current, func(userContext json.RawMessage) {
+			items := []bulkItem{{
+				label: scope,
+				run: func(ctx context.Context) error {
+					return server.client.SetUserContext(ctx, subnet.Id, pool, userContext)
+				},
+				after: func() { *current = userContext },
+			}}
+			u.submit("Updating the user context of "+scope, items, 0, dryRun, u.updateTable)
+		})
+	})
+}
+
+// Opens a form editing a user context as JSON. save is called with
+// the new context once it parses, nil when it was cleared.
+func (u *ui) userContextForm(title string, userContext json.RawMessage, save func(json.RawMessage)) {
+	var text bytes.Buffer
+	json.Compact(&text, userContext)
+	form := tview.NewForm().
+		AddInputField("User context", text.String(), 70, nil, nil)
+	closeForm := func() {
+		u.pages.RemovePage("form")
+		u.app.SetFocus(u.table)
+	}
+	form.AddButton("Save", func() {
+		parsed, err := ParseUserContext(form.GetFormItemByLabel("User context").(*tview.InputField).GetText())
+		if err != nil {
+			u.statusline.SetText(err.Error())
+			return
+		}
+		closeForm()
+		u.prev = u.table
+		save(parsed)
+	})
+	form.AddButton("Cancel", closeForm)
+	form.SetCancelFunc(closeForm)
+	form.SetBorder(true)
+	form.SetTitle(title)
+	u.pages.AddPage("form", centered(form, 90, 7), true, true)
+	u.app.SetFocus(form)
+}
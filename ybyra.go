@@ -2,12 +2,13 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
+	"fmt"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
-	"io/ioutil"
 	"net"
-	"net/http"
 	"os"
 	"sort"
 	"strconv"
@@ -15,64 +16,88 @@ import (
 	"time"
 )
 
-type command string
 type displayMode uint8
 
+// Upper bound for a single request to the Control Agent
+var requestTimeout = 10 * time.Second
+
 const (
 	displayLeases displayMode = 0
 	displayReserv             = 1
 	displayInfo               = 2
 )
 
-const (
-	configGet    command = "config-get"
-	statusGet            = "status-get"
-	lease4GetAll         = "lease4-get-all"
-	lease4Del            = "lease4-del"
-)
-
 const (
 	leaseColumns = 6
 )
 
-type KeaRequest[T any] struct {
-	Arguments T        `json:"arguments"`
-	Command   command  `json:"command"`
-	Service   []string `json:"service""`
+type KeaStatus struct {
+	HighAvailability      []HAStatus `json:"high-availability"`
+	Result                int        `json:"result"`
+	MultiThreadingEnabled bool       `json:"multi-threading-enabled"`
+	Pid                   int        `json:"pid"`
+	Reload                int        `json:"reload"`
+	Uptime                int        `json:"uptime"`
+
+	// Reported when multi-threading is enabled. The statistics are
+	// the average queue sizes over the last 10, 100 and 1000 packets.
+	ThreadPoolSize        int       `json:"thread-pool-size"`
+	PacketQueueSize       int       `json:"packet-queue-size"`
+	PacketQueueStatistics []float64 `json:"packet-queue-statistics"`
 }
 
-type KeaResponse struct {
-	Arguments map[string]json.RawMessage `json:"arguments,omitempty"`
-	Result    int                        `json:"result"`
-	Text      string                     `json:"text,omitempty"`
+type HAStatus struct {
+	Mode    string              `json:"ha-mode"`
+	Servers map[string]HAServer `json:"ha-servers"`
 }
 
-type KeaStatus struct {
-	HighAvailability      map[string]json.RawMessage `json:"high-availability"`
-	Result                int                        `json:"result"`
-	MultiThreadingEnabled bool                       `json:"multi-threading-enabled"`
-	Pid                   int                        `json:"pid"`
-	Reload                int                        `json:"reload"`
-	Uptime                int                        `json:"uptime"`
+// The local server reports its state, the remote one the last state
+// it was seen in
+type HAServer struct {
+	CommunicationInterrupted bool   `json:"communication-interrupted"`
+	InTouch                  bool   `json:"in-touch"`
+	LastState                string `json:"last-state"`
+	Role                     string `json:"role"`
+	ServerName               string `json:"server-name"`
+	State                    string `json:"state"`
 }
 
 type Subnet4 struct {
-	FourSixInterface   string                     `json:"4o6-interface"`
-	FourSixInterfaceId string                     `json:"4o6-interface-id"`
-	FourSixSubnet      string                     `json:"4o6-subnet"`
-	CalculateTeeTimes  bool                       `json:"calculate-tee-times"`
-	Id                 int                        `json:"id"`
-	OptionData         []OptionData               `json:"option-data"`
-	Pools              []Pool                     `json:"pools"`
-	RebindTimer        int                        `json:"rebind-timer"`
-	Relay              map[string]json.RawMessage `json:"relay"`
-	RenewTimer         int                        `json:"renew-timer"`
-	Reservations       []Reservation              `json:"reservations"`
-	StoreExtendedInfo  bool                       `json:"store-extended-info"`
-	Subnet             string                     `json:"subnet"`
-	T1Percent          float32                    `json:"t1-percent"`
-	T2Percent          float32                    `json:"t2-percent"`
-	ValidLifetime      int                        `json:"valid-lifetime"`
+	FourSixInterface   string        `json:"4o6-interface"`
+	FourSixInterfaceId string        `json:"4o6-interface-id"`
+	FourSixSubnet      string        `json:"4o6-subnet"`
+	CalculateTeeTimes  bool          `json:"calculate-tee-times"`
+	Id                 int           `json:"id"`
+	OptionData         []OptionData  `json:"option-data"`
+	Pools              []Pool        `json:"pools"`
+	RebindTimer        int           `json:"rebind-timer"`
+	Relay              Relay         `json:"relay"`
+	RenewTimer         int           `json:"renew-timer"`
+	Reservations       []Reservation `json:"reservations"`
+	StoreExtendedInfo  bool          `json:"store-extended-info"`
+	Subnet             string        `json:"subnet"`
+	T1Percent          float32       `json:"t1-percent"`
+	T2Percent          float32       `json:"t2-percent"`
+	ValidLifetime      int           `json:"valid-lifetime"`
+
+	// Unset when inherited from the shared network or global scope
+	DdnsSendUpdates            *bool    `json:"ddns-send-updates,omitempty"`
+	DdnsOverrideClientUpdate   *bool    `json:"ddns-override-client-update,omitempty"`
+	DdnsOverrideNoUpdate       *bool    `json:"ddns-override-no-update,omitempty"`
+	DdnsQualifyingSuffix       string   `json:"ddns-qualifying-suffix,omitempty"`
+	DdnsReplaceClientName      string   `json:"ddns-replace-client-name,omitempty"`
+	DdnsGeneratedPrefix        string   `json:"ddns-generated-prefix,omitempty"`
+	DdnsUpdateOnRenew          *bool    `json:"ddns-update-on-renew,omitempty"`
+	DdnsUseConflictResolution  *bool    `json:"ddns-use-conflict-resolution,omitempty"`
+	DdnsConflictResolutionMode string   `json:"ddns-conflict-resolution-mode,omitempty"`
+	DdnsTtlPercent             *float64 `json:"ddns-ttl-percent,omitempty"`
+	HostnameCharSet            *string  `json:"hostname-char-set,omitempty"`
+	HostnameCharReplacement    *string  `json:"hostname-char-replacement,omitempty"`
+
+	UserContext json.RawMessage `json:"user-context,omitempty"`
+
+	// Name of the shared network the subnet is in, if any
+	SharedNetwork string `json:"-"`
 }
 
 type Lease4 struct {
@@ -86,17 +111,32 @@ type Lease4 struct {
 	State     int    `json:"state"`
 	SubnetId  int    `json:"subnet-id"`
 	ValidLft  int    `json:"valid-lft"`
+	// Added in Kea 2.x
+	PoolId      int             `json:"pool-id,omitempty"`
+	UserContext json.RawMessage `json:"user-context,omitempty"`
+	// Fields ybyra does not model, carried through unchanged
+	Extra map[string]json.RawMessage `json:"-"`
+	// See DdnsStatus
+	ddnsStatus string
 }
 
 type Reservation struct {
-	BootFileName   string            `json:"boot-file-name"`
-	ClientClasses  []json.RawMessage `json:"client-classes"`
-	Hostname       string            `json:"hostname"`
-	HwAddress      string            `json:"hw-address"`
-	IpAddress      string            `json:"ip-address"`
-	NextServer     string            `json:"next-server"`
-	OptionData     []json.RawMessage `json:"option-data"`
-	ServerHostname string            `json:"server-hostname"`
+	BootFileName   string            `json:"boot-file-name,omitempty"`
+	ClientClasses  []json.RawMessage `json:"client-classes,omitempty"`
+	Hostname       string            `json:"hostname,omitempty"`
+	HwAddress      string            `json:"hw-address,omitempty"`
+	IpAddress      string            `json:"ip-address,omitempty"`
+	NextServer     string            `json:"next-server,omitempty"`
+	OptionData     []json.RawMessage `json:"option-data,omitempty"`
+	ServerHostname string            `json:"server-hostname,omitempty"`
+	UserContext    json.RawMessage   `json:"user-context,omitempty"`
+}
+
+// Addresses of the relays whose requests select a subnet
+type Relay struct {
+	IpAddresses []string `json:"ip-addresses,omitempty"`
+	// Single address used before Kea 1.4
+	IpAddress string `json:"ip-address,omitempty"`
 }
 
 type OptionData struct {
@@ -109,8 +149,9 @@ type OptionData struct {
 }
 
 type Pool struct {
-	OptionData []OptionData `json:"option-data"`
-	Pool       string       `json:"pool""`
+	OptionData  []OptionData    `json:"option-data"`
+	Pool        string          `json:"pool"`
+	UserContext json.RawMessage `json:"user-context,omitempty"`
 }
 
 type SortData struct {
@@ -118,86 +159,64 @@ type SortData struct {
 	Asc    bool
 }
 
-func LeaseState(state int) (string, tcell.Color) {
-	switch state {
-	case 0:
-		return "default", tcell.ColorGreen
-	case 1:
-		return "declined", tcell.ColorRed
-	case 2:
-		return "expired-reclaimed", tcell.ColorYellow
+func (r *Relay) Addresses() []string {
+	if r.IpAddress != "" {
+		return append([]string{r.IpAddress}, r.IpAddresses...)
 	}
-	return "", tcell.ColorWhite
+	return r.IpAddresses
 }
 
-func getSubnets(url string) []Subnet4 {
-	jsonbytes := sendCommand(url, configGet, "")
-	var grades []KeaResponse
-	err := json.Unmarshal(jsonbytes, &grades)
-	if err != nil {
-		panic(err)
-	}
-	var dhcp map[string]json.RawMessage
-	err = json.Unmarshal(grades[0].Arguments["Dhcp4"], &dhcp)
-	if err != nil {
-		panic(err)
-	}
-	var subnets []Subnet4
-	err = json.Unmarshal(dhcp["subnet4"], &subnets)
-	if err != nil {
-		panic(err)
-	}
-	return subnets
+// Reports whether DHCPv4-over-DHCPv6 clients are selected into the
+// subnet, which any of the 4o6 parameters does
+func (s *Subnet4) Is4o6() bool {
+	return s.FourSixInterface != "" || s.FourSixInterfaceId != "" || s.FourSixSubnet != ""
 }
 
-func getLeases(url string, subnet int) []Lease4 {
-	args := map[string][]int{"subnets": []int{subnet}}
-	jsonbytes := sendCommand(url, lease4GetAll, args)
-	var grades []KeaResponse
-	err := json.Unmarshal(jsonbytes, &grades)
-	if err != nil {
-		panic(err)
-	}
-	var leases []Lease4
-	err = json.Unmarshal(grades[0].Arguments["leases"], &leases)
-	if err != nil {
-		panic(err)
+// Returns the 4o6 parameters of a subnet that are set, as name and
+// value pairs in the names of the Kea configuration
+func (s *Subnet4) FourSixFields() [][]string {
+	var fields [][]string
+	for _, f := range [][]string{
+		{"4o6-interface", s.FourSixInterface},
+		{"4o6-interface-id", s.FourSixInterfaceId},
+		{"4o6-subnet", s.FourSixSubnet},
+	} {
+		if f[1] != "" {
+			fields = append(fields, f)
+		}
 	}
-	return leases
+	return fields
 }
 
-func sendCommand[T any](url string, comm command, args T) []byte {
-	keacomm := KeaRequest[T]{
-		Command:   comm,
-		Arguments: args,
-		Service:   []string{"dhcp4"}}
-	reqBody, err := json.MarshalIndent(keacomm, "", " ")
-	if err != nil {
-		panic(err)
-	}
-	// fmt.Println(string(reqBody))
-	resp, err := http.Post(url,
-		"application/json", bytes.NewBuffer(reqBody))
-	if err != nil {
-		panic(err)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	defer resp.Body.Close()
-	if err != nil {
-		panic(err)
+func (s *HAServer) CurrentState() string {
+	if s.State != "" {
+		return s.State
 	}
-	return body
+	return s.LastState
 }
 
-func DelLease(url string, ip string) (int, string) {
-	args := map[string]string{"ip-address": ip}
-	result := sendCommand(url, lease4Del, args)
-	var resp []KeaResponse
-	err := json.Unmarshal(result, &resp)
-	if err != nil {
-		panic(err)
+const (
+	stateDefault          = 0
+	stateDeclined         = 1
+	stateExpiredReclaimed = 2
+	stateReleased         = 3
+	stateRegistered       = 4
+)
+
+func LeaseState(state int) (string, tcell.Color) {
+	switch state {
+	case stateDefault:
+		return "default", tcell.ColorGreen
+	case stateDeclined:
+		return "declined", tcell.ColorRed
+	case stateExpiredReclaimed:
+		return "expired-reclaimed", tcell.ColorYellow
+	case stateReleased:
+		return "released", tcell.ColorBlue
+	case stateRegistered:
+		return "registered", tcell.ColorTeal
 	}
-	return resp[0].Result, resp[0].Text
+	return "", tcell.ColorWhite
 }
 
 // Helper function for comparing Leases
@@ -228,41 +247,121 @@ func (l1 *Lease4) Compare(l2 *Lease4, field int) int {
 		return cmp(l1.Cltt, l2.Cltt)
 	case 5:
 		return cmp(l1.ClientId, l2.ClientId)
+	case vendorField:
+		return cmp(macVendor(l1.HwAddress), macVendor(l2.HwAddress))
+	case ptrField:
+		name1, _ := reverseDNS.Lookup(l1.IpAddress)
+		name2, _ := reverseDNS.Lookup(l2.IpAddress)
+		return cmp(name1, name2)
+	case ddnsField:
+		return cmp(DdnsFlags(l1), DdnsFlags(l2))
+	case ddnsStatusField:
+		return cmp(DdnsStatus(l1), DdnsStatus(l2))
 	}
 	return 0
 }
 
-func UpdateTable(url string, dispmode displayMode, subnet *Subnet4, table *tview.Table, sortorder *[]SortData) {
+// Column titles of the lease table, in the field order of Compare
+var leaseHeader = []string{"Hostname", "IP", "MAC", "State", "Timestamp", "Client ID", "Vendor", "PTR", "DDNS", "DNS"}
+
+// Fields of the lease table after the first six, the vendor, PTR and
+// DNS status columns being optional
+const (
+	vendorField     = 6
+	ptrField        = 7
+	ddnsField       = 8
+	ddnsStatusField = 9
+)
+
+// Whether the lease tables show the vendor column, which o toggles
+var showVendor bool
+
+// Returns the fields of the columns the lease tables show, in order
+func shownLeaseFields() []int {
+	fields := []int{0, 1, 2, 3, 4, 5, ddnsField}
+	if ddnsStatusKey != "" {
+		fields = append(fields, ddnsStatusField)
+	}
+	if showVendor {
+		fields = append(fields, vendorField)
+	}
+	if showPTR {
+		fields = append(fields, ptrField)
+	}
+	return fields
+}
+
+// Fills the lease columns of a row starting at column col. Reserved
+// addresses are marked with a star.
+func SetLeaseCells(table *tview.Table, row, col int, l *Lease4, reserved bool) {
+	prefix := ""
+	var attr tcell.AttrMask = 0
+	if reserved {
+		attr = tcell.AttrBold
+		prefix = "*"
+	}
+	stateText, stateColor := LeaseState(l.State)
+	t := time.Unix(l.Cltt, 0)
+	table.SetCell(row, col, tview.NewTableCell(prefix+l.Hostname).SetAttributes(attr))
+	table.SetCell(row, col+1, tview.NewTableCell(l.IpAddress))
+	table.SetCell(row, col+2, tview.NewTableCell(l.HwAddress))
+	table.SetCell(row, col+3, tview.NewTableCell(stateText).SetTextColor(stateColor))
+	table.SetCell(row, col+4, tview.NewTableCell(t.Format(timeFormat)))
+	table.SetCell(row, col+5, tview.NewTableCell(l.ClientId))
+	table.SetCell(row, col+6, tview.NewTableCell(DdnsFlags(l)))
+	col += 7
+	if ddnsStatusKey != "" {
+		table.SetCell(row, col, ddnsStatusCell(l))
+		col++
+	}
+	if showVendor {
+		table.SetCell(row, col, tview.NewTableCell(macVendor(l.HwAddress)))
+		col++
+	}
+	if showPTR {
+		table.SetCell(row, col, ptrCell(l))
+	}
+}
+
+// Keeps the leases fetched for the subnet of a view, marking those
+// that are new and recording the MACs seen
+func (v *viewState) setLeases(leases []Lease4) {
+	v.leases = append([]Lease4{}, leases...)
+	v.markFresh(leases)
+	v.recordMACs(leases, time.Now())
+}
+
+// Fills the table with the subnet of a view in its display mode. The
+// leases are fetched when the view has none cached, and only those
+// matching its filter are shown.
+func UpdateTable(ctx context.Context, client *Client, view *viewState, table *tview.Table) {
 	table.Clear()
+	subnet := view.subnet
+	sortorder := &view.sortorder
 	sortfunc := func(col int) func() bool {
 		return func() bool {
 			(*sortorder)[0].Column = col
 			(*sortorder)[0].Asc = !(*sortorder)[0].Asc
-			UpdateTable(url, dispmode, subnet, table, sortorder)
+			UpdateTable(ctx, client, view, table)
 			return false
 		}
 	}
-	switch dispmode {
+	switch view.dispmode {
 	case displayLeases:
-		table.SetCell(0, 0, tview.NewTableCell("Hostname").
-			SetTextColor(tcell.ColorYellow).
-			SetClickedFunc(sortfunc(0)))
-		table.SetCell(0, 1, tview.NewTableCell("IP").
-			SetTextColor(tcell.ColorYellow).
-			SetClickedFunc(sortfunc(1)))
-		table.SetCell(0, 2, tview.NewTableCell("MAC").
-			SetTextColor(tcell.ColorYellow).
-			SetClickedFunc(sortfunc(2)))
-		table.SetCell(0, 3, tview.NewTableCell("State").
-			SetTextColor(tcell.ColorYellow).
-			SetClickedFunc(sortfunc(3)))
-		table.SetCell(0, 4, tview.NewTableCell("Timestamp").
-			SetTextColor(tcell.ColorYellow).
-			SetClickedFunc(sortfunc(4)))
-		table.SetCell(0, 5, tview.NewTableCell("Client ID").
-			SetTextColor(tcell.ColorYellow).
-			SetClickedFunc(sortfunc(5)))
-		leases := getLeases(url, subnet.Id)
+		for i, field := range shownLeaseFields() {
+			table.SetCell(0, i, tview.NewTableCell(leaseHeader[field]).
+				SetTextColor(tcell.ColorYellow).
+				SetClickedFunc(sortfunc(field)))
+		}
+		if view.leases == nil {
+			leases, err := client.Leases(ctx, subnet.Id).All()
+			if err != nil {
+				table.SetCell(1, 0, tview.NewTableCell(err.Error()).SetTextColor(tcell.ColorRed))
+				break
+			}
+			view.setLeases(leases)
+		}
+		leases := view.leases
 		column := (*sortorder)[0].Column
 		sort.Slice(leases, func(i, j int) bool {
 			if (*sortorder)[0].Asc {
@@ -271,24 +370,34 @@ func UpdateTable(url string, dispmode displayMode, subnet *Subnet4, table *tview
 			return leases[i].Compare(&leases[j], column) > 0
 
 		})
-		for i, l := range leases {
-			t := time.Unix(l.Cltt, 0)
-			prefix := ""
-			var attr tcell.AttrMask = 0
-			for _, r := range subnet.Reservations {
+		filter, err := ParseLeaseFilter(view.filter)
+		if err != nil {
+			table.SetCell(1, 0, tview.NewTableCell("Filter: "+err.Error()).SetTextColor(tcell.ColorRed))
+			break
+		}
+		row := 1
+		for _, l := range leases {
+			var reservation *Reservation
+			for i, r := range subnet.Reservations {
 				if r.IpAddress == l.IpAddress {
-					attr = tcell.AttrBold
-					prefix = "*"
+					reservation = &subnet.Reservations[i]
 					break
 				}
 			}
-			stateText, stateColor := LeaseState(l.State)
-			table.SetCell(i+1, 0, tview.NewTableCell(prefix+l.Hostname).SetAttributes(attr))
-			table.SetCell(i+1, 1, tview.NewTableCell(l.IpAddress))
-			table.SetCell(i+1, 2, tview.NewTableCell(l.HwAddress))
-			table.SetCell(i+1, 3, tview.NewTableCell(stateText).SetTextColor(stateColor))
-			table.SetCell(i+1, 4, tview.NewTableCell(t.Format("2006-01-02T15:04:05")))
-			table.SetCell(i+1, 5, tview.NewTableCell(l.ClientId))
+			reserved := reservation != nil
+			if !filter.Match(&l) || !view.reserved.match(reserved) ||
+				(l.State == stateExpiredReclaimed && !view.reclaimed) {
+				continue
+			}
+			SetLeaseCells(table, row, 0, &l, reserved)
+			table.GetCell(row, 0).SetReference(l)
+			if view.macConflict(&l, reservation) != "" {
+				table.GetCell(row, 1).SetTextColor(conflictColor)
+			}
+			if view.dnsIssues[l.IpAddress] != "" {
+				table.GetCell(row, leaseColumn(ddnsField)).SetTextColor(dnsIssueColor)
+			}
+			row++
 		}
 	case displayReserv:
 		table.SetCell(0, 0, tview.NewTableCell("IP").SetTextColor(tcell.ColorYellow))
@@ -297,13 +406,23 @@ func UpdateTable(url string, dispmode displayMode, subnet *Subnet4, table *tview
 		table.SetCell(0, 3, tview.NewTableCell("Bootfile").SetTextColor(tcell.ColorYellow))
 		table.SetCell(0, 4, tview.NewTableCell("Next Server").SetTextColor(tcell.ColorYellow))
 		table.SetCell(0, 5, tview.NewTableCell("Server Hostname").SetTextColor(tcell.ColorYellow))
-		for i, l := range subnet.Reservations {
-			table.SetCell(i+1, 0, tview.NewTableCell(l.IpAddress))
+		filter, err := ParseLeaseFilter(view.filter)
+		if err != nil {
+			table.SetCell(1, 0, tview.NewTableCell("Filter: "+err.Error()).SetTextColor(tcell.ColorRed))
+			break
+		}
+		i := 0
+		for _, l := range subnet.Reservations {
+			if !filter.Match(l.filterLease()) {
+				continue
+			}
+			table.SetCell(i+1, 0, tview.NewTableCell(l.IpAddress).SetReference(l))
 			table.SetCell(i+1, 1, tview.NewTableCell(l.HwAddress))
 			table.SetCell(i+1, 2, tview.NewTableCell(l.Hostname))
 			table.SetCell(i+1, 3, tview.NewTableCell(l.BootFileName))
 			table.SetCell(i+1, 4, tview.NewTableCell(l.NextServer))
 			table.SetCell(i+1, 5, tview.NewTableCell(l.ServerHostname))
+			i++
 		}
 	case displayInfo:
 		lifetime := time.Duration(subnet.ValidLifetime) * time.Second
@@ -320,19 +439,73 @@ func UpdateTable(url string, dispmode displayMode, subnet *Subnet4, table *tview
 		table.SetCell(4, 0, tview.NewTableCell("ID").SetTextColor(tcell.ColorYellow))
 		table.SetCell(4, 1, tview.NewTableCell(strconv.Itoa(subnet.Id)))
 		i := 5
+		if m, err := NewSubnetMath(subnet.Subnet, subnet.Pools); err == nil {
+			rows := [][]string{
+				{"Network", m.Network.String()},
+				{"Broadcast", m.Broadcast.String()},
+				{"Netmask", m.Netmask.String()},
+				{"Usable hosts", strconv.FormatUint(m.Usable, 10)},
+				{"Pool coverage", fmt.Sprintf("%.1f%% (%d of %d)", m.Coverage(), m.PoolSize, m.Size)},
+			}
+			for j, gap := range m.Gaps {
+				name := ""
+				if j == 0 {
+					name = "Pool gaps"
+				}
+				rows = append(rows, []string{name, fmt.Sprintf("%s - %s (%d)", gap[0], gap[1], rangeSize(gap))})
+			}
+			for _, row := range rows {
+				table.SetCell(i, 0, tview.NewTableCell(row[0]).SetTextColor(tcell.ColorYellow))
+				table.SetCell(i, 1, tview.NewTableCell(row[1]))
+				i++
+			}
+		}
+		// Relayed subnets are selected by the relay address rather
+		// than by the interface the request came in on
+		for j, ip := range subnet.Relay.Addresses() {
+			if j == 0 {
+				table.SetCell(i, 0, tview.NewTableCell("Relay").SetTextColor(tcell.ColorYellow))
+			}
+			table.SetCell(i, 1, tview.NewTableCell(ip))
+			i++
+		}
+		// DHCPv4-over-DHCPv6 clients are selected by the DHCPv6
+		// relay or interface they come through
+		for _, row := range subnet.FourSixFields() {
+			table.SetCell(i, 0, tview.NewTableCell(row[0]).SetTextColor(tcell.ColorYellow))
+			table.SetCell(i, 1, tview.NewTableCell(row[1]))
+			i++
+		}
+		for _, row := range subnet.DdnsFields() {
+			table.SetCell(i, 0, tview.NewTableCell(row[0]).SetTextColor(tcell.ColorYellow))
+			table.SetCell(i, 1, tview.NewTableCell(row[1]))
+			i++
+		}
+		if len(subnet.UserContext) > 0 {
+			table.SetCell(i, 0, tview.NewTableCell("User context").SetTextColor(tcell.ColorYellow))
+			table.SetCell(i, 1, jsonCell(subnet.UserContext))
+			i++
+		}
 		for _, pool := range subnet.Pools {
-			ips := strings.Split(pool.Pool, "-")
+			first, last := pool.Range()
+			if first == nil {
+				continue
+			}
 			table.SetCell(i, 0, tview.NewTableCell("Pool").SetTextColor(tcell.ColorYellow))
-			table.SetCell(i, 1, tview.NewTableCell(ips[0]))
-			table.SetCell(i+1, 1, tview.NewTableCell(ips[1]))
+			table.SetCell(i, 1, tview.NewTableCell(first.String()))
+			table.SetCell(i+1, 1, tview.NewTableCell(last.String()))
 			i += 2
+			if len(pool.UserContext) > 0 {
+				table.SetCell(i, 1, jsonCell(pool.UserContext))
+				i++
+			}
 		}
 		for _, opt := range subnet.OptionData {
 			table.SetCell(i, 0, tview.NewTableCell("Option-data").SetTextColor(tcell.ColorYellow))
 			table.SetCell(i, 1, tview.NewTableCell("Name").SetTextColor(tcell.ColorYellow))
-			table.SetCell(i, 2, tview.NewTableCell(opt.Name))
+			table.SetCell(i, 2, tview.NewTableCell(opt.DisplayName()))
 			table.SetCell(i+1, 1, tview.NewTableCell("Data").SetTextColor(tcell.ColorYellow))
-			table.SetCell(i+1, 2, tview.NewTableCell(opt.Data))
+			table.SetCell(i+1, 2, tview.NewTableCell(opt.Value()))
 			table.SetCell(i+2, 1, tview.NewTableCell("Code").SetTextColor(tcell.ColorYellow))
 			table.SetCell(i+2, 2, tview.NewTableCell(strconv.Itoa(opt.Code)))
 			table.SetCell(i+3, 1, tview.NewTableCell("Space").SetTextColor(tcell.ColorYellow))
@@ -341,11 +514,44 @@ func UpdateTable(url string, dispmode displayMode, subnet *Subnet4, table *tview
 			table.SetCell(i+4, 2, tview.NewTableCell(strconv.FormatBool(opt.CsvFormat)))
 			i += 5
 		}
-
 	}
+	markSelected(table, view.selected, view.freshLeases())
 	table.ScrollToBeginning()
 }
 
+// Returns the hosts behind the selected row, or behind every row when
+// the table is not in selection mode. Leases are converted into
+// reservations.
+func TableHosts(table *tview.Table) []Reservation {
+	first, last := 1, table.GetRowCount()-1
+	if selectable, _ := table.GetSelectable(); selectable {
+		first, _ = table.GetSelection()
+		last = first
+	}
+	var rows []int
+	for i := first; i <= last; i++ {
+		rows = append(rows, i)
+	}
+	return RowHosts(table, rows)
+}
+
+// Returns the hosts behind the given rows, with leases converted into
+// reservations
+func RowHosts(table *tview.Table, rows []int) []Reservation {
+	var hosts []Reservation
+	for _, i := range rows {
+		switch ref := table.GetCell(i, 0).GetReference().(type) {
+		case Lease4:
+			hosts = append(hosts, ReservationFromLease(&ref))
+		case serverLease:
+			hosts = append(hosts, ReservationFromLease(&ref.Lease4))
+		case Reservation:
+			hosts = append(hosts, ref)
+		}
+	}
+	return hosts
+}
+
 func SearchForwardList(input *tview.InputField, list *tview.List, line *tview.TextView) {
 	for _, i := range list.FindItems(input.GetText(), "", false, false) {
 		if i > list.GetCurrentItem() {
@@ -362,7 +568,7 @@ func SearchForwardTable(input *tview.InputField, table *tview.Table, line *tview
 	for i := curr + 1; i < table.GetRowCount(); i++ {
 		for j := 0; j < table.GetColumnCount(); j++ {
 			if strings.Contains(table.GetCell(i, j).Text, input.GetText()) {
-				table.SetSelectable(true, false)
+				table.SetSelectable(true, true)
 				table.Select(i, 0)
 				line.SetText("/" + input.GetText())
 				return
@@ -373,202 +579,141 @@ func SearchForwardTable(input *tview.InputField, table *tview.Table, line *tview
 }
 
 func main() {
-	url := "http://127.0.0.1:8000"
-	if len(os.Args) > 1 {
-		url = "http://" + os.Args[1] + ":8000"
-	}
-	dispmode := displayLeases
-	sortorder := []SortData{
-		SortData{4, true},
-		SortData{1, true},
-	}
-	subnets := getSubnets(url)
-	// Sorts the subnets by IP
-	sort.Slice(subnets, func(i, j int) bool {
-		return bytes.Compare(
-			net.ParseIP(strings.Split(subnets[i].Subnet, "/")[0]),
-			net.ParseIP(strings.Split(subnets[j].Subnet, "/")[0])) < 0
-	})
-	table := tview.NewTable().
-		SetSeparator(tview.Borders.Vertical).
-		SetBorders(false).
-		SetSelectable(false, false)
-	table.SetBorder(true)
-	table.SetTitle("Leases")
-	app := tview.NewApplication().EnableMouse(true)
-	statusline := tview.NewTextView().SetText(url)
-	statusinput := tview.NewInputField()
-	statuspage := tview.NewPages().
-		AddPage("line", statusline, true, true).
-		AddPage("input", statusinput, true, false)
-	subnetList := tview.NewList().
-		ShowSecondaryText(false)
-	subnetList.SetBorder(true)
-	subnetList.SetTitle("Subnets")
-	var prev tview.Primitive
-	prev = subnetList
-	for _, x := range subnets {
-		subnetList.AddItem(x.Subnet, "", 0, nil)
-	}
-	subnetList.SetSelectedFunc(func(index int, text string, stext string, r rune) {
-		UpdateTable(url, dispmode, &subnets[index], table, &sortorder)
-	})
-	statusinput.SetFinishedFunc(func(key tcell.Key) {
-		statuspage.SwitchToPage("line")
-		app.SetFocus(prev)
-		switch prev {
-		case subnetList:
-			SearchForwardList(statusinput, subnetList, statusline)
-		case table:
-			SearchForwardTable(statusinput, table, statusline)
-		}
-	})
-
-	grid := tview.NewGrid().
-		SetColumns(0, -5).
-		SetRows(0, 1).
-		SetBorders(false).
-		AddItem(subnetList, 0, 0, 1, 1, 0, 0, true).
-		AddItem(table, 0, 1, 1, 1, 0, 0, false).
-		AddItem(statuspage, 1, 0, 1, 2, 0, 0, false)
-
-	subnetList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyTab {
-			app.SetFocus(table)
-			return nil
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: %s [flags] [host | URL | socket | profile | group]...\n", os.Args[0])
+		fmt.Fprintf(out, "       %s [flags] <command> [arguments]\n\nCommands:\n", os.Args[0])
+		var names []string
+		for name := range cliCommands {
+			names = append(names, name)
 		}
-		if event.Rune() == 'l' {
-			app.SetFocus(table)
-			return nil
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(out, "  %s\n", cliCommands[name].usage)
 		}
-		if event.Key() == tcell.KeyRight {
-			app.SetFocus(table)
-			return nil
-		}
-		if event.Rune() == 'j' {
-			return tcell.NewEventKey(tcell.KeyDown, 258, tcell.ModNone)
-		}
-		if event.Rune() == 'k' {
-			return tcell.NewEventKey(tcell.KeyUp, 257, tcell.ModNone)
-		}
-		if event.Rune() == 'n' {
-			SearchForwardList(statusinput, subnetList, statusline)
-			return event
-		}
-		if event.Rune() == 'N' {
-			indexes := subnetList.FindItems(statusinput.GetText(), "", false, false)
-			curr := subnetList.GetCurrentItem()
-			for j, i := range indexes {
-				if i >= curr && j > 0 {
-					statusline.SetText("?" + statusinput.GetText())
-					subnetList.SetCurrentItem(indexes[j-1])
-					if indexes[j-1] == curr {
-						statusline.SetText("Pattern not found \"" + statusinput.GetText() + "\"")
-					}
-					return event
-				}
-			}
-			statusline.SetText("Pattern not found \"" + statusinput.GetText() + "\"")
-			return event
+		fmt.Fprintln(out, "  completion bash | zsh | fish")
+		fmt.Fprintln(out, "\nCommands exit with 0 on success, 1 on Kea errors, 2 on transport")
+		fmt.Fprintln(out, "errors and 3 when the result is empty.")
+		fmt.Fprintln(out, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.StringVar(&netbox.URL, "netbox-url", os.Getenv("NETBOX_URL"),
+		"base `URL` of the NetBox instance used by the NetBox export")
+	flag.StringVar(&netbox.Token, "netbox-token", os.Getenv("NETBOX_TOKEN"),
+		"NetBox API `token`")
+	flag.DurationVar(&requestTimeout, "timeout", requestTimeout,
+		"timeout for each request to the Control Agent")
+	flag.DurationVar(&healthInterval, "health-interval", healthInterval,
+		"`interval` between health checks of the servers in the TUI, 0 to disable")
+	flag.DurationVar(&refreshInterval, "refresh", refreshInterval,
+		"`interval` at which the TUI fetches the table shown again, 0 to disable")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"log the commands that would change a server instead of sending them")
+	strict := flag.Bool("strict", false,
+		"fail on response fields ybyra does not know, to spot Kea schema changes")
+	pick := flag.Bool("pick", false,
+		"pick a lease with Enter and print it to stdout on exit")
+	pickFormat := flag.String("pick-format", "{ip}",
+		"`template` printed for the picked lease, with {ip}, {mac}, {hostname}, {client-id} and {state}")
+	server := flag.String("server", "127.0.0.1",
+		"`address` of the Control Agent or control socket, or a server profile name")
+	configPath := flag.String("config", defaultConfigPath(),
+		"configuration `file` with server profiles")
+	flag.Parse()
+	switch flag.Arg(0) {
+	case "completion", "__complete":
+		var err error
+		if flag.Arg(0) == "completion" {
+			err = printCompletion(os.Stdout, flag.Args()[1:])
+		} else {
+			err = complete(os.Stdout, flag.Args()[1:])
 		}
-		if event.Rune() == '/' {
-			statuspage.SwitchToPage("input")
-			prev = subnetList
-			app.SetFocus(statuspage)
-			return nil
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
 		}
-		return event
+		os.Exit(exitCode(err))
+	}
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	ouiFile = config.OUIFile
+	showVendor = config.ShowVendor
+	ddnsStatusKey = config.DdnsStatusKey
+	serverSet := false
+	flag.Visit(func(f *flag.Flag) {
+		serverSet = serverSet || f.Name == "server"
 	})
-
-	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyTab {
-			app.SetFocus(subnetList)
-			return nil
-		}
-		_, col := table.GetOffset()
-		if col < 1 {
-			if event.Rune() == 'h' {
-				app.SetFocus(subnetList)
-				return nil
-			}
-			if event.Key() == tcell.KeyLeft {
-				app.SetFocus(subnetList)
-				return nil
-			}
-		}
-		if event.Rune() == 'n' {
-			SearchForwardTable(statusinput, table, statusline)
-			return event
-		}
-		if event.Rune() == 'N' {
-			curr, _ := table.GetSelection()
-			for i := curr - 1; i > 0; i-- {
-				for j := 0; j < table.GetColumnCount(); j++ {
-					if strings.Contains(table.GetCell(i, j).Text, statusinput.GetText()) {
-						table.SetSelectable(true, false)
-						table.Select(i, 0)
-						statusline.SetText("?" + statusinput.GetText())
-						return event
-					}
-				}
-			}
-			statusline.SetText("Pattern not found \"" + statusinput.GetText() + "\"")
-			return event
+	// Subcommands only need discovery for a -server that is no
+	// configured profile, the TUI for the servers it lists
+	_, configured := config.Servers[*server]
+	_, isCLI := cliCommands[flag.Arg(0)]
+	if len(config.Discovery) > 0 && (!isCLI || serverSet && !configured) {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		for _, err := range config.Discover(ctx) {
+			fmt.Fprintln(os.Stderr, "discovery:", err)
 		}
-		if selectable, _ := table.GetSelectable(); event.Rune() == 'd' && selectable && dispmode == displayLeases {
-			row, _ := table.GetSelection()
-			ipaddr := table.GetCell(row, 1).Text
-			_, text := DelLease(url, ipaddr)
-			statusline.SetText(text)
-			return nil
-		}
-		if event.Key() == tcell.KeyEnter {
-			row, _ := table.GetSelectable()
-			table.SetSelectable(!row, false)
+		cancel()
+	}
+	if isCLI {
+		client, err := NewProfileClient(config.Profile(*server))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		if event.Rune() == '/' {
-			statuspage.SwitchToPage("input")
-			prev = table
-			app.SetFocus(statuspage)
-			return nil
+		client.Strict = *strict
+		os.Exit(runCLI(client, flag.Arg(0), flag.Args()[1:]))
+	}
+	// The TUI connects to the servers and groups given as arguments,
+	// or to every configured server unless -server is set
+	var names []string
+	for _, arg := range flag.Args() {
+		if _, ok := config.Groups[arg]; ok {
+			names = append(names, config.GroupServers(arg)...)
+		} else {
+			names = append(names, arg)
 		}
-		return event
-	})
-
-	statusinput.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyEscape {
-			statuspage.SwitchToPage("line")
-			app.SetFocus(prev)
-			return nil
+	}
+	if len(names) == 0 {
+		names = []string{*server}
+		if !serverSet && len(config.Servers) > 0 {
+			names = config.ServerNames()
 		}
-		return event
-	})
-
-	grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if (event.Rune() == 'q' || event.Key() == tcell.KeyEscape) && !statuspage.HasFocus() {
-			app.Stop()
-			return nil
+	}
+	var servers []*serverView
+	for _, name := range names {
+		s, err := newServerView(config, name, *strict)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, name+": "+err.Error())
+			os.Exit(1)
 		}
-		if event.Rune() == 'm' {
-			dispmode = (dispmode + 1) % 3
-			UpdateTable(url,
-				dispmode,
-				&subnets[subnetList.GetCurrentItem()],
-				table,
-				&sortorder)
-			switch dispmode {
-			case displayLeases:
-				table.SetTitle("Leases")
-			case displayReserv:
-				table.SetTitle("Reservations")
-			case displayInfo:
-				table.SetTitle("Subnet Information")
-			}
+		servers = append(servers, s)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	loadServers(ctx, servers)
+	if len(servers) == 1 && servers[0].err != nil {
+		fmt.Fprintln(os.Stderr, servers[0].err)
+		os.Exit(1)
+	}
+	u := newUI(ctx, servers)
+	if showVendor {
+		if err := loadVendors(); err != nil {
+			u.statusline.SetText(err.Error())
 		}
-		return event
-	})
-
-	if err := app.SetRoot(grid, true).SetFocus(grid).Run(); err != nil {
+	}
+	u.pick, u.pickFormat = *pick, *pickFormat
+	u.config, u.strict = config, *strict
+	u.queueing = config.QueueChanges
+	u.setMouse(!config.DisableMouse)
+	if err := u.run(); err != nil {
 		panic(err)
 	}
+	if *pick {
+		if u.picked == "" {
+			os.Exit(1)
+		}
+		fmt.Println(u.picked)
+	}
 }